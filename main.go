@@ -8,6 +8,7 @@ import (
 
 	"github.com/MykolaSainiuk/schatgo/src/api"
 	"github.com/MykolaSainiuk/schatgo/src/server"
+	"github.com/MykolaSainiuk/schatgo/src/worker"
 )
 
 //	@title			sChat Server API
@@ -28,6 +29,7 @@ func main() {
 	defer srv.Shutdown()
 
 	api.InitRoutes(srv)
+	stopWorkers := worker.StartAll(srv)
 
 	stoppedServerCh := srv.Run()
 
@@ -42,6 +44,8 @@ func main() {
 		slog.Info("SIGTERM signal caught", slog.String("signal", sig.String()))
 	}
 
+	stopWorkers()
+
 	if sig != nil {
 		// os.Exit(sig)
 		os.Exit(1)
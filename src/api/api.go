@@ -4,16 +4,24 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/middleware"
+	"github.com/MykolaSainiuk/schatgo/src/model"
 
 	"github.com/MykolaSainiuk/schatgo/src/api/authapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/botapi"
 	"github.com/MykolaSainiuk/schatgo/src/api/chatapi"
 	"github.com/MykolaSainiuk/schatgo/src/api/chatapi/messageapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/chatapi/webhookapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/eventapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/mentionapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/searchapi"
 	"github.com/MykolaSainiuk/schatgo/src/api/userapi"
+	"github.com/MykolaSainiuk/schatgo/src/api/userapi/blockapi"
 	"github.com/MykolaSainiuk/schatgo/src/api/userapi/contactapi"
 )
 
@@ -22,49 +30,190 @@ func apiRouter(srv types.IServer) chi.Router {
 
 	AuthOnly := middleware.Authorized(srv.GetDB())
 
+	PublicLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "public", Limit: 20, Period: time.Minute})
+	UserLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "user", Limit: 300, Period: time.Minute})
+	MessageLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "messages", Limit: 60, Period: time.Minute})
+	ChatLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "chats", Limit: 10, Period: time.Minute})
+	ContactRequestLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "contact_requests", Limit: 20, Period: time.Hour})
+	SearchLimit := middleware.RateLimit(middleware.RateLimitConfig{Name: "search", Limit: 30, Period: time.Minute})
+	IncomingHookLimit := middleware.RateLimit(middleware.RateLimitConfig{
+		Name: "incoming_hooks", Limit: 30, Period: time.Minute, Key: webhookapi.IncomingHookRateLimitKey,
+	})
+
 	r.Group(func(r chi.Router) {
+		r.Use(PublicLimit)
 		authHandler := authapi.NewAuthHandler(srv)
+		userHandler := userapi.NewUserHandler(srv)
 
 		r.Route("/user", func(r chi.Router) {
 			r.Post("/register", authHandler.RegisterUser)
 			r.Post("/login", authHandler.LoginUser)
+			r.Post("/login/2fa", authHandler.LoginTwoFactor)
+			r.Get("/oidc/authorize", authHandler.OidcAuthorize)
+			r.Post("/oidc/callback", authHandler.OidcCallback)
+			r.Post("/password/forgot", authHandler.ForgotPassword)
+			r.Post("/password/reset", authHandler.ResetPassword)
+			r.Post("/email/confirm", userHandler.ConfirmEmail)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		authHandler := authapi.NewAuthHandler(srv)
+		r.Post("/user/me/password", authHandler.ChangePassword)
+		r.Route("/user/me/2fa", func(r chi.Router) {
+			r.Post("/enroll", authHandler.EnrollTwoFactor)
+			r.Post("/verify", authHandler.VerifyTwoFactor)
+			r.Post("/recovery-codes", authHandler.RegenerateRecoveryCodes)
+			r.Post("/disable", authHandler.DisableTwoFactor)
 		})
+		r.Post("/user/me/oidc/link/authorize", authHandler.OidcLinkAuthorize)
+		r.Post("/user/me/oidc/link/callback", authHandler.OidcLinkCallback)
+		r.Post("/user/me/oidc/reauth/authorize", authHandler.OidcReauthAuthorize)
+		r.Post("/user/me/oidc/reauth/callback", authHandler.OidcReauthCallback)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(AuthOnly)
+		r.Use(UserLimit)
 		userHandler := userapi.NewUserHandler(srv)
 		r.Get("/user/me", userHandler.GetUserInfo)
+		r.Patch("/user/me", userHandler.UpdateProfile)
+		r.Post("/user/me/email/confirm", userHandler.SendEmailConfirmation)
+		r.Put("/user/me/privacy", userHandler.UpdatePrivacy)
+		r.Put("/user/me/handle", userHandler.ChangeHandle)
+		r.Get("/user/handle/{handle}", userHandler.GetUserByHandle)
+		r.Get("/user/search", userHandler.SearchUsers)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(AuthOnly)
+		r.Use(UserLimit)
 		contactHandler := contactapi.NewContactHandler(srv)
 		r.Route("/user/contact", func(r chi.Router) {
-			r.Put("/add", contactHandler.AddContact)
+			r.With(ContactRequestLimit).Put("/request/new", contactHandler.SendContactRequest)
+			r.Get("/request/list/incoming", contactHandler.ListIncomingRequests)
+			r.Get("/request/list/outgoing", contactHandler.ListOutgoingRequests)
+			r.Post("/request/{requestId}/respond", contactHandler.RespondContactRequest)
+			r.Delete("/request/{requestId}", contactHandler.CancelContactRequest)
 			r.Get("/list/all", contactHandler.ListAllContacts)
 			r.Get("/list", contactHandler.ListContactsPaginated)
+			r.Delete("/{userId}", contactHandler.RemoveContact)
 		})
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		blockHandler := blockapi.NewBlockHandler(srv)
+		r.Route("/user/block", func(r chi.Router) {
+			r.Put("/add", blockHandler.BlockUser)
+			r.Get("/list", blockHandler.ListBlocked)
+			r.Delete("/{userId}", blockHandler.UnblockUser)
+		})
+	})
+
+	// routes open to bots declare API key scope before AuthOnly
+	ChatsReaders := middleware.AllowBots(model.ScopeChatsRead)
+	MessagesReaders := middleware.AllowBots(model.ScopeMessagesRead)
+	MessagesWriters := middleware.AllowBots(model.ScopeMessagesWrite)
+
+	r.Group(func(r chi.Router) {
 		chatHandler := chatapi.NewChatHandler(srv)
 		r.Route("/chat", func(r chi.Router) {
-			r.Put("/new", chatHandler.NewChat)
-			r.Get("/list/all", chatHandler.ListAllChats)
-			r.Get("/list", chatHandler.ListChatsPaginated)
-			r.Delete("/{chatId}/clear", chatHandler.ClearChat)
+			r.With(AuthOnly, UserLimit, ChatLimit).Put("/new", chatHandler.NewChat)
+			r.With(AuthOnly, UserLimit, ChatLimit).Put("/group/new", chatHandler.NewGroup)
+			r.With(AuthOnly, UserLimit).Get("/invite/list", chatHandler.ListInvites)
+			r.With(AuthOnly, UserLimit).Post("/{chatId}/invite/accept", chatHandler.AcceptInvite)
+			r.With(AuthOnly, UserLimit).Delete("/{chatId}/invite", chatHandler.DeclineInvite)
+			r.With(ChatsReaders, AuthOnly, UserLimit).Get("/list/all", chatHandler.ListAllChats)
+			r.With(ChatsReaders, AuthOnly, UserLimit).Get("/list", chatHandler.ListChatsPaginated)
+			r.With(AuthOnly, UserLimit, ChatLimit).Patch("/{chatId}", chatHandler.UpdateChat)
+			r.With(AuthOnly, UserLimit).Delete("/{chatId}/clear", chatHandler.ClearChat)
+			r.With(AuthOnly, UserLimit).Put("/{chatId}/retention", chatHandler.SetChatRetention)
+			r.With(AuthOnly, UserLimit).Patch("/{chatId}/settings", chatHandler.UpdateChatSettings)
 		})
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		webhookHandler := webhookapi.NewWebhookHandler(srv)
+		r.Route("/chat/{chatId}/webhook", func(r chi.Router) {
+			r.Put("/new", webhookHandler.NewWebhook)
+			r.Get("/list", webhookHandler.ListWebhooks)
+			r.Delete("/{webhookId}", webhookHandler.DeleteWebhook)
+			r.Get("/{webhookId}/deliveries", webhookHandler.ListDeliveries)
+			r.Post("/{webhookId}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver)
+			r.Put("/incoming/new", webhookHandler.NewIncomingHook)
+			r.Get("/incoming/list", webhookHandler.ListIncomingHooks)
+			r.Delete("/incoming/{hookId}", webhookHandler.RevokeIncomingHook)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		webhookHandler := webhookapi.NewWebhookHandler(srv)
+		r.With(IncomingHookLimit).Post("/hook/{token}", webhookHandler.PostIncomingHook)
+	})
+
+	r.Group(func(r chi.Router) {
 		msgHandler := messageapi.NewMessageHandler(srv)
 		r.Route("/message", func(r chi.Router) {
-			r.Put("/{chatId}/new", msgHandler.NewMessage)
-			r.Get("/{chatId}/list/all", msgHandler.ListAllMessages)
-			r.Get("/{chatId}/list", msgHandler.ListMessagesPaginated)
+			r.With(MessagesWriters, AuthOnly, UserLimit, MessageLimit).Put("/{chatId}/new", msgHandler.NewMessage)
+			r.With(MessagesWriters, AuthOnly, UserLimit, MessageLimit).Put("/{chatId}/poll/new", msgHandler.NewPoll)
+			r.With(MessagesWriters, AuthOnly, UserLimit, MessageLimit).Post("/{chatId}/forward", msgHandler.ForwardMessages)
+			r.With(AuthOnly, UserLimit).Post("/{chatId}/{messageId}/vote", msgHandler.VotePoll)
+			r.With(MessagesReaders, AuthOnly, UserLimit).Get("/{chatId}/pinned", msgHandler.ListPinnedMessages)
+			r.With(AuthOnly, UserLimit).Post("/{chatId}/{messageId}/pin", msgHandler.PinMessage)
+			r.With(AuthOnly, UserLimit).Delete("/{chatId}/{messageId}/pin", msgHandler.UnpinMessage)
+			r.With(MessagesWriters, AuthOnly, UserLimit).Get("/{chatId}/scheduled/list", msgHandler.ListScheduledMessages)
+			r.With(MessagesWriters, AuthOnly, UserLimit).Patch("/{chatId}/scheduled/{scheduledId}", msgHandler.EditScheduledMessage)
+			r.With(MessagesWriters, AuthOnly, UserLimit).Delete("/{chatId}/scheduled/{scheduledId}", msgHandler.CancelScheduledMessage)
+			r.With(MessagesReaders, AuthOnly, UserLimit).Get("/{chatId}/list/all", msgHandler.ListAllMessages)
+			r.With(MessagesReaders, AuthOnly, UserLimit).Get("/{chatId}/list", msgHandler.ListMessagesPaginated)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		botHandler := botapi.NewBotHandler(srv)
+		r.Route("/bot", func(r chi.Router) {
+			r.Put("/new", botHandler.NewBot)
+			r.Get("/list", botHandler.ListBots)
+			r.Put("/{botId}/key/new", botHandler.NewApiKey)
+			r.Get("/{botId}/key/list", botHandler.ListApiKeys)
+			r.Delete("/{botId}/key/{keyId}", botHandler.RevokeApiKey)
+			r.Put("/{botId}/commands", botHandler.SetBotCommands)
+			r.Get("/{botId}/commands", botHandler.GetBotCommands)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		eventHandler := eventapi.NewEventHandler(srv)
+		r.Get("/events", eventHandler.StreamEvents)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		searchHandler := searchapi.NewSearchHandler(srv)
+		r.Route("/search", func(r chi.Router) {
+			r.With(SearchLimit).Get("/messages", searchHandler.SearchMessages)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(AuthOnly)
+		r.Use(UserLimit)
+		mentionHandler := mentionapi.NewMentionHandler(srv)
+		r.Route("/mentions", func(r chi.Router) {
+			r.Get("/", mentionHandler.ListMentions)
+			r.Get("/unread", mentionHandler.ListUnreadMentions)
+			r.Post("/{chatId}/read", mentionHandler.ReadMentions)
 		})
 	})
 
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
 
@@ -13,7 +15,10 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/iphelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
 	"github.com/MykolaSainiuk/schatgo/src/service/authservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
 )
 
 type AuthHandler struct {
@@ -28,7 +33,7 @@ func NewAuthHandler(srv types.IServer) *AuthHandler {
 // RegisterUser method
 //
 //	@Summary		Register user
-//	@Description	Register user with name & avatar
+//	@Description	Register user with handle, name & avatar (handle is derived from name if omitted)
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
@@ -59,7 +64,14 @@ func (handler *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request)
 	userId, err := handler.authService.RegisterNewUser(ctx, &body)
 	if err != nil {
 		if errors.Is(err, cmnerr.ErrUniqueViolation) {
-			httpexp.From(err, "such name is already occupied", http.StatusUnprocessableEntity).Reply(w)
+			httpexp.From(err, "such handle is already occupied", http.StatusUnprocessableEntity).Reply(w)
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidHandle) ||
+			errors.Is(err, pwdhelper.ErrPasswordTooShort) ||
+			errors.Is(err, pwdhelper.ErrPasswordTooLong) ||
+			errors.Is(err, pwdhelper.ErrPasswordTooSimple) {
+			httpexp.From(err, MsgInvalidRegisterInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
 			return
 		}
 		// if errors.Is(err, cmnerr.ErrHashGeneration) {
@@ -76,12 +88,14 @@ func (handler *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request)
 // LoginUser method
 //
 //	@Summary		Login user
-//	@Description	Make access token for user
+//	@Description	Make access token for user. If User has 2FA enabled, only challenge token is returned to be passed to /api/user/login/2fa
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			body	body		dto.LoginInputDto	true	"Registration form"
 //	@Success		200		{object}	dto.LoginOutputDto
+//	@Success		202		{object}	dto.LoginOutputDto	"2FA code required"
+//	@Failure		429		{object}	httpexp.HttpExp	"Locked out after failed attempts, see Retry-After"
 //	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
 //	@Router			/auth/login [post]
 func (handler *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
@@ -101,8 +115,11 @@ func (handler *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	// FromError(err, http.StatusUnauthorized).SetNewMessage(failedToLoginMsg) - bcz always oblivious about reasons
 
 	ctx := r.Context()
-	accessToken, err := handler.authService.LoginUser(ctx, body.Name, body.Password)
+	output, err := handler.authService.LoginUser(ctx, body.Handle, body.Password, iphelper.ClientIP(r))
 	if err != nil {
+		if replyLoginLocked(w, err) {
+			return
+		}
 		if errors.Is(err, cmnerr.ErrNotFoundEntity) || errors.Is(err, cmnerr.ErrHashMismatch) || errors.Is(err, cmnerr.ErrGenerateAccessToken) {
 			httpexp.From(err, MsgFailedToLogin, http.StatusUnauthorized).Reply(w)
 			return
@@ -111,6 +128,75 @@ func (handler *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if output.TwoFactorRequired {
+		w.WriteHeader(http.StatusAccepted)
+		res, _ := json.Marshal(output)
+		w.Write(res)
+		return
+	}
+
+	replyAccessToken(w, r, output.AccessToken)
+}
+
+// LoginTwoFactor method
+//
+//	@Summary		Complete 2FA login
+//	@Description	Exchange challenge token from login & TOTP (or recovery) code for access token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.TwoFactorLoginInputDto	true	"Challenge token & code"
+//	@Success		200		{object}	dto.LoginOutputDto
+//	@Failure		401		{object}	httpexp.HttpExp	"Bad challenge token or code"
+//	@Failure		429		{object}	httpexp.HttpExp	"Locked out after failed attempts, see Retry-After"
+//	@Router			/api/user/login/2fa [post]
+func (handler *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body dto.TwoFactorLoginInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgFailedToLogin, http.StatusUnauthorized).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgFailedToLogin, http.StatusUnauthorized, validationErrs...).Reply(w)
+		return
+	}
+
+	accessToken, err := handler.authService.CompleteTwoFactorLogin(r.Context(), body.ChallengeToken, body.Code, iphelper.ClientIP(r))
+	if err != nil {
+		if replyLoginLocked(w, err) {
+			return
+		}
+		if errors.Is(err, cmnerr.ErrInvalidToken) || errors.Is(err, cmnerr.ErrExpiredToken) ||
+			errors.Is(err, cmnerr.ErrNotFoundEntity) || errors.Is(err, authservice.ErrInvalidTwoFactorCode) ||
+			errors.Is(err, cmnerr.ErrGenerateAccessToken) {
+			httpexp.From(err, MsgFailedToLogin, http.StatusUnauthorized).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	replyAccessToken(w, r, accessToken)
+}
+
+// replyLoginLocked replies 429 with Retry-After if login is locked out
+func replyLoginLocked(w http.ResponseWriter, err error) bool {
+	var lockedErr *authservice.LoginLockedError
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+
+	seconds := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	httpexp.From(err, MsgTooManyLoginAttempts, http.StatusTooManyRequests).Reply(w)
+	return true
+}
+
+func replyAccessToken(w http.ResponseWriter, r *http.Request, accessToken string) {
 	w.WriteHeader(http.StatusOK)
 	rawRes := r.URL.Query().Get("raw")
 	if rawRes == "true" {
@@ -121,7 +207,149 @@ func (handler *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ChangePassword method
+//
+//	@Summary		Change password
+//	@Description	Set new password and log out every other session of User
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Param			body	body		dto.ChangePasswordInputDto	true	"Current & new passwords"
+//	@Success		204
+//	@Failure		403		{object}	httpexp.HttpExp	"Wrong current password"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error or weak password"
+//	@Router			/api/user/me/password [post]
+func (handler *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var body dto.ChangePasswordInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidChangePasswordInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidChangePasswordInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	accessToken, _ := ctx.Value(types.RawAccessToken{}).(string)
+
+	err := handler.authService.ChangePassword(ctx, userID, accessToken, body.CurrentPassword, body.ReauthToken, body.NewPassword)
+	if err != nil {
+		replyPasswordError(w, err, MsgInvalidChangePasswordInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ForgotPassword method
+//
+//	@Summary		Request password reset
+//	@Description	Mail one-time reset token to User owning the email. Replies the same whether such User exists or not
+//	@Tags			auth
+//	@Accept			json
+//	@Param			body	body	dto.ForgotPasswordInputDto	true	"User email"
+//	@Success		202
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/user/password/forgot [post]
+func (handler *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body dto.ForgotPasswordInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidResetPasswordInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidResetPasswordInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	if err := handler.authService.RequestPasswordReset(r.Context(), body.Email); err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(nil)
+}
+
+// ResetPassword method
+//
+//	@Summary		Reset password
+//	@Description	Set new password by one-time reset token and log out every session of User
+//	@Tags			auth
+//	@Accept			json
+//	@Param			body	body	dto.ResetPasswordInputDto	true	"Reset token & new password"
+//	@Success		204
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error, weak password or bad token"
+//	@Router			/api/user/password/reset [post]
+func (handler *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var body dto.ResetPasswordInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidResetPasswordInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidResetPasswordInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	err := handler.authService.ResetPassword(r.Context(), body.Token, body.NewPassword)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidResetToken) {
+			httpexp.From(err, MsgInvalidResetPasswordInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+			return
+		}
+		replyPasswordError(w, err, MsgInvalidResetPasswordInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+func replyPasswordError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, cmnerr.ErrPasswordMismatch):
+		httpexp.From(err, "current password is wrong", http.StatusForbidden).Reply(w)
+	case errors.Is(err, pwdhelper.ErrPasswordTooShort),
+		errors.Is(err, pwdhelper.ErrPasswordTooLong),
+		errors.Is(err, pwdhelper.ErrPasswordTooSimple),
+		errors.Is(err, authservice.ErrSamePassword):
+		httpexp.From(err, msg, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+	case errors.Is(err, authservice.ErrInvalidTwoFactorCode):
+		httpexp.From(err, err.Error(), http.StatusForbidden).Reply(w)
+	case errors.Is(err, authservice.ErrTwoFactorEnabled),
+		errors.Is(err, authservice.ErrTwoFactorNotEnrolled):
+		httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
 const (
 	MsgFailedToLogin        = "failed to login user"
+	MsgTooManyLoginAttempts = "too many failed login attempts, try later"
 	MsgInvalidRegisterInput = "invalid input to register user"
+
+	MsgInvalidChangePasswordInput = "invalid input to change password"
+	MsgInvalidResetPasswordInput  = "invalid input to reset password"
+	MsgInvalidTwoFactorInput      = "invalid two-factor authentication input"
+	MsgInvalidOidcInput           = "invalid oidc callback input"
+	MsgFailedOidc                 = "failed to sign in with identity provider"
 )
@@ -0,0 +1,206 @@
+package authapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/authservice"
+)
+
+// OidcAuthorize method
+//
+//	@Summary		Start OIDC login
+//	@Description	Make authorization URL of corporate identity provider (code flow with PKCE) to redirect browser to
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200		{object}	dto.OidcAuthorizeOutputDto
+//	@Failure		501		{object}	httpexp.HttpExp	"OIDC is not configured"
+//	@Router			/api/user/oidc/authorize [get]
+func (handler *AuthHandler) OidcAuthorize(w http.ResponseWriter, r *http.Request) {
+	authURL, err := handler.authService.StartOidcLogin(r.Context(), "")
+	if err != nil {
+		replyOidcError(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.OidcAuthorizeOutputDto{AuthorizationUrl: authURL})
+	w.Write(res)
+}
+
+// OidcCallback method
+//
+//	@Summary		Finish OIDC login
+//	@Description	Exchange state & code provider redirected back with for access token. User is created on first login if sign up is allowed.
+//	@Description	If User has two-factor authentication enabled only challenge token is returned, finish with /api/user/login/2fa
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.OidcCallbackInputDto	true	"State & code"
+//	@Success		200		{object}	dto.LoginOutputDto
+//	@Success		202		{object}	dto.LoginOutputDto	"Second factor required"
+//	@Failure		401		{object}	httpexp.HttpExp	"Bad state, code or ID token"
+//	@Failure		403		{object}	httpexp.HttpExp	"Sign up is disabled"
+//	@Router			/api/user/oidc/callback [post]
+func (handler *AuthHandler) OidcCallback(w http.ResponseWriter, r *http.Request) {
+	var body dto.OidcCallbackInputDto
+	if !decodeBody(w, r, &body, MsgInvalidOidcInput) {
+		return
+	}
+
+	output, err := handler.authService.FinishOidcLogin(r.Context(), body.State, body.Code)
+	if err != nil {
+		replyOidcError(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	if output.TwoFactorRequired {
+		w.WriteHeader(http.StatusAccepted)
+		res, _ := json.Marshal(output)
+		w.Write(res)
+		return
+	}
+
+	replyAccessToken(w, r, output.AccessToken)
+}
+
+// OidcLinkAuthorize method
+//
+//	@Summary		Start OIDC identity linking
+//	@Description	Make authorization URL to link identity of the provider to current User
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{object}	dto.OidcAuthorizeOutputDto
+//	@Failure		501		{object}	httpexp.HttpExp	"OIDC is not configured"
+//	@Router			/api/user/me/oidc/link/authorize [post]
+func (handler *AuthHandler) OidcLinkAuthorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	authURL, err := handler.authService.StartOidcLogin(ctx, userID)
+	if err != nil {
+		replyOidcError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.OidcAuthorizeOutputDto{AuthorizationUrl: authURL})
+	w.Write(res)
+}
+
+// OidcLinkCallback method
+//
+//	@Summary		Finish OIDC identity linking
+//	@Description	Link identity provider redirected back with to current User
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Param			body	body		dto.OidcCallbackInputDto	true	"State & code"
+//	@Success		204
+//	@Failure		409		{object}	httpexp.HttpExp	"Identity is linked to another User"
+//	@Failure		422		{object}	httpexp.HttpExp	"Bad state, code or ID token"
+//	@Router			/api/user/me/oidc/link/callback [post]
+func (handler *AuthHandler) OidcLinkCallback(w http.ResponseWriter, r *http.Request) {
+	var body dto.OidcCallbackInputDto
+	if !decodeBody(w, r, &body, MsgInvalidOidcInput) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if err := handler.authService.FinishOidcLink(ctx, userID, body.State, body.Code); err != nil {
+		replyOidcError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// OidcReauthAuthorize method
+//
+//	@Summary		Start OIDC re-authentication
+//	@Description	Make authorization URL to prove presence of current User by linked identity, for users without password
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{object}	dto.OidcAuthorizeOutputDto
+//	@Failure		501		{object}	httpexp.HttpExp	"OIDC is not configured"
+//	@Router			/api/user/me/oidc/reauth/authorize [post]
+func (handler *AuthHandler) OidcReauthAuthorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	authURL, err := handler.authService.StartOidcReauth(ctx, userID)
+	if err != nil {
+		replyOidcError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.OidcAuthorizeOutputDto{AuthorizationUrl: authURL})
+	w.Write(res)
+}
+
+// OidcReauthCallback method
+//
+//	@Summary		Finish OIDC re-authentication
+//	@Description	Exchange state & code for short-lived reauth token, accepted instead of current password
+//	@Description	to change (or set the first) password, enroll or disable 2FA and change email
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.OidcCallbackInputDto	true	"State & code"
+//	@Success		200		{object}	dto.OidcReauthOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Identity isn't linked to current User"
+//	@Failure		422		{object}	httpexp.HttpExp	"Bad state, code or ID token"
+//	@Router			/api/user/me/oidc/reauth/callback [post]
+func (handler *AuthHandler) OidcReauthCallback(w http.ResponseWriter, r *http.Request) {
+	var body dto.OidcCallbackInputDto
+	if !decodeBody(w, r, &body, MsgInvalidOidcInput) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	reauthToken, err := handler.authService.FinishOidcReauth(ctx, userID, body.State, body.Code)
+	if err != nil {
+		replyOidcError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.OidcReauthOutputDto{ReauthToken: reauthToken})
+	w.Write(res)
+}
+
+// replyOidcError uses rejectCode for flow failures caused by client or provider response
+func replyOidcError(w http.ResponseWriter, err error, rejectCode int) {
+	switch {
+	case errors.Is(err, oidchelper.ErrNotConfigured):
+		httpexp.From(err, err.Error(), http.StatusNotImplemented).Reply(w)
+	case errors.Is(err, oidchelper.ErrProviderUnavailable):
+		httpexp.From(err, err.Error(), http.StatusBadGateway).Reply(w)
+	case errors.Is(err, authservice.ErrOidcStateMismatch),
+		errors.Is(err, oidchelper.ErrExchangeFailed),
+		errors.Is(err, oidchelper.ErrInvalidIDToken):
+		httpexp.From(err, MsgFailedOidc, rejectCode, err.Error()).Reply(w)
+	case errors.Is(err, authservice.ErrOidcSignupDisabled),
+		errors.Is(err, authservice.ErrIdentityNotLinked):
+		httpexp.From(err, err.Error(), http.StatusForbidden).Reply(w)
+	case errors.Is(err, authservice.ErrIdentityTaken):
+		httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
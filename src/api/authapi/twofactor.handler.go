@@ -0,0 +1,164 @@
+package authapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+)
+
+// EnrollTwoFactor method
+//
+//	@Summary		Enroll 2FA
+//	@Description	Generate TOTP secret & otpauth URI for authenticator app, requires password. 2FA is enabled only after code verification
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.TwoFactorEnrollInputDto	true	"Password"
+//	@Success		200		{object}	dto.TwoFactorEnrollOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Wrong password"
+//	@Failure		409		{object}	httpexp.HttpExp	"2FA is already enabled"
+//	@Router			/api/user/me/2fa/enroll [post]
+func (handler *AuthHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body dto.TwoFactorEnrollInputDto
+	if !decodeTwoFactorBody(w, r, &body) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.authService.EnrollTwoFactor(ctx, userID, body.Password, body.ReauthToken)
+	if err != nil {
+		replyPasswordError(w, err, MsgInvalidTwoFactorInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// VerifyTwoFactor method
+//
+//	@Summary		Verify 2FA
+//	@Description	Enable 2FA by the first TOTP code. Recovery codes are returned only once
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.TwoFactorCodeInputDto	true	"TOTP code"
+//	@Success		200		{object}	dto.RecoveryCodesOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Invalid code"
+//	@Failure		409		{object}	httpexp.HttpExp	"2FA is not enrolled or already enabled"
+//	@Router			/api/user/me/2fa/verify [post]
+func (handler *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body dto.TwoFactorCodeInputDto
+	if !decodeTwoFactorBody(w, r, &body) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	codes, err := handler.authService.VerifyTwoFactor(ctx, userID, body.Code)
+	if err != nil {
+		replyPasswordError(w, err, MsgInvalidTwoFactorInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.RecoveryCodesOutputDto{RecoveryCodes: codes})
+	w.Write(res)
+}
+
+// RegenerateRecoveryCodes method
+//
+//	@Summary		Regenerate 2FA recovery codes
+//	@Description	Replace all recovery codes with new ones, requires TOTP code
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.TwoFactorCodeInputDto	true	"TOTP code"
+//	@Success		200		{object}	dto.RecoveryCodesOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Invalid code"
+//	@Failure		409		{object}	httpexp.HttpExp	"2FA is not enabled"
+//	@Router			/api/user/me/2fa/recovery-codes [post]
+func (handler *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var body dto.TwoFactorCodeInputDto
+	if !decodeTwoFactorBody(w, r, &body) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	codes, err := handler.authService.RegenerateRecoveryCodes(ctx, userID, body.Code)
+	if err != nil {
+		replyPasswordError(w, err, MsgInvalidTwoFactorInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.RecoveryCodesOutputDto{RecoveryCodes: codes})
+	w.Write(res)
+}
+
+// DisableTwoFactor method
+//
+//	@Summary		Disable 2FA
+//	@Description	Turn 2FA off, requires password and TOTP or recovery code
+//	@Tags			auth
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Param			body	body		dto.DisableTwoFactorInputDto	true	"Password & code"
+//	@Success		204
+//	@Failure		403		{object}	httpexp.HttpExp	"Wrong password or code"
+//	@Failure		409		{object}	httpexp.HttpExp	"2FA is not enabled"
+//	@Router			/api/user/me/2fa/disable [post]
+func (handler *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body dto.DisableTwoFactorInputDto
+	if !decodeTwoFactorBody(w, r, &body) {
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if err := handler.authService.DisableTwoFactor(ctx, userID, body.Password, body.ReauthToken, body.Code); err != nil {
+		replyPasswordError(w, err, MsgInvalidTwoFactorInput)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+func decodeTwoFactorBody(w http.ResponseWriter, r *http.Request, body any) bool {
+	return decodeBody(w, r, body, MsgInvalidTwoFactorInput)
+}
+
+// decodeBody unmarshals & validates JSON body replying 422 with msg on failure
+func decodeBody(w http.ResponseWriter, r *http.Request, body any, msg string) bool {
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, body); err != nil {
+		httpexp.From(err, msg, http.StatusUnprocessableEntity).Reply(w)
+		return false
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, msg, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return false
+	}
+	return true
+}
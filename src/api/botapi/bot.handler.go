@@ -0,0 +1,290 @@
+package botapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/botservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+type BotHandler struct {
+	BotService *botservice.BotService
+}
+
+func NewBotHandler(srv types.IServer) *BotHandler {
+	botService := botservice.NewBotService(srv)
+	return &BotHandler{botService}
+}
+
+// NewBot method
+//
+//	@Summary		Create bot
+//	@Description	Create bot user owned by User. Bot posts via API keys, open chat with it by handle to let it in
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.NewBotInputDto	true	"Bot data"
+//	@Success		201		{object}	dto.BotOutputDto
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error or occupied handle"
+//	@Router			/api/bot/new [put]
+func (handler *BotHandler) NewBot(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewBotInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidBotInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidBotInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	bot, err := handler.BotService.CreateBot(ctx, userID, &body)
+	if err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrUniqueViolation):
+			httpexp.From(err, "such handle is already occupied", http.StatusUnprocessableEntity).Reply(w)
+		case errors.Is(err, userservice.ErrInvalidHandle), errors.Is(err, botservice.ErrTooManyBots):
+			httpexp.From(err, MsgInvalidBotInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		default:
+			replyBotError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(botservice.ToBotOutputDto(bot))
+	w.Write(res)
+}
+
+// ListBots method
+//
+//	@Summary		List own bots
+//	@Description	List bots owned by User
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{array}		dto.BotOutputDto
+//	@Router			/api/bot/list [get]
+func (handler *BotHandler) ListBots(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	bots, err := handler.BotService.ListBots(ctx, userID)
+	if err != nil {
+		replyBotError(w, err)
+		return
+	}
+
+	output := make([]dto.BotOutputDto, len(bots))
+	for i := range bots {
+		output[i] = botservice.ToBotOutputDto(&bots[i])
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// NewApiKey method
+//
+//	@Summary		Create bot API key
+//	@Description	Issue scoped API key for own bot. The key is shown only once, pass it as "Authorization: Bearer sck_..."
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			botId	path		string					true	"Bot ID"
+//	@Param			body	body		dto.NewApiKeyInputDto	true	"Key name & scopes"
+//	@Success		201		{object}	dto.NewApiKeyOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found bot"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/bot/{botId}/key/new [put]
+func (handler *BotHandler) NewApiKey(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewApiKeyInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidApiKeyInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidApiKeyInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.BotService.CreateApiKey(ctx, userID, chi.URLParam(r, "botId"), &body)
+	if err != nil {
+		replyBotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// ListApiKeys method
+//
+//	@Summary		List bot API keys
+//	@Description	List API keys of own bot including revoked ones
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			botId	path		string	true	"Bot ID"
+//	@Success		200		{array}		model.ApiKey
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found bot"
+//	@Router			/api/bot/{botId}/key/list [get]
+func (handler *BotHandler) ListApiKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	keys, err := handler.BotService.ListApiKeys(ctx, userID, chi.URLParam(r, "botId"))
+	if err != nil {
+		replyBotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(keys)
+	w.Write(res)
+}
+
+// RevokeApiKey method
+//
+//	@Summary		Revoke bot API key
+//	@Description	Revoke API key of own bot, it stops working immediately
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Param			botId	path	string	true	"Bot ID"
+//	@Param			keyId	path	string	true	"API key ID"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found bot or active key"
+//	@Router			/api/bot/{botId}/key/{keyId} [delete]
+func (handler *BotHandler) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.BotService.RevokeApiKey(ctx, userID, chi.URLParam(r, "botId"), chi.URLParam(r, "keyId"))
+	if err != nil {
+		replyBotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// SetBotCommands method
+//
+//	@Summary		Set bot slash commands
+//	@Description	Replace slash commands of own bot. When a chat member sends "/name args" (or "/name@bot args") into a chat with the bot, the call is POSTed to callbackUrl signed like webhooks (X-Schatgo-Signature) with the returned secret, which rotates on every update. The bot may answer with {"text", "ephemeral"}
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			botId	path		string					true	"Bot ID"
+//	@Param			body	body		dto.BotCommandsInputDto	true	"Callback URL & commands"
+//	@Success		200		{object}	dto.BotCommandsOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found bot"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/bot/{botId}/commands [put]
+func (handler *BotHandler) SetBotCommands(w http.ResponseWriter, r *http.Request) {
+	var body dto.BotCommandsInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidBotCommandsInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidBotCommandsInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.BotService.SetBotCommands(ctx, userID, chi.URLParam(r, "botId"), &body)
+	if err != nil {
+		if errors.Is(err, botservice.ErrInvalidCommandName) || errors.Is(err, webhookhelper.ErrInvalidUrl) {
+			httpexp.From(err, MsgInvalidBotCommandsInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+			return
+		}
+		replyBotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// GetBotCommands method
+//
+//	@Summary		Get bot slash commands
+//	@Description	Slash commands of own bot, the secret is not included
+//	@Tags			bot
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			botId	path		string	true	"Bot ID"
+//	@Success		200		{object}	dto.BotCommandsOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found bot"
+//	@Router			/api/bot/{botId}/commands [get]
+func (handler *BotHandler) GetBotCommands(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.BotService.GetBotCommands(ctx, userID, chi.URLParam(r, "botId"))
+	if err != nil {
+		replyBotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+func replyBotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, "bot not found", http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "bots cannot own bots", http.StatusForbidden).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
+const (
+	MsgInvalidBotInput    = "invalid input to create bot"
+	MsgInvalidApiKeyInput = "invalid input to create api key"
+
+	MsgInvalidBotCommandsInput = "invalid input to set bot commands"
+)
@@ -16,6 +16,7 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/chatrepo"
 	"github.com/MykolaSainiuk/schatgo/src/service/chatservice"
 	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
 )
@@ -42,6 +43,7 @@ func NewChatHandler(srv types.IServer) *ChatHandler {
 //	@Param			body	body		dto.AddChatInputDto	true	"New chat input"
 //	@Success		201
 //	@Failure		404		{object}	httpexp.HttpExp	"Not found user"
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked"
 //	@Router			/api/chat/new [put]
 func (handler *ChatHandler) NewChat(w http.ResponseWriter, r *http.Request) {
 	var body dto.AddChatInputDto
@@ -59,7 +61,7 @@ func (handler *ChatHandler) NewChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
 	newChat, err := handler.ChatService.CreateChat(ctx, userID, &body)
 	if err != nil {
@@ -67,6 +69,51 @@ func (handler *ChatHandler) NewChat(w http.ResponseWriter, r *http.Request) {
 			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
 			return
 		}
+		if errors.Is(err, cmnerr.ErrForbidden) {
+			httpexp.From(err, "cannot create chat with this user", http.StatusForbidden).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(newChat)
+	w.Write(res)
+}
+
+// NewGroup method
+//
+//	@Summary		Create new group
+//	@Description	Establish group chat with the creator alone, others join it by invites (see /invite command)
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		dto.NewGroupInputDto	true	"New group input"
+//	@Success		201
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/group/new [put]
+func (handler *ChatHandler) NewGroup(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewGroupInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidNewGroupInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidNewGroupInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	newChat, err := handler.ChatService.CreateGroup(ctx, userID, &body)
+	if err != nil {
 		cmnerr.Reply500(w, err)
 		return
 	}
@@ -76,20 +123,110 @@ func (handler *ChatHandler) NewChat(w http.ResponseWriter, r *http.Request) {
 	w.Write(res)
 }
 
+// ListInvites method
+//
+//	@Summary		List invites
+//	@Description	Groups the user is invited into but hasn't joined yet
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{array}		model.Chat
+//	@Router			/api/chat/invite/list [get]
+func (handler *ChatHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := primitive.ObjectIDFromHex(types.GetTokenPayload(ctx).UserID)
+
+	chats, err := handler.ChatService.GetInvitedChats(ctx, userID)
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(chats)
+	w.Write(res)
+}
+
+// AcceptInvite method
+//
+//	@Summary		Accept invite
+//	@Description	Join the group the user is invited into
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId	path	string	true	"Chat ID"
+//	@Success		200		{object}	model.Chat
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found invite"
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked"
+//	@Router			/api/chat/{chatId}/invite/accept [post]
+func (handler *ChatHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := primitive.ObjectIDFromHex(types.GetTokenPayload(ctx).UserID)
+	chatID, _ := primitive.ObjectIDFromHex(chi.URLParam(r, "chatId"))
+
+	chat, err := handler.ChatService.AcceptInvite(ctx, chatID, userID)
+	if err != nil {
+		replyInviteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(chat)
+	w.Write(res)
+}
+
+// DeclineInvite method
+//
+//	@Summary		Decline invite
+//	@Description	Drop invite into the group
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Param			chatId	path	string	true	"Chat ID"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found invite"
+//	@Router			/api/chat/{chatId}/invite [delete]
+func (handler *ChatHandler) DeclineInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := primitive.ObjectIDFromHex(types.GetTokenPayload(ctx).UserID)
+	chatID, _ := primitive.ObjectIDFromHex(chi.URLParam(r, "chatId"))
+
+	if err := handler.ChatService.DeclineInvite(ctx, chatID, userID); err != nil {
+		replyInviteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+func replyInviteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, "invite not found", http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "cannot join chat with blocked user", http.StatusForbidden).Reply(w)
+	case errors.Is(err, chatservice.ErrAlreadyMember):
+		httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
 // ListAllChats method
 //
 //	@Summary		List all chats
-//	@Description	Unpaginated list of all user chats
+//	@Description	Unpaginated list of all user chats, pinned ones first
 //	@Tags			chat
 //	@Security		BearerAuth
+//	@Param			archived	query	string	false	"true lists archived chats only, all lists every chat, archived are hidden by default"
 //	@Produce		json
 //	@Success		200		{array}		dto.ChatOutputDto
 //	@Router			/api/chat/list/all [get]
 func (handler *ChatHandler) ListAllChats(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
-	chats, err := handler.ChatService.GetAllChats(ctx, userID)
+	chats, err := handler.ChatService.GetAllChats(ctx, userID, parseChatsFilter(r))
 
 	renderChats(w, chats, err)
 }
@@ -97,17 +234,18 @@ func (handler *ChatHandler) ListAllChats(w http.ResponseWriter, r *http.Request)
 // ListChatsPaginated method
 //
 //	@Summary		List chats paginated
-//	@Description	Paginated list of User chats
+//	@Description	Paginated list of User chats, pinned ones first
 //	@Tags			chat
 //	@Security		BearerAuth
 //	@Param			page	path	string					false	"page number"
 //	@Param			limit	path	string					false	"page size"
+//	@Param			archived	query	string				false	"true lists archived chats only, all lists every chat, archived are hidden by default"
 //	@Produce		json
 //	@Success		200		{array}		dto.ChatOutputDto
 //	@Router			/api/chat/list [get]
 func (handler *ChatHandler) ListChatsPaginated(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
 	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
 	if err != nil || page < 0 || page > 100 {
@@ -121,11 +259,24 @@ func (handler *ChatHandler) ListChatsPaginated(w http.ResponseWriter, r *http.Re
 	chats, err := handler.ChatService.GetChatsPaginated(ctx, userID, types.PaginationParams{
 		Page:  int(page),
 		Limit: int(limit),
-	})
+	}, parseChatsFilter(r))
 
 	renderChats(w, chats, err)
 }
 
+func parseChatsFilter(r *http.Request) chatrepo.ChatsFilter {
+	switch r.URL.Query().Get("archived") {
+	case "all":
+		return chatrepo.ChatsFilter{}
+	case "true":
+		archived := true
+		return chatrepo.ChatsFilter{Archived: &archived}
+	default:
+		archived := false
+		return chatrepo.ChatsFilter{Archived: &archived}
+	}
+}
+
 func renderChats(w http.ResponseWriter, chats []model.ChatPopulated, err error) {
 	if err != nil {
 		cmnerr.Reply500(w, err)
@@ -144,19 +295,26 @@ func renderChats(w http.ResponseWriter, chats []model.ChatPopulated, err error)
 //	@Tags			chat
 //	@Security		BearerAuth
 //	@Accept			json
+//	@Param			chatId	path	string	true	"Chat ID"
 //	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
 //	@Router			/api/chat/{chatId}/clear [delete]
 func (handler *ChatHandler) ClearChat(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
 	chatId := chi.URLParam(r, "chatId")
 
-	if err := handler.MessageService.ClearChatMessages(ctx, chatId); err != nil {
+	if err := handler.MessageService.ClearChatMessages(ctx, chatId, userID); err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+			return
+		}
 		cmnerr.Reply500(w, err)
 		return
 	}
 
 	_chatId, _ := primitive.ObjectIDFromHex(chatId)
-	if err := handler.ChatService.SetLastMessage(ctx, _chatId, primitive.NilObjectID); err != nil {
+	if err := handler.ChatService.SetLastMessage(ctx, _chatId, primitive.NilObjectID, nil); err != nil {
 		cmnerr.Reply500(w, err)
 		return
 	}
@@ -165,6 +323,159 @@ func (handler *ChatHandler) ClearChat(w http.ResponseWriter, r *http.Request) {
 	w.Write(nil)
 }
 
+// SetChatRetention method
+//
+//	@Summary		Set disappearing messages timer
+//	@Description	New messages of the chat are deleted after given number of seconds, 0 turns it off. Only chat creator (either user in direct chat) can set it. Members are notified by system message
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Param			chatId	path	string						true	"Chat ID"
+//	@Param			body	body	dto.ChatRetentionInputDto	true	"Timer"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/{chatId}/retention [put]
+func (handler *ChatHandler) SetChatRetention(w http.ResponseWriter, r *http.Request) {
+	var body dto.ChatRetentionInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidChatRetentionInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidChatRetentionInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if err := handler.MessageService.SetChatRetention(ctx, chi.URLParam(r, "chatId"), userID, body.RetentionSeconds); err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+		case errors.Is(err, cmnerr.ErrForbidden):
+			httpexp.From(err, "cannot change timer of this chat", http.StatusForbidden).Reply(w)
+		case errors.Is(err, messageservice.ErrInvalidRetention):
+			httpexp.From(err, MsgInvalidChatRetentionInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// UpdateChat method
+//
+//	@Summary		Update chat
+//	@Description	Change name, description or icon of the chat, absent fields are kept and empty ones are cleared. Members are notified by system message.
+//	@Description	Only chat creator (either user in direct chat) may do it
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path	string					true	"Chat ID"
+//	@Param			body	body	dto.UpdateChatInputDto	true	"Chat metadata"
+//	@Success		200		{object}	dto.ChatOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked or cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/{chatId} [patch]
+func (handler *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
+	var body dto.UpdateChatInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidUpdateChatInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidUpdateChatInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	chat, err := handler.MessageService.UpdateChatMetadata(ctx, chi.URLParam(r, "chatId"), userID, &body)
+	if err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+		case errors.Is(err, cmnerr.ErrForbidden):
+			httpexp.From(err, "cannot update this chat", http.StatusForbidden).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(chat)
+	w.Write(res)
+}
+
+// UpdateChatSettings method
+//
+//	@Summary		Update chat settings
+//	@Description	Change preferences of User for the chat: mute, pin, archive and notification level. Other members aren't affected
+//	@Tags			chat
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path	string						true	"Chat ID"
+//	@Param			body	body	dto.ChatSettingsInputDto	true	"Settings to change"
+//	@Success		200		{object}	dto.ChatSettingsOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/{chatId}/settings [patch]
+func (handler *ChatHandler) UpdateChatSettings(w http.ResponseWriter, r *http.Request) {
+	var body dto.ChatSettingsInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidChatSettingsInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidChatSettingsInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	settings, err := handler.ChatService.UpdateChatSettings(ctx, chi.URLParam(r, "chatId"), userID, &body)
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(settings)
+	w.Write(res)
+}
+
 const (
-	MsgInvalidNewChatInput = "invalid input to create new chat"
+	MsgInvalidNewChatInput       = "invalid input to create new chat"
+	MsgInvalidNewGroupInput      = "invalid input to create new group"
+	MsgInvalidChatRetentionInput = "invalid disappearing messages timer"
+	MsgInvalidChatSettingsInput  = "invalid chat settings"
+	MsgInvalidUpdateChatInput    = "invalid input to update chat"
 )
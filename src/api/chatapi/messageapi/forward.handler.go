@@ -0,0 +1,80 @@
+package messageapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+// ForwardMessages method
+//
+//	@Summary		Forward messages
+//	@Description	Copy up to 20 messages of the chat into another chat of the user, in order they were written.
+//	@Description	Copies are attributed to original author (and chat if all members of the destination can open it), images are kept by reference
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path		string						true	"Source chat ID"
+//	@Param			body	body		dto.ForwardMessagesInputDto	true	"Destination and messages"
+//	@Success		201		{object}	dto.ForwardMessagesOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat or message"
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/message/{chatId}/forward [post]
+func (handler *MessageHandler) ForwardMessages(w http.ResponseWriter, r *http.Request) {
+	var body dto.ForwardMessagesInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidForwardInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidForwardInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	payload := types.GetTokenPayload(ctx)
+
+	ids, err := handler.MessageService.ForwardMessages(ctx, payload.UserID, payload.IsBot, chi.URLParam(r, "chatId"), &body)
+	if err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+		case errors.Is(err, cmnerr.ErrForbidden):
+			httpexp.From(err, "cannot write into this chat", http.StatusForbidden).Reply(w)
+		case errors.Is(err, messageservice.ErrCannotForwardSystem):
+			httpexp.From(err, MsgInvalidForwardInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	output := dto.ForwardMessagesOutputDto{Ids: make([]string, 0, len(ids))}
+	for _, id := range ids {
+		output.Ids = append(output.Ids, id.Hex())
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+const (
+	MsgInvalidForwardInput = "invalid input to forward messages"
+)
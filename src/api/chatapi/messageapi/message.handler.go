@@ -17,29 +17,35 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/model"
 	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/pollservice"
 )
 
 type MessageHandler struct {
 	MessageService *messageservice.MessageService
+	PollService    *pollservice.PollService
 }
 
 func NewMessageHandler(srv types.IServer) *MessageHandler {
 	messageService := messageservice.NewMessageService(srv)
-	return &MessageHandler{messageService}
+	pollService := pollservice.NewPollService(srv)
+	return &MessageHandler{messageService, pollService}
 }
 
 // NewMessage method
 //
 // @Summary			Write new message
-// @Description		Add new message into the chat
+// @Description		Add new message into the chat. Text starting with "/" runs slash command (see /help) instead. With sendAt the message is scheduled
+// @Description		and posted at that time as typed
 // @Tags			message
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
 // @Param       	chatId  path      	string  				true  "Chat ID"
 // @Param			body	body		dto.NewMessageInputDto	true	"New contact input"
-// @Success			201		{object}	dto.NewMessageOutputDto	"Created"
+// @Success			201		{object}	dto.NewMessageOutputDto	"Created (or stored reply to slash command)"
+// @Success			202		{object}	model.ScheduledMessage	"Scheduled, or slash command is handled and its reply (if any) is ephemeral (no body then)"
 // @Failure			404		{object}	httpexp.HttpExp	"Not found user"
+// @Failure			403		{object}	httpexp.HttpExp	"Blocked"
 // @Router			/api/message/{chatId}/new [put]
 func (handler *MessageHandler) NewMessage(w http.ResponseWriter, r *http.Request) {
 	var body dto.NewMessageInputDto
@@ -57,19 +63,35 @@ func (handler *MessageHandler) NewMessage(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	payload := types.GetTokenPayload(ctx)
 	chatId := chi.URLParam(r, "chatId")
 
-	newMessageID, err := handler.MessageService.NewMessage(ctx, chatId, userID, &body)
-	if err != nil || newMessageID == primitive.NilObjectID {
+	if body.SendAt != nil {
+		handler.scheduleMessage(w, r, &body)
+		return
+	}
+
+	newMessageID, err := handler.MessageService.NewMessage(ctx, chatId, payload.UserID, &body, messageservice.AsBot(payload.IsBot))
+	if err != nil {
 		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
 			httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
 			return
 		}
+		if errors.Is(err, cmnerr.ErrForbidden) {
+			httpexp.From(err, "cannot write into this chat", http.StatusForbidden).Reply(w)
+			return
+		}
 		cmnerr.Reply500(w, err)
 		return
 	}
 
+	// slash command without stored reply
+	if newMessageID == primitive.NilObjectID {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(nil)
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	res, _ := json.Marshal(dto.NewMessageOutputDto{Id: newMessageID.Hex()})
 	w.Write(res)
@@ -84,12 +106,14 @@ func (handler *MessageHandler) NewMessage(w http.ResponseWriter, r *http.Request
 //	@Produce		json
 //	@Param        	chatId   path      	string  			true	"Chat ID"
 //	@Success		200		{array}		dto.MessageExtendedOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Chat not found or user isn't its member"
 //	@Router			/api/message/{chatId}/list/all [get]
 func (handler *MessageHandler) ListAllMessages(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
 	chatId := chi.URLParam(r, "chatId")
 
-	messages, err := handler.MessageService.GetAllMessages(ctx, chatId)
+	messages, err := handler.MessageService.GetAllMessages(ctx, chatId, userID)
 
 	renderChats(w, messages, err)
 }
@@ -105,9 +129,11 @@ func (handler *MessageHandler) ListAllMessages(w http.ResponseWriter, r *http.Re
 //	@Param			limit	path		string	false	"page size"
 //	@Produce		json
 //	@Success		200		{array}		dto.MessageExtendedOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Chat not found or user isn't its member"
 //	@Router			/api/message/{chatId}/list [get]
 func (handler *MessageHandler) ListMessagesPaginated(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
 	chatId := chi.URLParam(r, "chatId")
 
 	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
@@ -119,7 +145,7 @@ func (handler *MessageHandler) ListMessagesPaginated(w http.ResponseWriter, r *h
 		limit = 10
 	}
 
-	messages, err := handler.MessageService.GetMessagesPaginated(ctx, chatId, types.PaginationParams{
+	messages, err := handler.MessageService.GetMessagesPaginated(ctx, chatId, userID, types.PaginationParams{
 		Page:  int(page),
 		Limit: int(limit),
 	})
@@ -128,6 +154,10 @@ func (handler *MessageHandler) ListMessagesPaginated(w http.ResponseWriter, r *h
 }
 
 func renderChats(w http.ResponseWriter, messages []model.MessagePopulated, err error) {
+	if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+		return
+	}
 	if err != nil {
 		cmnerr.Reply500(w, err)
 		return
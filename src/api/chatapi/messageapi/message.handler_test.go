@@ -0,0 +1,59 @@
+package messageapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+)
+
+func TestListMessagesOfForeignChat(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "paginated", path: "/message/%s/list"},
+		{name: "all", path: "/message/%s/list/all"},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			viewerID, chatID := primitive.NewObjectID(), primitive.NewObjectID()
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "schat.chats", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: chatID},
+				{Key: "name", Value: "private"},
+				{Key: "users", Value: bson.A{primitive.NewObjectID(), primitive.NewObjectID()}},
+				{Key: "createdAt", Value: time.Now()},
+				{Key: "updatedAt", Value: time.Now()},
+			}))
+
+			srv := testhelper.NewServer(mt)
+			handler := NewMessageHandler(srv)
+			r := srv.Router
+			r.Get("/message/{chatId}/list", handler.ListMessagesPaginated)
+			r.Get("/message/{chatId}/list/all", handler.ListAllMessages)
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf(tt.path, chatID.Hex()), nil)
+			req = req.WithContext(testhelper.WithUser(req.Context(), viewerID.Hex()))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected status 404, got %d: %s", w.Code, w.Body.String())
+			}
+			for _, event := range mt.GetAllStartedEvents() {
+				if collection, _ := event.Command.Lookup(event.CommandName).StringValueOK(); collection == "messages" {
+					t.Errorf("expected messages not queried, got %s", event.Command)
+				}
+			}
+		})
+	}
+}
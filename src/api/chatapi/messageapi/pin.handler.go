@@ -0,0 +1,110 @@
+package messageapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+// PinMessage method
+//
+//	@Summary		Pin message
+//	@Description	Put message on top of pinned ones of the chat (up to 10), members get system notice about it.
+//	@Description	Only chat creator (either user in direct chat) may do it
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Param			chatId		path	string	true	"Chat ID"
+//	@Param			messageId	path	string	true	"Message ID"
+//	@Success		204
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found chat or message"
+//	@Failure		403			{object}	httpexp.HttpExp	"Blocked or cannot administer the chat"
+//	@Failure		409			{object}	httpexp.HttpExp	"Pinned already"
+//	@Failure		422			{object}	httpexp.HttpExp	"Too many pinned messages"
+//	@Router			/api/message/{chatId}/{messageId}/pin [post]
+func (handler *MessageHandler) PinMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.MessageService.PinMessage(ctx, chi.URLParam(r, "chatId"), userID, chi.URLParam(r, "messageId"))
+	if err != nil {
+		replyPinError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// UnpinMessage method
+//
+//	@Summary		Unpin message
+//	@Description	Drop message from pinned ones of the chat. Only chat creator (either user in direct chat) may do it
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Param			chatId		path	string	true	"Chat ID"
+//	@Param			messageId	path	string	true	"Message ID"
+//	@Success		204
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found chat or pinned message"
+//	@Failure		403			{object}	httpexp.HttpExp	"Blocked or cannot administer the chat"
+//	@Router			/api/message/{chatId}/{messageId}/pin [delete]
+func (handler *MessageHandler) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.MessageService.UnpinMessage(ctx, chi.URLParam(r, "chatId"), userID, chi.URLParam(r, "messageId"))
+	if err != nil {
+		replyPinError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ListPinnedMessages method
+//
+//	@Summary		List pinned messages
+//	@Description	Pinned messages of the chat, the latest pin first. They don't depend on history pages
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId	path		string	true	"Chat ID"
+//	@Success		200		{array}		dto.MessageExtendedOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Router			/api/message/{chatId}/pinned [get]
+func (handler *MessageHandler) ListPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	messages, err := handler.MessageService.GetPinnedMessages(ctx, chi.URLParam(r, "chatId"), userID)
+	if err != nil {
+		replyPinError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(messages)
+	w.Write(res)
+}
+
+func replyPinError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "cannot pin messages in this chat", http.StatusForbidden).Reply(w)
+	case errors.Is(err, messageservice.ErrAlreadyPinned):
+		httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+	case errors.Is(err, messageservice.ErrTooManyPinned):
+		httpexp.From(err, err.Error(), http.StatusUnprocessableEntity).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
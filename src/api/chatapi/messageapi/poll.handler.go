@@ -0,0 +1,140 @@
+package messageapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/pollservice"
+)
+
+// NewPoll method
+//
+//	@Summary		Start poll
+//	@Description	Post poll message into the chat, single or multiple choice, open or anonymous, optionally closing at given time
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Param			body	body		dto.NewPollInputDto		true	"Poll"
+//	@Success		201		{object}	dto.NewMessageOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/message/{chatId}/poll/new [put]
+func (handler *MessageHandler) NewPoll(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewPollInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidNewPollInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidNewPollInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	poll, err := pollservice.BuildPoll(body.Options, body.MultipleChoice, body.Anonymous, body.ClosesAt)
+	if err != nil {
+		httpexp.From(err, MsgInvalidNewPollInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	payload := types.GetTokenPayload(ctx)
+
+	newMessageID, err := handler.MessageService.NewMessage(ctx, chi.URLParam(r, "chatId"), payload.UserID,
+		&dto.NewMessageInputDto{Text: body.Question}, messageservice.AsBot(payload.IsBot), messageservice.WithPoll(poll))
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+			return
+		}
+		if errors.Is(err, cmnerr.ErrForbidden) {
+			httpexp.From(err, "cannot write into this chat", http.StatusForbidden).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(dto.NewMessageOutputDto{Id: newMessageID.Hex()})
+	w.Write(res)
+}
+
+// VotePoll method
+//
+//	@Summary		Vote in poll
+//	@Description	Choose options of the poll, voting again replaces the choice and empty options retract it
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId		path		string					true	"Chat ID"
+//	@Param			messageId	path		string					true	"Poll message ID"
+//	@Param			body		body		dto.PollVoteInputDto	true	"Chosen option IDs"
+//	@Success		200			{object}	model.PollResults
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found chat or message"
+//	@Failure		403			{object}	httpexp.HttpExp	"Blocked"
+//	@Failure		409			{object}	httpexp.HttpExp	"Poll is closed"
+//	@Failure		422			{object}	httpexp.HttpExp	"Not a poll or invalid choice"
+//	@Router			/api/message/{chatId}/{messageId}/vote [post]
+func (handler *MessageHandler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	var body dto.PollVoteInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidPollVoteInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidPollVoteInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	results, err := handler.PollService.Vote(ctx, userID, chi.URLParam(r, "chatId"), chi.URLParam(r, "messageId"), body.Options)
+	if err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+		case errors.Is(err, cmnerr.ErrForbidden):
+			httpexp.From(err, "cannot vote in this chat", http.StatusForbidden).Reply(w)
+		case errors.Is(err, pollservice.ErrPollClosed):
+			httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+		case errors.Is(err, pollservice.ErrNotPoll), errors.Is(err, pollservice.ErrUnknownPollOption),
+			errors.Is(err, pollservice.ErrSingleChoice):
+			httpexp.From(err, MsgInvalidPollVoteInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(results)
+	w.Write(res)
+}
+
+const (
+	MsgInvalidNewPollInput  = "invalid input to start poll"
+	MsgInvalidPollVoteInput = "invalid vote"
+)
@@ -0,0 +1,144 @@
+package messageapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+// scheduleMessage is NewMessage given sendAt
+func (handler *MessageHandler) scheduleMessage(w http.ResponseWriter, r *http.Request, body *dto.NewMessageInputDto) {
+	ctx := r.Context()
+	payload := types.GetTokenPayload(ctx)
+
+	scheduled, err := handler.MessageService.ScheduleMessage(ctx, chi.URLParam(r, "chatId"), payload.UserID, body, payload.IsBot)
+	if err != nil {
+		replyScheduledError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	res, _ := json.Marshal(scheduled)
+	w.Write(res)
+}
+
+// ListScheduledMessages method
+//
+//	@Summary		List scheduled messages
+//	@Description	List messages the user has scheduled into the chat which aren't posted yet, soonest first. Failed ones stay until cancelled
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId	path		string	true	"Chat ID"
+//	@Success		200		{array}		model.ScheduledMessage
+//	@Router			/api/message/{chatId}/scheduled/list [get]
+func (handler *MessageHandler) ListScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	scheduled, err := handler.MessageService.ListScheduled(ctx, chi.URLParam(r, "chatId"), userID)
+	if err != nil {
+		replyScheduledError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(scheduled)
+	w.Write(res)
+}
+
+// EditScheduledMessage method
+//
+//	@Summary		Edit scheduled message
+//	@Description	Change text, image or time of scheduled message while it's pending
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId		path		string								true	"Chat ID"
+//	@Param			scheduledId	path		string								true	"Scheduled message ID"
+//	@Param			body		body		dto.EditScheduledMessageInputDto	true	"Changes"
+//	@Success		200			{object}	model.ScheduledMessage
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found pending message"
+//	@Failure		422			{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/message/{chatId}/scheduled/{scheduledId} [patch]
+func (handler *MessageHandler) EditScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	var body dto.EditScheduledMessageInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidScheduledMessageInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidScheduledMessageInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	scheduled, err := handler.MessageService.EditScheduled(ctx, chi.URLParam(r, "chatId"), userID, chi.URLParam(r, "scheduledId"), &body)
+	if err != nil {
+		replyScheduledError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(scheduled)
+	w.Write(res)
+}
+
+// CancelScheduledMessage method
+//
+//	@Summary		Cancel scheduled message
+//	@Description	Drop pending or failed scheduled message
+//	@Tags			message
+//	@Security		BearerAuth
+//	@Param			chatId		path	string	true	"Chat ID"
+//	@Param			scheduledId	path	string	true	"Scheduled message ID"
+//	@Success		204
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found message or it's being posted"
+//	@Router			/api/message/{chatId}/scheduled/{scheduledId} [delete]
+func (handler *MessageHandler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.MessageService.CancelScheduled(ctx, chi.URLParam(r, "chatId"), userID, chi.URLParam(r, "scheduledId"))
+	if err != nil {
+		replyScheduledError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+func replyScheduledError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, cmnerr.ErrNotFoundEntity.Error(), http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "cannot write into this chat", http.StatusForbidden).Reply(w)
+	case errors.Is(err, messageservice.ErrInvalidSendAt), errors.Is(err, messageservice.ErrTooManyScheduled):
+		httpexp.From(err, MsgInvalidScheduledMessageInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
+const (
+	MsgInvalidScheduledMessageInput = "invalid input to schedule message"
+)
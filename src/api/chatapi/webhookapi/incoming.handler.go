@@ -0,0 +1,178 @@
+package webhookapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/webhookservice"
+)
+
+// NewIncomingHook method
+//
+//	@Summary		Create incoming hook
+//	@Description	Make secret URL posting into the chat on behalf of User under given display name. The token is shown only once.
+//	@Description	Only chat creator (either user in direct chat) may do it
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path		string							true	"Chat ID"
+//	@Param			body	body		dto.NewIncomingHookInputDto		true	"Display identity"
+//	@Success		201		{object}	dto.NewIncomingHookOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/{chatId}/webhook/incoming/new [put]
+func (handler *WebhookHandler) NewIncomingHook(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewIncomingHookInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidIncomingHookInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidIncomingHookInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.WebhookService.CreateIncomingHook(ctx, userID, chi.URLParam(r, "chatId"), &body)
+	if err != nil {
+		if errors.Is(err, webhookservice.ErrTooManyIncomingHooks) {
+			httpexp.From(err, MsgInvalidIncomingHookInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+			return
+		}
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// ListIncomingHooks method
+//
+//	@Summary		List incoming hooks
+//	@Description	List incoming hooks of the chat including revoked ones, tokens are not included
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId	path		string	true	"Chat ID"
+//	@Success		200		{array}		model.IncomingHook
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Router			/api/chat/{chatId}/webhook/incoming/list [get]
+func (handler *WebhookHandler) ListIncomingHooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	hooks, err := handler.WebhookService.ListIncomingHooks(ctx, userID, chi.URLParam(r, "chatId"))
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(hooks)
+	w.Write(res)
+}
+
+// RevokeIncomingHook method
+//
+//	@Summary		Revoke incoming hook
+//	@Description	Revoke incoming hook of the chat, its URL stops working immediately. Only chat creator (either user in direct chat) may do it
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Param			chatId	path	string	true	"Chat ID"
+//	@Param			hookId	path	string	true	"Incoming hook ID"
+//	@Success		204
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat or active hook"
+//	@Router			/api/chat/{chatId}/webhook/incoming/{hookId} [delete]
+func (handler *WebhookHandler) RevokeIncomingHook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.WebhookService.RevokeIncomingHook(ctx, userID, chi.URLParam(r, "chatId"), chi.URLParam(r, "hookId"))
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// PostIncomingHook method
+//
+//	@Summary		Post via incoming hook
+//	@Description	Post message into the chat of the hook, the secret URL is the only credential
+//	@Tags			webhook
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	path		string								true	"Hook token"
+//	@Param			body	body		dto.IncomingHookMessageInputDto		true	"Message"
+//	@Success		201		{object}	dto.NewMessageOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Unknown or revoked hook"
+//	@Failure		403		{object}	httpexp.HttpExp	"Hook creator cannot write into the chat anymore"
+//	@Failure		429		{object}	httpexp.HttpExp	"Rate limited"
+//	@Router			/api/hook/{token} [post]
+func (handler *WebhookHandler) PostIncomingHook(w http.ResponseWriter, r *http.Request) {
+	var body dto.IncomingHookMessageInputDto
+	data, _ := io.ReadAll(io.LimitReader(r.Body, MaxIncomingHookBodySize))
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidIncomingHookMessage, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidIncomingHookMessage, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	messageID, err := handler.WebhookService.PostViaIncomingHook(r.Context(), chi.URLParam(r, "token"), &body)
+	if err != nil {
+		switch {
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, "hook not found", http.StatusNotFound).Reply(w)
+		case errors.Is(err, cmnerr.ErrForbidden):
+			httpexp.From(err, "cannot write into this chat", http.StatusForbidden).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(dto.NewMessageOutputDto{Id: messageID.Hex()})
+	w.Write(res)
+}
+
+// IncomingHookRateLimitKey buckets posts per hook, the token itself isn't kept in memory
+func IncomingHookRateLimitKey(r *http.Request) string {
+	return "hook:" + pwdhelper.HashToken(chi.URLParam(r, "token"))
+}
+
+const (
+	MsgInvalidIncomingHookInput   = "invalid input to create incoming hook"
+	MsgInvalidIncomingHookMessage = "invalid message to post via incoming hook"
+
+	MaxIncomingHookBodySize = 64 << 10
+)
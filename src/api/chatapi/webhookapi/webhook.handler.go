@@ -0,0 +1,213 @@
+package webhookapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/webhookservice"
+)
+
+type WebhookHandler struct {
+	WebhookService *webhookservice.WebhookService
+}
+
+func NewWebhookHandler(srv types.IServer) *WebhookHandler {
+	webhookService := webhookservice.NewWebhookService(srv)
+	return &WebhookHandler{webhookService}
+}
+
+// NewWebhook method
+//
+//	@Summary		Create chat webhook
+//	@Description	Subscribe URL to chat events. Payloads are POSTed with X-Schatgo-Signature header: "sha256=" + hex HMAC-SHA256 of "{X-Schatgo-Timestamp}.{body}" keyed by the secret, which is shown only once.
+//	@Description	Only chat admins (creator, either user in direct chat) may do it
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Param			body	body		dto.NewWebhookInputDto	true	"Webhook URL & events"
+//	@Success		201		{object}	dto.NewWebhookOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/chat/{chatId}/webhook/new [put]
+func (handler *WebhookHandler) NewWebhook(w http.ResponseWriter, r *http.Request) {
+	var body dto.NewWebhookInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidWebhookInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidWebhookInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	output, err := handler.WebhookService.CreateWebhook(ctx, userID, chi.URLParam(r, "chatId"), &body)
+	if err != nil {
+		if errors.Is(err, webhookhelper.ErrInvalidUrl) || errors.Is(err, webhookservice.ErrTooManyWebhooks) {
+			httpexp.From(err, MsgInvalidWebhookInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+			return
+		}
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// ListWebhooks method
+//
+//	@Summary		List chat webhooks
+//	@Description	List webhooks of the chat, secrets are not included
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId	path		string	true	"Chat ID"
+//	@Success		200		{array}		model.Webhook
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Router			/api/chat/{chatId}/webhook/list [get]
+func (handler *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	webhooks, err := handler.WebhookService.ListWebhooks(ctx, userID, chi.URLParam(r, "chatId"))
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(webhooks)
+	w.Write(res)
+}
+
+// DeleteWebhook method
+//
+//	@Summary		Delete chat webhook
+//	@Description	Delete webhook of the chat together with its delivery log. Only chat admins may do it
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Param			chatId		path	string	true	"Chat ID"
+//	@Param			webhookId	path	string	true	"Webhook ID"
+//	@Success		204
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat or webhook"
+//	@Router			/api/chat/{chatId}/webhook/{webhookId} [delete]
+func (handler *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.WebhookService.DeleteWebhook(ctx, userID, chi.URLParam(r, "chatId"), chi.URLParam(r, "webhookId"))
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ListDeliveries method
+//
+//	@Summary		Webhook delivery log
+//	@Description	Paginated deliveries of the webhook, newest first. Status is one of pending, delivering, succeeded, dead
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			chatId		path		string	true	"Chat ID"
+//	@Param			webhookId	path		string	true	"Webhook ID"
+//	@Param			page		query		string	false	"page number"
+//	@Param			limit		query		string	false	"page size"
+//	@Success		200			{array}		model.WebhookDelivery
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found chat or webhook"
+//	@Router			/api/chat/{chatId}/webhook/{webhookId}/deliveries [get]
+func (handler *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
+	if err != nil || page < 0 || page > 100 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	if err != nil || limit < 0 || limit > 100 {
+		limit = 10
+	}
+
+	deliveries, err := handler.WebhookService.ListDeliveries(ctx, userID, chi.URLParam(r, "chatId"), chi.URLParam(r, "webhookId"), types.PaginationParams{
+		Page:  int(page),
+		Limit: int(limit),
+	})
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(deliveries)
+	w.Write(res)
+}
+
+// Redeliver method
+//
+//	@Summary		Redeliver webhook delivery
+//	@Description	Put succeeded or dead delivery back into the queue with fresh attempts. Only chat admins may do it
+//	@Tags			webhook
+//	@Security		BearerAuth
+//	@Param			chatId		path	string	true	"Chat ID"
+//	@Param			webhookId	path	string	true	"Webhook ID"
+//	@Param			deliveryId	path	string	true	"Delivery ID"
+//	@Success		202
+//	@Failure		403		{object}	httpexp.HttpExp	"User cannot administer the chat"
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat, webhook or finished delivery"
+//	@Router			/api/chat/{chatId}/webhook/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+func (handler *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	err := handler.WebhookService.Redeliver(ctx, userID,
+		chi.URLParam(r, "chatId"), chi.URLParam(r, "webhookId"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		replyWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(nil)
+}
+
+func replyWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, "not found", http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "cannot administer this chat", http.StatusForbidden).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
+const (
+	MsgInvalidWebhookInput = "invalid input to create webhook"
+)
@@ -1,13 +1,19 @@
 package dto
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // -- RegisterUser
 // RegisterInputDto
 type RegisterInputDto struct {
+	Handle    string `json:"handle" validate:"omitempty,min=3,max=33"`
 	Name      string `json:"name" validate:"required,min=2"`
-	Password  string `json:"password" validate:"required,min=6"`
+	Password  string `json:"password" validate:"required"`
 	AvatarUri string `json:"avatarUri" validate:"url|uri|base64url"`
+	Email     string `json:"email" validate:"omitempty,max=254,email"`
 }
 
 // RegisterOutputDto
@@ -18,19 +24,84 @@ type RegisterOutputDto struct {
 // -- LoginUser
 // LoginInputDto
 type LoginInputDto struct {
-	Name     string `json:"name" validate:"required,min=2"`
+	// Handle also accepts the name users registered before handles existed logged in with
+	Handle   string `json:"handle" validate:"required,min=2"`
 	Password string `json:"password" validate:"required,min=6"`
 }
 
-// LoginOutputDto
+// LoginOutputDto carries either access token or 2FA challenge token
 type LoginOutputDto struct {
-	AccessToken string `json:"token"`
+	AccessToken       string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
 	// RefreshToken string `json:"refresh_token"`
 }
 
+// TwoFactorLoginInputDto
+type TwoFactorLoginInputDto struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	// Code is either TOTP code or recovery code
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// TwoFactorEnrollInputDto
+type TwoFactorEnrollInputDto struct {
+	Password string `json:"password" validate:"required_without=ReauthToken"`
+	// ReauthToken of fresh OIDC login replaces password, see /api/user/me/oidc/reauth/callback
+	ReauthToken string `json:"reauthToken"`
+}
+
+// TwoFactorEnrollOutputDto
+type TwoFactorEnrollOutputDto struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauthUri"`
+}
+
+// TwoFactorCodeInputDto
+type TwoFactorCodeInputDto struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// DisableTwoFactorInputDto
+type DisableTwoFactorInputDto struct {
+	Password    string `json:"password" validate:"required_without=ReauthToken"`
+	ReauthToken string `json:"reauthToken"`
+	Code        string `json:"code" validate:"required,max=32"`
+}
+
+// RecoveryCodesOutputDto
+type RecoveryCodesOutputDto struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// OidcAuthorizeOutputDto
+type OidcAuthorizeOutputDto struct {
+	AuthorizationUrl string `json:"authorizationUrl"`
+}
+
+// OidcCallbackInputDto carries query params provider redirected back with
+type OidcCallbackInputDto struct {
+	State string `json:"state" validate:"required,max=128"`
+	Code  string `json:"code" validate:"required,max=2048"`
+}
+
+// OidcReauthOutputDto
+type OidcReauthOutputDto struct {
+	ReauthToken string `json:"reauthToken"`
+}
+
+// ChangePasswordInputDto
+type ChangePasswordInputDto struct {
+	// users signed up via OIDC have no password, they set the first one with ReauthToken
+	CurrentPassword string `json:"currentPassword" validate:"required_without=ReauthToken"`
+	ReauthToken     string `json:"reauthToken"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
 // UserInfoOutputDto
 type UserInfoOutputDto struct {
 	ID        string `json:"_id"`
+	Handle    string `json:"handle"`
 	Name      string `json:"name"`
 	AvatarUri string `json:"avatarUri"`
 
@@ -44,6 +115,7 @@ type UserInfoOutputDto struct {
 // UserInfoExtendedOutputDto
 type UserInfoExtendedOutputDto struct {
 	ID        string `json:"_id"`
+	Handle    string `json:"handle"`
 	Name      string `json:"name"`
 	AvatarUri string `json:"avatarUri"`
 
@@ -54,39 +126,250 @@ type UserInfoExtendedOutputDto struct {
 	UpdatedAt string `json:"updatedAt"`
 }
 
+// UpdateProfileInputDto
+type UpdateProfileInputDto struct {
+	Name       *string `json:"name" validate:"omitempty,min=2,max=64"`
+	AvatarUri  *string `json:"avatarUri" validate:"omitempty,max=2047,url|uri|base64url"`
+	Bio        *string `json:"bio" validate:"omitempty,max=500"`
+	StatusText *string `json:"statusText" validate:"omitempty,max=140"`
+	// empty email removes it, any change of email needs current password
+	Email           *string `json:"email" validate:"omitempty,max=254,len=0|email"`
+	CurrentPassword *string `json:"currentPassword"`
+	ReauthToken     string  `json:"reauthToken"`
+}
+
+// ConfirmEmailInputDto
+type ConfirmEmailInputDto struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ProfileOutputDto
+type ProfileOutputDto struct {
+	ID         string `json:"_id"`
+	Handle     string `json:"handle"`
+	Name       string `json:"name"`
+	AvatarUri  string `json:"avatarUri"`
+	Bio        string `json:"bio"`
+	StatusText string `json:"statusText"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+// ForgotPasswordInputDto
+type ForgotPasswordInputDto struct {
+	Email string `json:"email" validate:"required,max=254,email"`
+}
+
+// ResetPasswordInputDto
+type ResetPasswordInputDto struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+// ChangeHandleInputDto
+type ChangeHandleInputDto struct {
+	Handle string `json:"handle" validate:"required,min=3,max=33"`
+}
+
+// UserPublicOutputDto
+type UserPublicOutputDto struct {
+	ID        string `json:"_id"`
+	Handle    string `json:"handle"`
+	Name      string `json:"name"`
+	AvatarUri string `json:"avatarUri"`
+}
+
+// ContactRequestOutputDto
+type ContactRequestOutputDto struct {
+	ID        string              `json:"_id"`
+	From      UserPublicOutputDto `json:"from"`
+	To        UserPublicOutputDto `json:"to"`
+	Status    string              `json:"status"`
+	CreatedAt string              `json:"createdAt"`
+	UpdatedAt string              `json:"updatedAt"`
+}
+
+// PrivacySettingsInputDto
+type PrivacySettingsInputDto struct {
+	Discoverable *bool `json:"discoverable" validate:"required"`
+}
+
 // AddContactInputDto
 type AddContactInputDto struct {
-	UserName string `json:"username" validate:"required,min=2"`
+	Handle string `json:"handle" validate:"required,min=3"`
+}
+
+// BlockUserInputDto
+type BlockUserInputDto struct {
+	Handle string `json:"handle" validate:"required,min=3"`
+}
+
+// RespondContactRequestInputDto
+type RespondContactRequestInputDto struct {
+	Action string `json:"action" validate:"required,oneof=accept decline"`
 }
 
+const (
+	ContactRequestActionAccept  = "accept"
+	ContactRequestActionDecline = "decline"
+)
+
 // AddChatInputDto
 type AddChatInputDto struct {
-	UserName string `json:"username" validate:"required,min=2"`
+	Handle   string `json:"handle" validate:"required,min=3"`
 	ChatName string `json:"chatName"`
 }
 
-// ChatOutputDto
+// NewGroupInputDto
+type NewGroupInputDto struct {
+	ChatName string `json:"chatName" validate:"required,min=1,max=64"`
+}
+
+// UpdateChatInputDto, absent fields are kept and empty ones are cleared
+type UpdateChatInputDto struct {
+	Name        *string `json:"name" validate:"omitempty,max=64"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+	IconUri     *string `json:"iconUri" validate:"omitempty,max=2047,len=0|url|uri|base64url"`
+}
+
+// ChatOutputDto, settings are of the caller only and null if the caller has never changed them
 type ChatOutputDto struct {
-	ID          string               `json:"_id"`
-	Name        string               `json:"name"`
-	IconUri     string               `json:"iconUri"`
-	Muted       bool                 `json:"muted"`
-	Users       []primitive.ObjectID `json:"users"`
-	LastMessage primitive.ObjectID   `json:"lastMessage"`
-	CreatedAt   string               `json:"createdAt"`
-	UpdatedAt   string               `json:"updatedAt"`
+	ID          string                 `json:"_id"`
+	Name        string                 `json:"name"`
+	IconUri     string                 `json:"iconUri"`
+	Description string                 `json:"description"`
+	Muted       bool                   `json:"muted"`
+	Users       []primitive.ObjectID   `json:"users"`
+	LastMessage primitive.ObjectID     `json:"lastMessage"`
+	Settings    *ChatSettingsOutputDto `json:"settings"`
+	CreatedAt   string                 `json:"createdAt"`
+	UpdatedAt   string                 `json:"updatedAt"`
+}
+
+// ChatSettingsInputDto, absent fields are kept and mutedUntil in the past unmutes
+type ChatSettingsInputDto struct {
+	MutedUntil        *time.Time `json:"mutedUntil"`
+	Pinned            *bool      `json:"pinned"`
+	Archived          *bool      `json:"archived"`
+	NotificationLevel *string    `json:"notificationLevel" validate:"omitempty,oneof=all mentions none"`
+}
+
+// ChatSettingsOutputDto
+type ChatSettingsOutputDto struct {
+	Chat              string  `json:"chat"`
+	User              string  `json:"user"`
+	MutedUntil        *string `json:"mutedUntil"`
+	Pinned            bool    `json:"pinned"`
+	Archived          bool    `json:"archived"`
+	NotificationLevel string  `json:"notificationLevel"`
+	UpdatedAt         string  `json:"updatedAt"`
 }
 
 // ChatExtendedOutputDto
 type ChatExtendedOutputDto struct {
-	ID          string              `json:"_id"`
-	Name        string              `json:"name"`
-	IconUri     string              `json:"iconUri"`
-	Muted       bool                `json:"muted"`
-	Users       []UserInfoOutputDto `json:"users"`
-	LastMessage MessageOutputDto    `json:"lastMessage"`
-	CreatedAt   string              `json:"createdAt"`
-	UpdatedAt   string              `json:"updatedAt"`
+	ID          string                 `json:"_id"`
+	Name        string                 `json:"name"`
+	IconUri     string                 `json:"iconUri"`
+	Description string                 `json:"description"`
+	Muted       bool                   `json:"muted"`
+	Users       []UserInfoOutputDto    `json:"users"`
+	LastMessage MessageOutputDto       `json:"lastMessage"`
+	Settings    *ChatSettingsOutputDto `json:"settings"`
+	CreatedAt   string                 `json:"createdAt"`
+	UpdatedAt   string                 `json:"updatedAt"`
+}
+
+// NewBotInputDto
+type NewBotInputDto struct {
+	Handle    string `json:"handle" validate:"omitempty,min=3,max=33"`
+	Name      string `json:"name" validate:"required,min=2,max=64"`
+	AvatarUri string `json:"avatarUri" validate:"omitempty,max=2047,url|uri|base64url"`
+}
+
+// BotOutputDto
+type BotOutputDto struct {
+	ID        string `json:"_id"`
+	Handle    string `json:"handle"`
+	Name      string `json:"name"`
+	AvatarUri string `json:"avatarUri"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// NewApiKeyInputDto
+type NewApiKeyInputDto struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=messages:read messages:write chats:read"`
+}
+
+// NewApiKeyOutputDto has the key itself, it's shown only once
+type NewApiKeyOutputDto struct {
+	ID     string   `json:"_id"`
+	Key    string   `json:"key"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+}
+
+// NewWebhookInputDto
+type NewWebhookInputDto struct {
+	Url    string   `json:"url" validate:"required,max=2048,http_url"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=message.created message.deleted member.joined"`
+}
+
+// NewWebhookOutputDto has the signing secret, it's shown only once
+type NewWebhookOutputDto struct {
+	ID     string   `json:"_id"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// NewIncomingHookInputDto is display identity messages posted via the hook get
+type NewIncomingHookInputDto struct {
+	Name      string `json:"name" validate:"required,min=2,max=64"`
+	AvatarUri string `json:"avatarUri" validate:"omitempty,max=2047,url|uri|base64url"`
+}
+
+// NewIncomingHookOutputDto has the token itself, it's shown only once
+type NewIncomingHookOutputDto struct {
+	ID     string `json:"_id"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Token  string `json:"token"`
+	Prefix string `json:"prefix"`
+}
+
+// IncomingHookMessageInputDto
+type IncomingHookMessageInputDto struct {
+	Text string `json:"text" validate:"required,min=1,max=4096"`
+}
+
+// BotCommandsInputDto replaces all slash commands of the bot
+type BotCommandsInputDto struct {
+	CallbackUrl string          `json:"callbackUrl" validate:"required,max=2048,http_url"`
+	Commands    []BotCommandDto `json:"commands" validate:"max=50,dive"`
+}
+
+// BotCommandDto
+type BotCommandDto struct {
+	Name        string `json:"name" validate:"required,min=1,max=32"`
+	Description string `json:"description" validate:"max=256"`
+}
+
+// BotCommandsOutputDto has the signing secret only right after it's rotated
+type BotCommandsOutputDto struct {
+	CallbackUrl string          `json:"callbackUrl"`
+	Commands    []BotCommandDto `json:"commands"`
+	Secret      string          `json:"secret,omitempty"`
+}
+
+// ChatRetentionInputDto, 0 turns disappearing messages off
+type ChatRetentionInputDto struct {
+	RetentionSeconds int64 `json:"retentionSeconds" validate:"min=0"`
+}
+
+// UnreadMentionsOutputDto
+type UnreadMentionsOutputDto struct {
+	Chat           primitive.ObjectID `json:"chat"`
+	UnreadMentions int                `json:"unreadMentions"`
 }
 
 // NewMessageInputDto
@@ -94,6 +377,40 @@ type NewMessageInputDto struct {
 	Text  string `json:"text" validate:"required,min=1"`
 	Image string `json:"image"`
 	// Image string `json:"image" validate:"required_without=text,url|uri|base64url"`
+	// SendAt schedules the message instead of posting it now
+	SendAt *time.Time `json:"sendAt"`
+}
+
+// EditScheduledMessageInputDto, omitted fields are left as they are
+type EditScheduledMessageInputDto struct {
+	Text   *string    `json:"text" validate:"omitempty,min=1"`
+	Image  *string    `json:"image"`
+	SendAt *time.Time `json:"sendAt"`
+}
+
+// ForwardMessagesInputDto, messages are forwarded in order they were written
+type ForwardMessagesInputDto struct {
+	To       string   `json:"to" validate:"required,mongodb"`
+	Messages []string `json:"messages" validate:"required,min=1,max=20,dive,mongodb"`
+}
+
+// ForwardMessagesOutputDto
+type ForwardMessagesOutputDto struct {
+	Ids []string `json:"ids"`
+}
+
+// NewPollInputDto
+type NewPollInputDto struct {
+	Question       string     `json:"question" validate:"required,max=300"`
+	Options        []string   `json:"options" validate:"required,min=2,max=10,dive,required,max=100"`
+	MultipleChoice bool       `json:"multipleChoice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closesAt"`
+}
+
+// PollVoteInputDto, empty options retract the vote
+type PollVoteInputDto struct {
+	Options []int `json:"options" validate:"max=10"`
 }
 
 // NewMessageOutputDto
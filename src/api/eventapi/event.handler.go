@@ -0,0 +1,97 @@
+package eventapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/authservice"
+)
+
+type EventHandler struct {
+	AuthService *authservice.AuthService
+}
+
+func NewEventHandler(srv types.IServer) *EventHandler {
+	authService := authservice.NewAuthService(srv)
+	return &EventHandler{authService}
+}
+
+// StreamEvents method
+//
+//	@Summary		Stream events
+//	@Description	Server-sent events addressed to User (profile updates of contacts etc.).
+//	@Description	Stream ends once the access token expires or is revoked
+//	@Tags			event
+//	@Security		BearerAuth
+//	@Produce		text/event-stream
+//	@Success		200
+//	@Router			/api/events [get]
+func (handler *EventHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		cmnerr.Reply500(w, ErrStreamingUnsupported)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	accessToken, _ := ctx.Value(types.RawAccessToken{}).(string)
+
+	events, unsubscribe := eventhelper.Subscribe(_userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", ReconnectDelay.Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			// tokens revoked on another replica are noticed here at the latest
+			if !handler.AuthService.IsTokenActive(ctx, accessToken) {
+				return
+			}
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if event.Type == eventhelper.EventTokensRevoked {
+				if !handler.AuthService.IsTokenActive(ctx, accessToken) {
+					return
+				}
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+const (
+	HeartbeatInterval = 25 * time.Second
+	ReconnectDelay    = 3 * time.Second
+)
+
+var (
+	ErrStreamingUnsupported = errors.New("streaming is not supported")
+)
@@ -0,0 +1,119 @@
+package mentionapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+type MentionHandler struct {
+	MessageService *messageservice.MessageService
+}
+
+func NewMentionHandler(srv types.IServer) *MentionHandler {
+	messageService := messageservice.NewMessageService(srv)
+	return &MentionHandler{messageService}
+}
+
+// ListMentions method
+//
+//	@Summary		List mentions
+//	@Description	Recent messages mentioning User in his chats, the latest first
+//	@Tags			mention
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			page	query		string	false	"page number"
+//	@Param			limit	query		string	false	"page size"
+//	@Success		200		{array}		dto.MessageExtendedOutputDto
+//	@Router			/api/mentions [get]
+func (handler *MentionHandler) ListMentions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
+	if err != nil || page <= 0 || page > 100 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	messages, err := handler.MessageService.ListMentions(ctx, userID, types.PaginationParams{
+		Page:  int(page),
+		Limit: int(limit),
+	})
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(messages)
+	w.Write(res)
+}
+
+// ListUnreadMentions method
+//
+//	@Summary		List unread mentions
+//	@Description	Chats where User has unread mentions with their counts, recently mentioned first
+//	@Tags			mention
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{array}		dto.UnreadMentionsOutputDto
+//	@Router			/api/mentions/unread [get]
+func (handler *MentionHandler) ListUnreadMentions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	counters, err := handler.MessageService.GetUnreadMentions(ctx, userID)
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	output := make([]dto.UnreadMentionsOutputDto, 0, len(counters))
+	for i := range counters {
+		output = append(output, dto.UnreadMentionsOutputDto{Chat: counters[i].Chat, UnreadMentions: counters[i].UnreadMentions})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(output)
+	w.Write(res)
+}
+
+// ReadMentions method
+//
+//	@Summary		Read mentions
+//	@Description	Reset unread mentions counter of User in the chat
+//	@Tags			mention
+//	@Security		BearerAuth
+//	@Param			chatId	path	string	true	"Chat ID"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Router			/api/mentions/{chatId}/read [post]
+func (handler *MentionHandler) ReadMentions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if err := handler.MessageService.ReadMentions(ctx, chi.URLParam(r, "chatId"), userID); err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
@@ -0,0 +1,81 @@
+package searchapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+type SearchHandler struct {
+	MessageService *messageservice.MessageService
+}
+
+func NewSearchHandler(srv types.IServer) *SearchHandler {
+	messageService := messageservice.NewMessageService(srv)
+	return &SearchHandler{messageService}
+}
+
+// SearchMessages method
+//
+//	@Summary		Search messages
+//	@Description	Full-text search of messages in all User chats or within one chat
+//	@Tags			search
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			q		query		string	true	"search query"
+//	@Param			chatId	query		string	false	"Chat ID to search within"
+//	@Param			page	query		string	false	"page number"
+//	@Param			limit	query		string	false	"page size"
+//	@Success		200		{array}		model.MessageSearchHit
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found chat"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/search/messages [get]
+func (handler *SearchHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(query)) < MinQueryLength {
+		httpexp.From(errors.New("search query is too short"), MsgInvalidSearchInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+	chatID := r.URL.Query().Get("chatId")
+
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
+	if err != nil || page < 0 || page > 100 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	hits, err := handler.MessageService.SearchMessages(ctx, userID, chatID, query, types.PaginationParams{
+		Page:  int(page),
+		Limit: int(limit),
+	})
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "chat not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(hits)
+	w.Write(res)
+}
+
+const (
+	MinQueryLength        = 2
+	MsgInvalidSearchInput = "invalid search input"
+)
@@ -0,0 +1,137 @@
+package blockapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/service/contactservice"
+)
+
+type BlockHandler struct {
+	ContactService *contactservice.ContactService
+}
+
+func NewBlockHandler(srv types.IServer) *BlockHandler {
+	contactService := contactservice.NewContactService(srv)
+	return &BlockHandler{contactService}
+}
+
+// BlockUser method
+//
+//	@Summary		Block user
+//	@Description	Block another user: no chats, messages, contact requests or search results between the two
+//	@Tags			block
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Param			body	body		dto.BlockUserInputDto	true	"User to block"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found user"
+//	@Router			/api/user/block/add [put]
+func (handler *BlockHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	var body dto.BlockUserInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidBlockInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidBlockInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if _, err := handler.ContactService.BlockUser(ctx, userID, body.Handle); err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		if errors.Is(err, contactservice.ErrSelfBlock) {
+			httpexp.From(err, contactservice.ErrSelfBlock.Error(), http.StatusUnprocessableEntity).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// UnblockUser method
+//
+//	@Summary		Unblock user
+//	@Description	Remove user from the block list
+//	@Tags			block
+//	@Security		BearerAuth
+//	@Param			userId	path	string	true	"Blocked user ID"
+//	@Success		204
+//	@Router			/api/user/block/{userId} [delete]
+func (handler *BlockHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	blockedID := chi.URLParam(r, "userId")
+
+	if err := handler.ContactService.UnblockUser(ctx, userID, blockedID); err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ListBlocked method
+//
+//	@Summary		List blocked users
+//	@Description	Block list of User
+//	@Tags			block
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200		{array}		dto.UserPublicOutputDto
+//	@Router			/api/user/block/list [get]
+func (handler *BlockHandler) ListBlocked(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	users, err := handler.ContactService.GetBlockedUsers(ctx, userID)
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	result := make([]dto.UserPublicOutputDto, 0, len(users))
+	for i := range users {
+		result = append(result, dto.UserPublicOutputDto{
+			ID:        users[i].ID.Hex(),
+			Handle:    users[i].Handle,
+			Name:      users[i].Name,
+			AvatarUri: users[i].AvatarUri,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(result)
+	w.Write(res)
+}
+
+const (
+	MsgInvalidBlockInput = "invalid input to block user"
+)
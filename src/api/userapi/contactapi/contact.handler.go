@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 
 	"github.com/MykolaSainiuk/schatgo/src/api/dto"
@@ -14,31 +15,36 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/contactservice"
 	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
 )
 
 type ContactHandler struct {
-	UserService *userservice.UserService
+	UserService    *userservice.UserService
+	ContactService *contactservice.ContactService
 }
 
 func NewContactHandler(srv types.IServer) *ContactHandler {
 	userService := userservice.NewUserService(srv)
-	return &ContactHandler{userService}
+	contactService := contactservice.NewContactService(srv)
+	return &ContactHandler{userService, contactService}
 }
 
-// AddContact method
+// SendContactRequest method
 //
-//	@Summary		Add contact to User
-//	@Description	Adding another user as a contact
+//	@Summary		Send contact request
+//	@Description	Ask another user to become a contact (accepts his pending request if there is one)
 //	@Tags			contact
 //	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			body	body		dto.AddContactInputDto	true	"New contact input"
-//	@Success		200
+//	@Success		201		{object}	model.ContactRequest
 //	@Failure		404		{object}	httpexp.HttpExp	"Not found user"
-//	@Router			/api/user/contact/add [put]
-func (handler *ContactHandler) AddContact(w http.ResponseWriter, r *http.Request) {
+//	@Failure		403		{object}	httpexp.HttpExp	"Blocked"
+//	@Failure		409		{object}	httpexp.HttpExp	"Already a contact or requested"
+//	@Router			/api/user/contact/request/new [put]
+func (handler *ContactHandler) SendContactRequest(w http.ResponseWriter, r *http.Request) {
 	var body dto.AddContactInputDto
 	data, _ := io.ReadAll(r.Body)
 	if err := json.Unmarshal(data, &body); err != nil {
@@ -54,26 +60,201 @@ func (handler *ContactHandler) AddContact(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
-	err := handler.UserService.AddContact(ctx, userID, body.UserName)
+	request, err := handler.ContactService.SendContactRequest(ctx, userID, body.Handle)
 	if err != nil {
-		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
-			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
-			return
-		}
-		cmnerr.Reply500(w, err)
+		replyContactRequestError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	res, _ := json.Marshal(request)
+	w.Write(res)
+}
+
+// ListIncomingRequests method
+//
+//	@Summary		List incoming contact requests
+//	@Description	Paginated list of pending contact requests sent to User
+//	@Tags			contact
+//	@Security		BearerAuth
+//	@Param			page	query	string					false	"page number"
+//	@Param			limit	query	string					false	"page size"
+//	@Produce		json
+//	@Success		200		{array}		dto.ContactRequestOutputDto
+//	@Router			/api/user/contact/request/list/incoming [get]
+func (handler *ContactHandler) ListIncomingRequests(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	requests, err := handler.ContactService.GetIncomingRequests(ctx, userID, getPaginationParams(r))
+
+	renderContactRequests(w, requests, err)
+}
+
+// ListOutgoingRequests method
+//
+//	@Summary		List outgoing contact requests
+//	@Description	Paginated list of pending contact requests sent by User
+//	@Tags			contact
+//	@Security		BearerAuth
+//	@Param			page	query	string					false	"page number"
+//	@Param			limit	query	string					false	"page size"
+//	@Produce		json
+//	@Success		200		{array}		dto.ContactRequestOutputDto
+//	@Router			/api/user/contact/request/list/outgoing [get]
+func (handler *ContactHandler) ListOutgoingRequests(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	requests, err := handler.ContactService.GetOutgoingRequests(ctx, userID, getPaginationParams(r))
+
+	renderContactRequests(w, requests, err)
+}
+
+// RespondContactRequest method
+//
+//	@Summary		Respond to contact request
+//	@Description	Accept or decline incoming contact request
+//	@Tags			contact
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			requestId	path		string								true	"Contact request ID"
+//	@Param			body		body		dto.RespondContactRequestInputDto	true	"Response"
+//	@Success		200			{object}	model.ContactRequest
+//	@Failure		404			{object}	httpexp.HttpExp	"Not found request"
+//	@Router			/api/user/contact/request/{requestId}/respond [post]
+func (handler *ContactHandler) RespondContactRequest(w http.ResponseWriter, r *http.Request) {
+	var body dto.RespondContactRequestInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidContactRequestResponse, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidContactRequestResponse, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	requestID := chi.URLParam(r, "requestId")
+
+	var request *model.ContactRequest
+	var err error
+	if body.Action == dto.ContactRequestActionAccept {
+		request, err = handler.ContactService.AcceptContactRequest(ctx, userID, requestID)
+	} else {
+		request, err = handler.ContactService.DeclineContactRequest(ctx, userID, requestID)
+	}
+	if err != nil {
+		replyContactRequestError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(request)
+	w.Write(res)
+}
+
+// CancelContactRequest method
+//
+//	@Summary		Cancel contact request
+//	@Description	Withdraw pending contact request sent by User
+//	@Tags			contact
+//	@Security		BearerAuth
+//	@Param			requestId	path	string	true	"Contact request ID"
+//	@Success		204
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found request"
+//	@Router			/api/user/contact/request/{requestId} [delete]
+func (handler *ContactHandler) CancelContactRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	requestID := chi.URLParam(r, "requestId")
+
+	if _, err := handler.ContactService.CancelContactRequest(ctx, userID, requestID); err != nil {
+		replyContactRequestError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// RemoveContact method
+//
+//	@Summary		Remove contact
+//	@Description	Remove users from contacts of each other
+//	@Tags			contact
+//	@Security		BearerAuth
+//	@Param			userId	path	string	true	"Contact user ID"
+//	@Success		204
+//	@Router			/api/user/contact/{userId} [delete]
+func (handler *ContactHandler) RemoveContact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	contactID := chi.URLParam(r, "userId")
+
+	if err := handler.ContactService.RemoveContact(ctx, userID, contactID); err != nil {
+		replyContactRequestError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 	w.Write(nil)
 }
 
+func replyContactRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cmnerr.ErrNotFoundEntity):
+		httpexp.From(err, "contact request or user not found", http.StatusNotFound).Reply(w)
+	case errors.Is(err, cmnerr.ErrForbidden):
+		httpexp.From(err, "cannot add this user as a contact", http.StatusForbidden).Reply(w)
+	case errors.Is(err, cmnerr.ErrAlreadyExists):
+		httpexp.From(err, "already a contact or requested", http.StatusConflict).Reply(w)
+	case errors.Is(err, contactservice.ErrSelfContact):
+		httpexp.From(err, contactservice.ErrSelfContact.Error(), http.StatusUnprocessableEntity).Reply(w)
+	default:
+		cmnerr.Reply500(w, err)
+	}
+}
+
+func renderContactRequests(w http.ResponseWriter, requests []dto.ContactRequestOutputDto, err error) {
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(requests)
+	w.Write(res)
+}
+
+func getPaginationParams(r *http.Request) types.PaginationParams {
+	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
+	if err != nil || page < 0 || page > 100 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	if err != nil || limit < 0 || limit > 100 {
+		limit = 10
+	}
+
+	return types.PaginationParams{
+		Page:  int(page),
+		Limit: int(limit),
+	}
+}
+
 // ListAllContacts method
 //
-//	@Summary		List all potential contacts
-//	@Description	Unpaginated list of all potential contacts of User
+//	@Summary		List all contacts
+//	@Description	Unpaginated list of all contacts of User
 //	@Tags			contact
 //	@Security		BearerAuth
 //	@Produce		json
@@ -82,9 +263,9 @@ func (handler *ContactHandler) AddContact(w http.ResponseWriter, r *http.Request
 //	@Router			/api/user/contact/list/all [get]
 func (handler *ContactHandler) ListAllContacts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
-	contacts, err := handler.UserService.GetAllUsers(ctx, userID)
+	contacts, err := handler.UserService.GetAllContacts(ctx, userID)
 
 	renderContacts(w, contacts, err)
 }
@@ -103,7 +284,7 @@ func (handler *ContactHandler) ListAllContacts(w http.ResponseWriter, r *http.Re
 //	@Router			/api/user/contact/list [get]
 func (handler *ContactHandler) ListContactsPaginated(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
 	page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 32)
 	if err != nil || page < 0 || page > 100 {
@@ -134,5 +315,6 @@ func renderContacts(w http.ResponseWriter, contacts []model.User, err error) {
 }
 
 const (
-	MsgInvalidNewContactInput = "invalid input to add contact"
+	MsgInvalidNewContactInput        = "invalid input to add contact"
+	MsgInvalidContactRequestResponse = "invalid response to contact request"
 )
@@ -3,8 +3,16 @@ package userapi
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
@@ -32,7 +40,7 @@ func NewUserHandler(srv types.IServer) *UserHandler {
 //	@Router			/api/user/me [get]
 func (handler *UserHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value(types.TokenPayload{}).(*types.TokenPayload).UserID
+	userID := types.GetTokenPayload(ctx).UserID
 
 	user, err := handler.UserService.GetUserInfo(ctx, userID)
 	if err != nil {
@@ -48,3 +56,328 @@ func (handler *UserHandler) GetUserInfo(w http.ResponseWriter, r *http.Request)
 	res, _ := json.Marshal(user)
 	w.Write(res)
 }
+
+// UpdateProfile method
+//
+//	@Summary		Update profile
+//	@Description	Change name, avatar, bio, status text or email of User (only provided fields are changed).
+//	@Description	Email change requires currentPassword, the new email is mailed a confirmation link
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			body	body		dto.UpdateProfileInputDto	true	"Profile changes"
+//	@Success		200		{object}	dto.ProfileOutputDto
+//	@Failure		403		{object}	httpexp.HttpExp	"Wrong current password"
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/user/me [patch]
+func (handler *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var body dto.UpdateProfileInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidProfileInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidProfileInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+	if body.Name == nil && body.AvatarUri == nil && body.Bio == nil && body.StatusText == nil && body.Email == nil {
+		httpexp.From(errors.New("nothing to update"), MsgInvalidProfileInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	user, err := handler.UserService.UpdateProfile(ctx, userID, &body)
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		if errors.Is(err, cmnerr.ErrPasswordMismatch) {
+			httpexp.From(err, "current password is wrong", http.StatusForbidden).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(userservice.ToProfileOutputDto(user))
+	w.Write(res)
+}
+
+// SendEmailConfirmation method
+//
+//	@Summary		Send email confirmation
+//	@Description	Mail one more confirmation link to unverified email of User, password resets go to confirmed emails only
+//	@Tags			user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		204
+//	@Failure		409		{object}	httpexp.HttpExp	"No email or it's confirmed already"
+//	@Failure		429		{object}	httpexp.HttpExp	"Too many letters requested"
+//	@Router			/api/user/me/email/confirm [post]
+func (handler *UserHandler) SendEmailConfirmation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	user, err := handler.UserService.GetUserByID(ctx, userID)
+	if err == nil {
+		err = handler.UserService.SendEmailConfirmation(ctx, user)
+	}
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		if errors.Is(err, userservice.ErrNoEmail) || errors.Is(err, userservice.ErrEmailVerified) {
+			httpexp.From(err, err.Error(), http.StatusConflict).Reply(w)
+			return
+		}
+		if errors.Is(err, userservice.ErrTooManyConfirmations) {
+			httpexp.From(err, err.Error(), http.StatusTooManyRequests).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ConfirmEmail method
+//
+//	@Summary		Confirm email
+//	@Description	Mark email verified by one-time token from confirmation letter
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body	dto.ConfirmEmailInputDto	true	"Confirmation token"
+//	@Success		204
+//	@Failure		422		{object}	httpexp.HttpExp	"Invalid, expired or used token"
+//	@Router			/api/user/email/confirm [post]
+func (handler *UserHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var body dto.ConfirmEmailInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidConfirmEmailInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidConfirmEmailInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	if err := handler.UserService.ConfirmEmail(r.Context(), body.Token); err != nil {
+		if errors.Is(err, userservice.ErrInvalidConfirmationToken) {
+			httpexp.From(err, MsgInvalidConfirmEmailInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+// ChangeHandle method
+//
+//	@Summary		Change handle
+//	@Description	Set new unique @handle; the former one keeps redirecting to User. Allowed once per 30 days
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			body	body		dto.ChangeHandleInputDto	true	"New handle"
+//	@Success		200		{object}	dto.ProfileOutputDto
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error or occupied handle"
+//	@Failure		429		{object}	httpexp.HttpExp	"Changed too recently"
+//	@Router			/api/user/me/handle [put]
+func (handler *UserHandler) ChangeHandle(w http.ResponseWriter, r *http.Request) {
+	var body dto.ChangeHandleInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidHandleInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidHandleInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	user, err := handler.UserService.ChangeHandle(ctx, userID, body.Handle)
+	if err != nil {
+		switch {
+		case errors.Is(err, userservice.ErrInvalidHandle):
+			httpexp.From(err, MsgInvalidHandleInput, http.StatusUnprocessableEntity, err.Error()).Reply(w)
+		case errors.Is(err, cmnerr.ErrUniqueViolation):
+			httpexp.From(err, "such handle is already occupied", http.StatusUnprocessableEntity).Reply(w)
+		case errors.Is(err, userservice.ErrHandleCooldown):
+			httpexp.From(err, userservice.ErrHandleCooldown.Error(), http.StatusTooManyRequests).Reply(w)
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+		default:
+			cmnerr.Reply500(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(userservice.ToProfileOutputDto(user))
+	w.Write(res)
+}
+
+// GetUserByHandle method
+//
+//	@Summary		Get user by handle
+//	@Description	Public profile by current or former @handle
+//	@Tags			user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			handle	path		string	true	"Handle"
+//	@Success		200		{object}	dto.UserPublicOutputDto
+//	@Failure		404		{object}	httpexp.HttpExp	"Not found user"
+//	@Router			/api/user/handle/{handle} [get]
+func (handler *UserHandler) GetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	user, err := handler.UserService.GetUserByHandle(ctx, chi.URLParam(r, "handle"))
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+	userservice.HideFromBlocked(_userID, user)
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(dto.UserPublicOutputDto{
+		ID:        user.ID.Hex(),
+		Handle:    user.Handle,
+		Name:      user.Name,
+		AvatarUri: user.AvatarUri,
+	})
+	w.Write(res)
+}
+
+// SearchUsers method
+//
+//	@Summary		Search users
+//	@Description	Case-insensitive name or handle prefix search among discoverable users
+//	@Tags			user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			q		query		string	true	"name or handle prefix"
+//	@Param			limit	query		string	false	"max number of results"
+//	@Success		200		{array}		dto.UserPublicOutputDto
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/user/search [get]
+func (handler *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(query)) < MinSearchQueryLength {
+		httpexp.From(errors.New("search query is too short"), MsgInvalidSearchInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	if err != nil || limit <= 0 || limit > MaxSearchResults {
+		limit = MaxSearchResults
+	}
+
+	users, err := handler.UserService.SearchUsers(ctx, userID, query, int(limit))
+	if err != nil {
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	result := make([]dto.UserPublicOutputDto, 0, len(users))
+	for i := range users {
+		result = append(result, dto.UserPublicOutputDto{
+			ID:        users[i].ID.Hex(),
+			Handle:    users[i].Handle,
+			Name:      users[i].Name,
+			AvatarUri: users[i].AvatarUri,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	res, _ := json.Marshal(result)
+	w.Write(res)
+}
+
+// UpdatePrivacy method
+//
+//	@Summary		Update privacy settings
+//	@Description	Set whether User can be found via directory search
+//	@Tags			user
+//	@Accept			json
+//	@Security		BearerAuth
+//	@Param			body	body		dto.PrivacySettingsInputDto	true	"Privacy settings"
+//	@Success		204
+//	@Failure		422		{object}	httpexp.HttpExp	"Validation error"
+//	@Router			/api/user/me/privacy [put]
+func (handler *UserHandler) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	var body dto.PrivacySettingsInputDto
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		httpexp.From(err, MsgInvalidPrivacyInput, http.StatusUnprocessableEntity).Reply(w)
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(&body); err != nil {
+		validationErrs := cmnerr.GetValidationErrors(err)
+		httpexp.From(err, MsgInvalidPrivacyInput, http.StatusUnprocessableEntity, validationErrs...).Reply(w)
+		return
+	}
+
+	ctx := r.Context()
+	userID := types.GetTokenPayload(ctx).UserID
+
+	if err := handler.UserService.SetDiscoverable(ctx, userID, *body.Discoverable); err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			httpexp.From(err, "user not found", http.StatusNotFound).Reply(w)
+			return
+		}
+		cmnerr.Reply500(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	w.Write(nil)
+}
+
+const (
+	MinSearchQueryLength = 1
+	MaxSearchResults     = 20
+
+	MsgInvalidSearchInput  = "invalid search input"
+	MsgInvalidPrivacyInput = "invalid input to update privacy settings"
+	MsgInvalidProfileInput = "invalid input to update profile"
+	MsgInvalidHandleInput  = "invalid input to change handle"
+
+	MsgInvalidConfirmEmailInput = "invalid input to confirm email"
+)
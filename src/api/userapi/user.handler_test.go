@@ -0,0 +1,62 @@
+package userapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+)
+
+func TestGetUserByHandleHidesBlockerProfile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("blocked viewer sees identity only", func(mt *mtest.T) {
+		viewerID, blockerID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "schat.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: blockerID},
+			{Key: "handle", Value: "blocker"},
+			{Key: "name", Value: "Blocker"},
+			{Key: "avatarUri", Value: "https://example.com/avatar.png"},
+			{Key: "bio", Value: "my bio"},
+			{Key: "statusText", Value: "busy"},
+			{Key: "email", Value: "blocker@example.com"},
+			{Key: "blocked", Value: bson.A{viewerID}},
+			{Key: "contacts", Value: bson.A{primitive.NewObjectID()}},
+			{Key: "createdAt", Value: time.Now()},
+			{Key: "updatedAt", Value: time.Now()},
+		}))
+
+		srv := testhelper.NewServer(mt)
+		handler := NewUserHandler(srv)
+		r := srv.Router
+		r.Get("/user/handle/{handle}", handler.GetUserByHandle)
+
+		req := httptest.NewRequest(http.MethodGet, "/user/handle/blocker", nil)
+		req = req.WithContext(testhelper.WithUser(req.Context(), viewerID.Hex()))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var profile map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+			t.Fatal(err)
+		}
+		if profile["_id"] != blockerID.Hex() || profile["handle"] != "blocker" || profile["name"] != "Blocker" {
+			t.Errorf("expected blocker identity, got %v", profile)
+		}
+		for _, key := range []string{"avatarUri", "bio", "statusText", "email", "contacts"} {
+			if value, ok := profile[key]; ok && value != "" {
+				t.Errorf("expected %s hidden from blocked viewer, got %v", key, value)
+			}
+		}
+	})
+}
@@ -5,6 +5,8 @@ import "errors"
 var (
 	ErrUniqueViolation     = errors.New("cannot insert duplicate")
 	ErrNotFoundEntity      = errors.New("cannot found such entity")
+	ErrAlreadyExists       = errors.New("such entity already exists")
+	ErrForbidden           = errors.New("action is forbidden")
 	ErrPasswordMismatch    = errors.New("password mismatch")
 	ErrInvalidToken        = errors.New("token is invalid")
 	ErrExpiredToken        = errors.New("token has expired")
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"context"
+
 	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -8,8 +10,33 @@ import (
 type TokenPayload struct {
 	UserID   string `json:"user_id"`
 	UserName string `json:"user_name"`
+
+	// IsBot & Scopes are set for requests authorized by bot API key, never put into JWT
+	IsBot  bool     `json:"-"`
+	Scopes []string `json:"-"`
+}
+
+// tokenPayloadKey is a context key of TokenPayload the request was authorized with.
+// Unlike the payload itself it's always comparable & can't collide with keys of other packages
+type tokenPayloadKey struct{}
+
+// WithTokenPayload puts payload of authorized request into context
+func WithTokenPayload(ctx context.Context, payload *TokenPayload) context.Context {
+	return context.WithValue(ctx, tokenPayloadKey{}, payload)
 }
 
+// GetTokenPayload returns payload put by WithTokenPayload, nil for unauthorized request
+func GetTokenPayload(ctx context.Context) *TokenPayload {
+	payload, _ := ctx.Value(tokenPayloadKey{}).(*TokenPayload)
+	return payload
+}
+
+// BotScope is a context key of API key scope the route lets bots in with
+type BotScope struct{}
+
+// RawAccessToken is a context key of encoded access token the request was authorized with
+type RawAccessToken struct{}
+
 type IServer interface {
 	GetRouter() chi.Router
 	GetDB() IDatabase
@@ -0,0 +1,19 @@
+package types
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTokenPayloadContext(t *testing.T) {
+	if GetTokenPayload(context.Background()) != nil {
+		t.Error("expected no payload in unauthorized context")
+	}
+
+	// payload with slice isn't comparable, so it must not be the key itself
+	payload := &TokenPayload{UserID: "bot", IsBot: true, Scopes: []string{"messages:write"}}
+	ctx := WithTokenPayload(context.Background(), payload)
+	if got := GetTokenPayload(ctx); got != payload {
+		t.Errorf("expected payload %+v, got %+v", payload, got)
+	}
+}
@@ -78,10 +78,39 @@ func RollUpAndGetCollections(ctx context.Context, db *mongo.Database, dbName str
 	collections["chats"] = db.Collection("chats")
 	collections["messages"] = db.Collection("messages")
 	collections["tokens"] = db.Collection("tokens")
+	collections["contactRequests"] = db.Collection("contactRequests")
+	collections["handleRedirects"] = db.Collection("handleRedirects")
+	collections["passwordResets"] = db.Collection("passwordResets")
+	collections["emailConfirmations"] = db.Collection("emailConfirmations")
+	collections["loginAttempts"] = db.Collection("loginAttempts")
+	collections["oidcStates"] = db.Collection("oidcStates")
+	collections["apiKeys"] = db.Collection("apiKeys")
+	collections["webhooks"] = db.Collection("webhooks")
+	collections["webhookDeliveries"] = db.Collection("webhookDeliveries")
+	collections["incomingHooks"] = db.Collection("incomingHooks")
+	collections["pollVotes"] = db.Collection("pollVotes")
+	collections["scheduledMessages"] = db.Collection("scheduledMessages")
+	collections["mentionCounters"] = db.Collection("mentionCounters")
+	collections["chatSettings"] = db.Collection("chatSettings")
+
+	// data migrations
+	if err := migrateUserHandles(ctx, db); err != nil {
+		slog.Error("Cannot migrate users to handles", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	if err := migrateUserNamesLower(ctx, db); err != nil {
+		slog.Error("Cannot migrate users to lowercased names", slog.Any("error", err.Error()))
+		return nil, err
+	}
 
 	// indices
+	// name is a display name now, handle is unique instead
+	if err := dropIndexIfExists(ctx, db.Collection("users"), "name_1"); err != nil {
+		slog.Error("Cannot drop unique name index of users collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
 	indexModel0 := mongo.IndexModel{
-		Keys:    bson.D{{Key: "name", Value: 1}},
+		Keys:    bson.D{{Key: "handle", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	}
 	_, err := db.Collection("users").Indexes().CreateOne(ctx, indexModel0)
@@ -118,6 +147,375 @@ func RollUpAndGetCollections(ctx context.Context, db *mongo.Database, dbName str
 		return nil, err
 	}
 
+	messageIndices := db.Collection("messages").Indexes()
+	indexModel3 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "text", Value: "text"}},
+		Options: options.Index().SetName("message_text_search"),
+	}
+	_, err = messageIndices.CreateOne(ctx, indexModel3)
+	if err != nil {
+		slog.Error("Cannot create text index for messages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// directory search matches anchored prefix of lowercased name
+	indexModel4 := mongo.IndexModel{
+		Keys: bson.D{{Key: "nameLower", Value: 1}},
+	}
+	_, err = db.Collection("users").Indexes().CreateOne(ctx, indexModel4)
+	if err != nil {
+		slog.Error("Cannot create name search index for users collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// only one pending request per direction is allowed
+	indexModel5 := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "from", Value: 1},
+			{Key: "to", Value: 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "status", Value: "pending"}}),
+	}
+	_, err = db.Collection("contactRequests").Indexes().CreateOne(ctx, indexModel5)
+	if err != nil {
+		slog.Error("Cannot create unique index for contactRequests collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	indexModel6 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "handle", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = db.Collection("handleRedirects").Indexes().CreateOne(ctx, indexModel6)
+	if err != nil {
+		slog.Error("Cannot create unique index for handleRedirects collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// legacy names were unique, as the name index used to be
+	indexModel7 := mongo.IndexModel{
+		Keys: bson.D{{Key: "legacyName", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "legacyName", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	}
+	_, err = db.Collection("users").Indexes().CreateOne(ctx, indexModel7)
+	if err != nil {
+		slog.Error("Cannot create legacy name index for users collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// email is unique among confirmed ones only: an unconfirmed claim must not lock the owner out
+	indexModel8 := mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "emailVerified", Value: true}}),
+	}
+	_, err = db.Collection("users").Indexes().CreateOne(ctx, indexModel8)
+	if err != nil {
+		slog.Error("Cannot create unique email index for users collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	resetIndices := db.Collection("passwordResets").Indexes()
+	indexModel9 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = resetIndices.CreateOne(ctx, indexModel9)
+	if err != nil {
+		slog.Error("Cannot create unique index for passwordResets collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel10 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = resetIndices.CreateOne(ctx, indexModel10)
+	if err != nil {
+		slog.Error("Cannot create TTL index for passwordResets collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	confirmationIndices := db.Collection("emailConfirmations").Indexes()
+	indexModel11 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = confirmationIndices.CreateOne(ctx, indexModel11)
+	if err != nil {
+		slog.Error("Cannot create unique index for emailConfirmations collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel12 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = confirmationIndices.CreateOne(ctx, indexModel12)
+	if err != nil {
+		slog.Error("Cannot create TTL index for emailConfirmations collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	attemptIndices := db.Collection("loginAttempts").Indexes()
+	indexModel13 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = attemptIndices.CreateOne(ctx, indexModel13)
+	if err != nil {
+		slog.Error("Cannot create unique index for loginAttempts collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel14 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = attemptIndices.CreateOne(ctx, indexModel14)
+	if err != nil {
+		slog.Error("Cannot create TTL index for loginAttempts collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// one external identity belongs to one user only
+	indexModel15 := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "identities.issuer", Value: 1},
+			{Key: "identities.subject", Value: 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "identities.subject", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	}
+	_, err = db.Collection("users").Indexes().CreateOne(ctx, indexModel15)
+	if err != nil {
+		slog.Error("Cannot create unique identities index for users collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	stateIndices := db.Collection("oidcStates").Indexes()
+	indexModel16 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "state", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = stateIndices.CreateOne(ctx, indexModel16)
+	if err != nil {
+		slog.Error("Cannot create unique index for oidcStates collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel17 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = stateIndices.CreateOne(ctx, indexModel17)
+	if err != nil {
+		slog.Error("Cannot create TTL index for oidcStates collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	apiKeyIndices := db.Collection("apiKeys").Indexes()
+	indexModel18 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "keyHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = apiKeyIndices.CreateOne(ctx, indexModel18)
+	if err != nil {
+		slog.Error("Cannot create unique index for apiKeys collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel19 := mongo.IndexModel{
+		Keys: bson.D{{Key: "bot", Value: 1}},
+	}
+	_, err = apiKeyIndices.CreateOne(ctx, indexModel19)
+	if err != nil {
+		slog.Error("Cannot create bot index for apiKeys collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	indexModel20 := mongo.IndexModel{
+		Keys: bson.D{{Key: "chat", Value: 1}, {Key: "events", Value: 1}},
+	}
+	_, err = db.Collection("webhooks").Indexes().CreateOne(ctx, indexModel20)
+	if err != nil {
+		slog.Error("Cannot create chat index for webhooks collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	deliveryIndices := db.Collection("webhookDeliveries").Indexes()
+	// delivery queue
+	indexModel21 := mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	}
+	_, err = deliveryIndices.CreateOne(ctx, indexModel21)
+	if err != nil {
+		slog.Error("Cannot create queue index for webhookDeliveries collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	// delivery log
+	indexModel22 := mongo.IndexModel{
+		Keys: bson.D{{Key: "webhook", Value: 1}, {Key: "createdAt", Value: -1}},
+	}
+	_, err = deliveryIndices.CreateOne(ctx, indexModel22)
+	if err != nil {
+		slog.Error("Cannot create log index for webhookDeliveries collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	// finished deliveries expire, pending ones have no expiresAt
+	indexModel23 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = deliveryIndices.CreateOne(ctx, indexModel23)
+	if err != nil {
+		slog.Error("Cannot create TTL index for webhookDeliveries collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	incomingHookIndices := db.Collection("incomingHooks").Indexes()
+	indexModel24 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = incomingHookIndices.CreateOne(ctx, indexModel24)
+	if err != nil {
+		slog.Error("Cannot create unique index for incomingHooks collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel25 := mongo.IndexModel{
+		Keys: bson.D{{Key: "chat", Value: 1}},
+	}
+	_, err = incomingHookIndices.CreateOne(ctx, indexModel25)
+	if err != nil {
+		slog.Error("Cannot create chat index for incomingHooks collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// pending invites into group chats
+	indexModel26 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "invited", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+	_, err = db.Collection("chats").Indexes().CreateOne(ctx, indexModel26)
+	if err != nil {
+		slog.Error("Cannot create invited index for chats collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	pollVoteIndices := db.Collection("pollVotes").Indexes()
+	indexModel27 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "message", Value: 1}, {Key: "user", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = pollVoteIndices.CreateOne(ctx, indexModel27)
+	if err != nil {
+		slog.Error("Cannot create unique index for pollVotes collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel28 := mongo.IndexModel{
+		Keys: bson.D{{Key: "chat", Value: 1}},
+	}
+	_, err = pollVoteIndices.CreateOne(ctx, indexModel28)
+	if err != nil {
+		slog.Error("Cannot create chat index for pollVotes collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	scheduledIndices := db.Collection("scheduledMessages").Indexes()
+	indexModel29 := mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "sendAt", Value: 1}},
+	}
+	_, err = scheduledIndices.CreateOne(ctx, indexModel29)
+	if err != nil {
+		slog.Error("Cannot create status index for scheduledMessages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel30 := mongo.IndexModel{
+		Keys: bson.D{{Key: "chat", Value: 1}, {Key: "user", Value: 1}, {Key: "sendAt", Value: 1}},
+	}
+	_, err = scheduledIndices.CreateOne(ctx, indexModel30)
+	if err != nil {
+		slog.Error("Cannot create chat index for scheduledMessages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	// disappearing messages, the sweeper job usually gets to them first
+	indexModel31 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = messageIndices.CreateOne(ctx, indexModel31)
+	if err != nil {
+		slog.Error("Cannot create TTL index for messages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel32 := mongo.IndexModel{
+		Keys: bson.D{{Key: "chat", Value: 1}, {Key: "createdAt", Value: -1}},
+	}
+	_, err = messageIndices.CreateOne(ctx, indexModel32)
+	if err != nil {
+		slog.Error("Cannot create chat index for messages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel33 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "payloadExpiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err = db.Collection("webhookDeliveries").Indexes().CreateOne(ctx, indexModel33)
+	if err != nil {
+		slog.Error("Cannot create payload TTL index for webhookDeliveries collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel34 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "lastMessageExpiresAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+	_, err = db.Collection("chats").Indexes().CreateOne(ctx, indexModel34)
+	if err != nil {
+		slog.Error("Cannot create last message expiry index for chats collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	indexModel35 := mongo.IndexModel{
+		Keys: bson.D{{Key: "mentions.user", Value: 1}, {Key: "createdAt", Value: -1}},
+	}
+	_, err = messageIndices.CreateOne(ctx, indexModel35)
+	if err != nil {
+		slog.Error("Cannot create mentions index for messages collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	mentionCounterIndices := db.Collection("mentionCounters").Indexes()
+	indexModel36 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "chat", Value: 1}, {Key: "user", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = mentionCounterIndices.CreateOne(ctx, indexModel36)
+	if err != nil {
+		slog.Error("Cannot create unique index for mentionCounters collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+	indexModel37 := mongo.IndexModel{
+		Keys: bson.D{{Key: "user", Value: 1}, {Key: "updatedAt", Value: -1}},
+	}
+	_, err = mentionCounterIndices.CreateOne(ctx, indexModel37)
+	if err != nil {
+		slog.Error("Cannot create user index for mentionCounters collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
+	indexModel38 := mongo.IndexModel{
+		Keys:    bson.D{{Key: "chat", Value: 1}, {Key: "user", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = db.Collection("chatSettings").Indexes().CreateOne(ctx, indexModel38)
+	if err != nil {
+		slog.Error("Cannot create unique index for chatSettings collection", slog.Any("error", err.Error()))
+		return nil, err
+	}
+
 	slog.Info("MongoDB metadata rolled up successfully")
 	return collections, nil
 }
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+)
+
+// migrateUserHandles derives unique handle out of name for users registered before handles existed
+func migrateUserHandles(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	cursor, err := users.Find(ctx, bson.D{{
+		Key: "$or", Value: []bson.D{
+			{{Key: "handle", Value: bson.D{{Key: "$exists", Value: false}}}},
+			{{Key: "handle", Value: ""}},
+		},
+	}}, options.Find().SetProjection(bson.D{{Key: "name", Value: 1}}).SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("cannot find users without handle: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var legacyUsers []struct {
+		ID   primitive.ObjectID `bson:"_id"`
+		Name string             `bson:"name"`
+	}
+	if err = cursor.All(ctx, &legacyUsers); err != nil {
+		return fmt.Errorf("cannot decode users without handle: %w", err)
+	}
+
+	for _, user := range legacyUsers {
+		base := handlehelper.FromName(user.Name)
+		handle := base
+		for n := 1; ; n++ {
+			count, err := users.CountDocuments(ctx, bson.D{{Key: "handle", Value: handle}})
+			if err != nil {
+				return fmt.Errorf("cannot check handle uniqueness: %w", err)
+			}
+			if count == 0 {
+				break
+			}
+			handle = handlehelper.WithSuffix(base, n)
+		}
+
+		// former name stays usable for login, since the derived handle may differ from it
+		_, err = users.UpdateByID(ctx, user.ID, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "handle", Value: handle},
+			{Key: "legacyName", Value: user.Name},
+		}}})
+		if err != nil {
+			return fmt.Errorf("cannot set handle for user: %w", err)
+		}
+	}
+
+	if len(legacyUsers) > 0 {
+		slog.Info("migrated users to handles", slog.Int("count", len(legacyUsers)))
+	}
+	return nil
+}
+
+// migrateUserNamesLower stores lowercased name used by directory search for users created before it existed
+func migrateUserNamesLower(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	cursor, err := users.Find(ctx, bson.D{{Key: "nameLower", Value: bson.D{{Key: "$exists", Value: false}}}},
+		options.Find().SetProjection(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("cannot find users without lowercased name: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var legacyUsers []struct {
+		ID   primitive.ObjectID `bson:"_id"`
+		Name string             `bson:"name"`
+	}
+	if err = cursor.All(ctx, &legacyUsers); err != nil {
+		return fmt.Errorf("cannot decode users without lowercased name: %w", err)
+	}
+
+	// lowercased in Go rather than via $toLower, which handles ASCII only
+	for _, user := range legacyUsers {
+		_, err = users.UpdateByID(ctx, user.ID, bson.D{{Key: "$set", Value: bson.D{{Key: "nameLower", Value: strings.ToLower(user.Name)}}}})
+		if err != nil {
+			return fmt.Errorf("cannot set lowercased name for user: %w", err)
+		}
+	}
+
+	if len(legacyUsers) > 0 {
+		slog.Info("migrated users to lowercased names", slog.Int("count", len(legacyUsers)))
+	}
+	return nil
+}
+
+// dropIndexIfExists removes obsolete index, ignoring the case it was never there
+func dropIndexIfExists(ctx context.Context, collection *mongo.Collection, name string) error {
+	_, err := collection.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if err != nil && errors.As(err, &cmdErr) && (cmdErr.Code == codeIndexNotFound || cmdErr.Code == codeNamespaceNotFound) {
+		return nil
+	}
+	return err
+}
+
+const (
+	codeNamespaceNotFound int32 = 26
+	codeIndexNotFound     int32 = 27
+)
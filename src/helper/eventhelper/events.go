@@ -0,0 +1,115 @@
+package eventhelper
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event is something connected users should learn about.
+// Delivery is in-process only: users connected to another replica won't get it.
+type Event struct {
+	Type      string    `json:"type"`
+	Payload   any       `json:"payload"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Recipients []primitive.ObjectID `json:"-"`
+	// Chat is set for chat events, webhooks of the chat get them
+	Chat primitive.ObjectID `json:"-"`
+	// ExpiresAt is set for events carrying disappearing messages, nothing keeps them past that
+	ExpiresAt *time.Time `json:"-"`
+}
+
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[primitive.ObjectID]map[chan Event]struct{}
+	listeners   []func(Event)
+}
+
+//nolint:gochecknoglobals // single bus per process
+var bus = &eventBus{
+	subscribers: make(map[primitive.ObjectID]map[chan Event]struct{}),
+}
+
+// Subscribe opens a stream of events for the user; call returned func to close it
+func Subscribe(userID primitive.ObjectID) (<-chan Event, func()) {
+	ch := make(chan Event, SubscriberBufferSize)
+
+	bus.mu.Lock()
+	if bus.subscribers[userID] == nil {
+		bus.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	bus.subscribers[userID][ch] = struct{}{}
+	bus.mu.Unlock()
+
+	return ch, func() {
+		bus.mu.Lock()
+		delete(bus.subscribers[userID], ch)
+		if len(bus.subscribers[userID]) == 0 {
+			delete(bus.subscribers, userID)
+		}
+		bus.mu.Unlock()
+	}
+}
+
+// Listen registers a callback receiving every published event regardless of recipients.
+// Callbacks are invoked synchronously by the publisher, after the event is handed to subscribers.
+func Listen(fn func(Event)) {
+	bus.mu.Lock()
+	bus.listeners = append(bus.listeners, fn)
+	bus.mu.Unlock()
+}
+
+func Publish(event Event) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	bus.mu.RLock()
+	for _, userID := range event.Recipients {
+		for ch := range bus.subscribers[userID] {
+			select {
+			case ch <- event:
+			default:
+				slog.Warn("event dropped for slow subscriber", slog.String("type", event.Type), slog.String("userId", userID.Hex()))
+			}
+		}
+	}
+	listeners := bus.listeners
+	bus.mu.RUnlock()
+
+	// listeners may be slow (e.g. write to DB), don't hold subscribers meanwhile
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+const SubscriberBufferSize int = 32
+
+const (
+	EventUserUpdated = "user.updated"
+	// EventTokensRevoked is internal, it's never sent to clients
+	EventTokensRevoked = "tokens.revoked"
+
+	EventMessageCreated = "message.created"
+	EventMessageDeleted = "message.deleted"
+	EventMemberJoined   = "member.joined"
+	// EventChatInvited goes to the invitee only, he isn't chat member yet
+	EventChatInvited = "chat.invited"
+
+	// EventMessageEphemeral is reply to slash command shown only to its caller
+	EventMessageEphemeral = "message.ephemeral"
+	// EventPollUpdated carries fresh tallies of the poll after vote
+	EventPollUpdated = "poll.updated"
+	// EventPinsUpdated carries pinned messages of the chat after pin or unpin
+	EventPinsUpdated = "pins.updated"
+	// EventNotification is about new message for members who haven't muted the chat or are mentioned
+	EventNotification = "notification"
+)
+
+// ChatEvents are ones webhooks can subscribe to
+//
+//nolint:gochecknoglobals // constant list
+var ChatEvents = []string{EventMessageCreated, EventMessageDeleted, EventMemberJoined}
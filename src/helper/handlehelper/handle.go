@@ -0,0 +1,89 @@
+package handlehelper
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//nolint:gochecknoglobals // compiled once
+var handleRegexp = regexp.MustCompile(`^[a-z0-9_]{3,32}$`)
+
+// Normalize makes handle comparable: no leading @, no surrounding spaces, lower case
+func Normalize(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// IsValid checks already normalized handle
+func IsValid(handle string) bool {
+	return handleRegexp.MatchString(handle)
+}
+
+// Mention is @handle found in text, the range is in runes and includes the @
+type Mention struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// FindMentions finds @handles in text, ones glued to a word (e.g. e-mails) are skipped
+func FindMentions(text string) []Mention {
+	mentions := []Mention{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isHandleRune(runes[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isHandleRune(runes[end]) {
+			end++
+		}
+		if handle := Normalize(string(runes[i+1 : end])); IsValid(handle) {
+			mentions = append(mentions, Mention{Handle: handle, Start: i, End: end})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+func isHandleRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// FromName derives handle candidate out of free-form display name
+func FromName(name string) string {
+	var sb strings.Builder
+	lastUnderscore := true
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+			lastUnderscore = false
+		case !lastUnderscore && r < 128:
+			sb.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+
+	handle := strings.Trim(sb.String(), "_")
+	if len(handle) > MaxLength-SuffixReserve {
+		handle = handle[:MaxLength-SuffixReserve]
+	}
+	if len(handle) < MinLength {
+		handle = DefaultBase + handle
+	}
+	return handle
+}
+
+// WithSuffix makes another candidate when the base one is occupied
+func WithSuffix(base string, n int) string {
+	return base + strconv.Itoa(n)
+}
+
+const (
+	MinLength     int = 3
+	MaxLength     int = 32
+	SuffixReserve int = 6
+
+	DefaultBase = "user"
+)
@@ -0,0 +1,28 @@
+package iphelper
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ClientIP takes peer address, proxy headers are trusted only if TRUST_PROXY_HEADERS=true
+// (i.e. app is reachable only via own load balancer)
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+			return strings.TrimSpace(ip)
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
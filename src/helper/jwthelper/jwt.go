@@ -15,6 +15,8 @@ import (
 
 type jwtCustomClaims struct {
 	types.TokenPayload
+	// Purpose is empty for access tokens
+	Purpose string `json:"purpose,omitempty"`
 	jwt.StandardClaims
 }
 type jwtDataStruct struct {
@@ -42,6 +44,7 @@ func GenerateToken(userID string, userName string) (string, error) {
 			UserID:   userID,
 			UserName: userName,
 		},
+		"",
 		jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Second * time.Duration(int32(JwtServerData.expr))).Unix(),
 			Issuer:    JwtServerData.issuer,
@@ -54,6 +57,37 @@ func GenerateToken(userID string, userName string) (string, error) {
 	return token.SignedString(JwtServerData.secretKey)
 }
 
+// GenerateChallengeToken makes short-lived token proving the password step of 2FA login passed.
+// It's never stored, so it cannot be used as access token
+func GenerateChallengeToken(userID string, userName string) (string, error) {
+	return generatePurposeToken(userID, userName, PurposeTwoFactor, ChallengeTokenLifetime)
+}
+
+// GenerateReauthToken makes short-lived token proving User has just signed in with linked identity,
+// it replaces current password for users without one
+func GenerateReauthToken(userID string, userName string) (string, error) {
+	return generatePurposeToken(userID, userName, PurposeReauth, ReauthTokenLifetime)
+}
+
+func generatePurposeToken(userID string, userName string, purpose string, lifetime time.Duration) (string, error) {
+	claims := &jwtCustomClaims{
+		types.TokenPayload{
+			UserID:   userID,
+			UserName: userName,
+		},
+		purpose,
+		jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(lifetime).Unix(),
+			Issuer:    JwtServerData.issuer,
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(JwtServerData.secretKey)
+}
+
 // func ValidateToken(encodedToken string) (*jwt.Token, error) {
 // 	return jwt.Parse(encodedToken, keyFunc)
 // }
@@ -67,6 +101,18 @@ func keyFunc(token *jwt.Token) (interface{}, error) {
 }
 
 func VerifyToken(encodedToken string) (*types.TokenPayload, error) {
+	return verifyToken(encodedToken, "")
+}
+
+func VerifyChallengeToken(encodedToken string) (*types.TokenPayload, error) {
+	return verifyToken(encodedToken, PurposeTwoFactor)
+}
+
+func VerifyReauthToken(encodedToken string) (*types.TokenPayload, error) {
+	return verifyToken(encodedToken, PurposeReauth)
+}
+
+func verifyToken(encodedToken string, purpose string) (*types.TokenPayload, error) {
 	jwtToken, err := jwt.ParseWithClaims(encodedToken, &jwtCustomClaims{}, keyFunc)
 	if err != nil {
 		var vErr *jwt.ValidationError
@@ -78,7 +124,7 @@ func VerifyToken(encodedToken string) (*types.TokenPayload, error) {
 	}
 
 	payload, ok := jwtToken.Claims.(*jwtCustomClaims)
-	if !ok {
+	if !ok || payload.Purpose != purpose {
 		return nil, cmnerr.ErrInvalidToken
 	}
 
@@ -104,4 +150,11 @@ func getAuthTokenExpr() int {
 	return n
 }
 
-const DefaultAccessTokenLifetimeHrsInSeconds int = 4 * 60 * 60
+const (
+	DefaultAccessTokenLifetimeHrsInSeconds int = 4 * 60 * 60
+
+	PurposeTwoFactor       = "2fa"
+	ChallengeTokenLifetime = 5 * time.Minute
+	PurposeReauth          = "reauth"
+	ReauthTokenLifetime    = 5 * time.Minute
+)
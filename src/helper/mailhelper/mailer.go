@@ -0,0 +1,120 @@
+package mailhelper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// SMTPMailer sends mail via SMTP server (STARTTLS is used if server supports it)
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		host: host,
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(_ context.Context, mail Mail) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, compose(m.from, mail)); err != nil {
+		return fmt.Errorf("cannot send mail via smtp: %w", err)
+	}
+	return nil
+}
+
+// LogMailer appends mail to the file or just logs it; meant for local development & tests
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(_ context.Context, mail Mail) error {
+	if m.path == "" {
+		slog.Info("mail", slog.String("to", mail.To), slog.String("subject", mail.Subject), slog.String("body", mail.Body))
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open mail log file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(compose("", mail), '\n')); err != nil {
+		return fmt.Errorf("cannot write mail log file: %w", err)
+	}
+	return nil
+}
+
+func compose(from string, mail Mail) []byte {
+	var sb strings.Builder
+	if from != "" {
+		sb.WriteString("From: " + from + "\r\n")
+	}
+	sb.WriteString("To: " + mail.To + "\r\n")
+	sb.WriteString("Subject: " + mail.Subject + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	sb.WriteString(mail.Body + "\r\n")
+	return []byte(sb.String())
+}
+
+//nolint:gochecknoglobals // one mailer per process
+var (
+	mailerOnce sync.Once
+	mailer     Mailer
+)
+
+// GetMailer picks implementation by MAIL_DRIVER env var: "smtp" or "log" (default)
+func GetMailer() Mailer {
+	mailerOnce.Do(func() {
+		if os.Getenv("MAIL_DRIVER") == "smtp" {
+			mailer = NewSMTPMailer(
+				os.Getenv("SMTP_HOST"),
+				os.Getenv("SMTP_PORT"),
+				os.Getenv("SMTP_USERNAME"),
+				os.Getenv("SMTP_PASSWORD"),
+				os.Getenv("MAIL_FROM"),
+			)
+			return
+		}
+		mailer = NewLogMailer(os.Getenv("MAIL_LOG_PATH"))
+	})
+	return mailer
+}
@@ -0,0 +1,389 @@
+package oidchelper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Config of OpenID Connect provider, see OIDC_* env vars
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// AllowSignup lets create users on first login
+	AllowSignup bool
+	// LinkByEmail lets attach identity to existing user with the same verified email
+	LinkByEmail bool
+}
+
+func ConfigFromEnv() Config {
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	return Config{
+		Issuer:       strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       scopes,
+		AllowSignup:  os.Getenv("OIDC_ALLOW_SIGNUP") == "true",
+		LinkByEmail:  os.Getenv("OIDC_LINK_BY_EMAIL") == "true",
+	}
+}
+
+func (c Config) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != "" && c.RedirectURL != ""
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Provider talks to the issuer; discovery document & keys are fetched lazily and cached
+type Provider struct {
+	Config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		Config: config,
+		client: client,
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+//nolint:gochecknoglobals // one provider per process keeps caches warm
+var (
+	providerOnce sync.Once
+	provider     *Provider
+)
+
+func GetProvider() *Provider {
+	providerOnce.Do(func() {
+		provider = NewProvider(ConfigFromEnv(), nil)
+	})
+	return provider
+}
+
+// AuthCodeURL builds authorization request of code flow with PKCE (S256)
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("malformed authorization endpoint: %w", err)
+	}
+	query := link.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+type tokenResponse struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Exchange redeems authorization code and returns verified ID token claims
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Claims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.Config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	var res tokenResponse
+	status, err := p.doJSON(req, &res)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || res.IDToken == "" {
+		return nil, errors.Join(ErrExchangeFailed, fmt.Errorf("status %d: %s %s", status, res.Error, res.ErrorDesc))
+	}
+
+	return p.VerifyIDToken(ctx, res.IDToken, nonce)
+}
+
+// Claims are the parts of ID token schatgo cares about
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+}
+
+// Valid is called by jwt parser after signature check
+func (c *Claims) Valid() error {
+	now := time.Now().Unix()
+	if c.ExpiresAt == 0 || now > c.ExpiresAt+int64(ClockSkew.Seconds()) {
+		return ErrInvalidIDToken
+	}
+	if c.IssuedAt > now+int64(ClockSkew.Seconds()) {
+		return ErrInvalidIDToken
+	}
+	return nil
+}
+
+// audience may come as single string or array
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrInvalidIDToken
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.Join(ErrInvalidIDToken, err)
+	}
+
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != d.Issuer || claims.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+	audOK := false
+	for _, aud := range claims.Audience {
+		audOK = audOK || aud == p.Config.ClientID
+	}
+	if !audOK || claims.Nonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	if !p.Config.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	status, err := p.doJSON(req, &d)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return nil, fmt.Errorf("%w: bad discovery document, status %d", ErrProviderUnavailable, status)
+	}
+	// OIDC Discovery 4.3: issuer must be exactly the configured one
+	if strings.TrimSuffix(d.Issuer, "/") != p.Config.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", ErrProviderUnavailable, d.Issuer)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// getKey refetches JWKS when kid is unknown (keys rotation), but not too often
+func (p *Provider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < MinJwksRefreshInterval {
+		return nil, ErrInvalidIDToken
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: jwks status %d", ErrProviderUnavailable, status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		if key, err := rsaKey(k); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrInvalidIDToken
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out any) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, errors.Join(ErrProviderUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, MaxResponseBytes))
+	if err != nil {
+		return 0, errors.Join(ErrProviderUnavailable, err)
+	}
+	if err = json.Unmarshal(data, out); err != nil && res.StatusCode == http.StatusOK {
+		return 0, errors.Join(ErrProviderUnavailable, err)
+	}
+	return res.StatusCode, nil
+}
+
+// RandomToken makes url-safe random value for state, nonce & PKCE verifier
+func RandomToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+const (
+	ClockSkew              = time.Minute
+	MinJwksRefreshInterval = 30 * time.Second
+	MaxResponseBytes       = 1 << 20
+)
+
+var (
+	ErrNotConfigured       = errors.New("oidc login is not configured")
+	ErrProviderUnavailable = errors.New("oidc provider is unavailable")
+	ErrExchangeFailed      = errors.New("cannot exchange authorization code")
+	ErrInvalidIDToken      = errors.New("id token is invalid")
+)
@@ -0,0 +1,114 @@
+package oidchelper_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := testhelper.NewOidcProvider(t)
+	provider := oidchelper.NewProvider(idp.Config(), idp.Server.Client())
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, _ := url.Parse(authURL)
+	query := link.Query()
+	if link.Scheme+"://"+link.Host+link.Path != idp.Server.URL+"/authorize" {
+		t.Errorf("expected discovered authorization endpoint, got %s", authURL)
+	}
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             idp.ClientID,
+		"redirect_uri":          idp.Config().RedirectURL,
+		"scope":                 "openid profile email",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidchelper.CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range expected {
+		if query.Get(key) != value {
+			t.Errorf("expected %s=%q, got %q", key, value, query.Get(key))
+		}
+	}
+	if query.Get("code_challenge") == "verifier" {
+		t.Error("verifier must not be sent in authorization request")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	identity := testhelper.OidcIdentity{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+
+	tests := []struct {
+		name        string
+		verifier    string
+		nonce       string
+		reuseCode   bool
+		expectedErr error
+	}{
+		{name: "valid code, verifier and nonce"},
+		{name: "wrong PKCE verifier", verifier: "stolen", expectedErr: oidchelper.ErrExchangeFailed},
+		{name: "replayed code", reuseCode: true, expectedErr: oidchelper.ErrExchangeFailed},
+		{name: "wrong nonce", nonce: "other", expectedErr: oidchelper.ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			idp := testhelper.NewOidcProvider(t)
+			provider := oidchelper.NewProvider(idp.Config(), idp.Server.Client())
+
+			authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, code := idp.Authorize(t, authURL, identity)
+
+			verifier, nonce := "verifier", "nonce"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if tt.reuseCode {
+				if _, err = provider.Exchange(ctx, code, verifier, nonce); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			claims, err := provider.Exchange(ctx, code, verifier, nonce)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Issuer != idp.Server.URL || claims.Subject != identity.Subject ||
+				claims.Email != identity.Email || !claims.EmailVerified || claims.Name != identity.Name {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestDiscoveryRejectsForeignIssuer(t *testing.T) {
+	idp := testhelper.NewOidcProvider(t)
+	idp.DiscoveryIssuer = "https://evil.example.com"
+	provider := oidchelper.NewProvider(idp.Config(), idp.Server.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if !errors.Is(err, oidchelper.ErrProviderUnavailable) {
+		t.Fatalf("expected %v, got %v", oidchelper.ErrProviderUnavailable, err)
+	}
+}
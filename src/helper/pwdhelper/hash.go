@@ -0,0 +1,233 @@
+package pwdhelper
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashes are self-describing, so parameters may change over time:
+//   - argon2id in PHC string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//   - bcrypt in its own format: $2a$14$<salt+key>
+const (
+	AlgoArgon2id = "argon2id"
+	AlgoBcrypt   = "bcrypt"
+)
+
+// HashParams configure new hashes; old ones are verified by params stored in them
+type HashParams struct {
+	Algo string
+
+	BcryptCost int
+
+	Argon2Memory    uint32 // KiB
+	Argon2Time      uint32
+	Argon2Threads   uint8
+	Argon2SaltLen   uint32
+	Argon2KeyLength uint32
+}
+
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Algo: AlgoArgon2id,
+
+		BcryptCost: DefaultBcryptCost,
+
+		Argon2Memory:    64 * 1024,
+		Argon2Time:      3,
+		Argon2Threads:   2,
+		Argon2SaltLen:   16,
+		Argon2KeyLength: 32,
+	}
+}
+
+//nolint:gochecknoglobals // read from env once
+var (
+	paramsOnce sync.Once
+	params     HashParams
+	paramsErr  error
+)
+
+// InitHashParams must be called on startup, so misconfigured hashing fails fast
+// instead of panicking on the first login
+func InitHashParams() error {
+	paramsOnce.Do(func() {
+		params, paramsErr = ParseHashParams(os.Getenv)
+	})
+	return paramsErr
+}
+
+// GetHashParams returns params read by InitHashParams, or defaults if they are invalid
+func GetHashParams() HashParams {
+	if err := InitHashParams(); err != nil {
+		return DefaultHashParams()
+	}
+	return params
+}
+
+// ParseHashParams reads PASSWORD_HASH_* vars over defaults, unset or empty ones keep defaults
+func ParseHashParams(getenv func(string) string) (HashParams, error) {
+	p := DefaultHashParams()
+
+	switch algo := getenv("PASSWORD_HASH_ALGO"); algo {
+	case "":
+	case AlgoArgon2id, AlgoBcrypt:
+		p.Algo = algo
+	default:
+		return p, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidHashParams, algo)
+	}
+
+	var err error
+	if p.BcryptCost, err = envInt(getenv, "PASSWORD_HASH_BCRYPT_COST", p.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost); err != nil {
+		return p, err
+	}
+	memory, err := envInt(getenv, "PASSWORD_HASH_ARGON2_MEMORY_KIB", int(p.Argon2Memory), MinArgon2Memory, MaxArgon2Memory)
+	if err != nil {
+		return p, err
+	}
+	iterations, err := envInt(getenv, "PASSWORD_HASH_ARGON2_TIME", int(p.Argon2Time), 1, MaxArgon2Time)
+	if err != nil {
+		return p, err
+	}
+	threads, err := envInt(getenv, "PASSWORD_HASH_ARGON2_THREADS", int(p.Argon2Threads), 1, math.MaxUint8)
+	if err != nil {
+		return p, err
+	}
+	p.Argon2Memory, p.Argon2Time, p.Argon2Threads = uint32(memory), uint32(iterations), uint8(threads)
+
+	// argon2 needs at least 8 KiB per thread
+	if p.Argon2Memory < 8*uint32(p.Argon2Threads) {
+		return p, fmt.Errorf("%w: PASSWORD_HASH_ARGON2_MEMORY_KIB must be at least 8 per thread", ErrInvalidHashParams)
+	}
+	return p, nil
+}
+
+func HashPassword(password string) (string, error) {
+	return HashPasswordWith(password, GetHashParams())
+}
+
+func HashPasswordWith(password string, p HashParams) (string, error) {
+	if p.Algo == AlgoBcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(bytes), err
+	}
+
+	salt := make([]byte, p.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, p.Argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgoArgon2id, argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads,
+		b64.EncodeToString(salt), b64.EncodeToString(key),
+	), nil
+}
+
+func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$"+AlgoArgon2id+"$") {
+		stored, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			slog.Error("malformed argon2id hash", slog.Any("error", err))
+			return false
+		}
+		actual := argon2.IDKey([]byte(password), salt, stored.Argon2Time, stored.Argon2Memory, stored.Argon2Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// NeedsRehash tells whether hash was made by other algorithm or weaker parameters than current ones
+func NeedsRehash(hash string) bool {
+	p := GetHashParams()
+
+	if strings.HasPrefix(hash, "$"+AlgoArgon2id+"$") {
+		if p.Algo != AlgoArgon2id {
+			return true
+		}
+		stored, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return stored.Argon2Memory < p.Argon2Memory || stored.Argon2Time < p.Argon2Time ||
+			stored.Argon2Threads != p.Argon2Threads ||
+			uint32(len(salt)) < p.Argon2SaltLen || uint32(len(key)) < p.Argon2KeyLength
+	}
+
+	if p.Algo != AlgoBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < p.BcryptCost
+}
+
+func parseArgon2id(hash string) (HashParams, []byte, []byte, error) {
+	var p HashParams
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Time, &p.Argon2Threads); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	p.Algo = AlgoArgon2id
+	return p, salt, key, nil
+}
+
+// envInt keeps fallback for empty var, but rejects garbage & values out of [minValue, maxValue]
+func envInt(getenv func(string) string, name string, fallback int, minValue int, maxValue int) (int, error) {
+	raw := getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < minValue || n > maxValue {
+		return 0, fmt.Errorf("%w: %s must be an integer in [%d, %d], got %q", ErrInvalidHashParams, name, minValue, maxValue, raw)
+	}
+	return n, nil
+}
+
+//nolint:gochecknoglobals // stateless encoder
+var b64 = base64.RawStdEncoding
+
+const (
+	DefaultBcryptCost int = 14
+
+	MinArgon2Memory = 8               // KiB
+	MaxArgon2Memory = 4 * 1024 * 1024 // 4 GiB in KiB
+	MaxArgon2Time   = 100
+)
+
+var (
+	ErrMalformedHash     = errors.New("malformed password hash")
+	ErrInvalidHashParams = errors.New("invalid password hash params")
+)
@@ -0,0 +1,99 @@
+package pwdhelper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseHashParams(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expectedError error
+		check         func(p HashParams) bool
+	}{
+		{
+			name:  "keeps defaults without env",
+			env:   map[string]string{},
+			check: func(p HashParams) bool { return p == DefaultHashParams() },
+		},
+		{
+			name: "reads valid values",
+			env: map[string]string{
+				"PASSWORD_HASH_ALGO":              AlgoBcrypt,
+				"PASSWORD_HASH_BCRYPT_COST":       "12",
+				"PASSWORD_HASH_ARGON2_MEMORY_KIB": "32768",
+				"PASSWORD_HASH_ARGON2_TIME":       "4",
+				"PASSWORD_HASH_ARGON2_THREADS":    "255",
+			},
+			check: func(p HashParams) bool {
+				return p.Algo == AlgoBcrypt && p.BcryptCost == 12 &&
+					p.Argon2Memory == 32768 && p.Argon2Time == 4 && p.Argon2Threads == 255
+			},
+		},
+		{name: "rejects unknown algorithm", env: map[string]string{"PASSWORD_HASH_ALGO": "md5"}, expectedError: ErrInvalidHashParams},
+		{name: "rejects non-number", env: map[string]string{"PASSWORD_HASH_ARGON2_TIME": "three"}, expectedError: ErrInvalidHashParams},
+		{name: "rejects zero", env: map[string]string{"PASSWORD_HASH_ARGON2_TIME": "0"}, expectedError: ErrInvalidHashParams},
+		{name: "rejects threads overflowing uint8", env: map[string]string{"PASSWORD_HASH_ARGON2_THREADS": "256"}, expectedError: ErrInvalidHashParams},
+		{name: "rejects memory overflowing uint32", env: map[string]string{"PASSWORD_HASH_ARGON2_MEMORY_KIB": "4294967296"}, expectedError: ErrInvalidHashParams},
+		{name: "rejects bcrypt cost over max", env: map[string]string{"PASSWORD_HASH_BCRYPT_COST": "32"}, expectedError: ErrInvalidHashParams},
+		{
+			name:          "rejects memory under 8 KiB per thread",
+			env:           map[string]string{"PASSWORD_HASH_ARGON2_MEMORY_KIB": "64", "PASSWORD_HASH_ARGON2_THREADS": "16"},
+			expectedError: ErrInvalidHashParams,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseHashParams(func(name string) string { return tt.env[name] })
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !tt.check(p) {
+				t.Errorf("unexpected params %+v", p)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current := GetHashParams()
+
+	hash, err := HashPasswordWith("password", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(hash) {
+		t.Error("expected hash made with current params to be kept")
+	}
+
+	weaker := current
+	weaker.Argon2Time = 1
+	weaker.Argon2Memory = 8 * 1024
+	hash, err = HashPasswordWith("password", weaker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPasswordHash("password", hash) {
+		t.Error("expected hash of old params to be verified by params stored in it")
+	}
+	if !NeedsRehash(hash) {
+		t.Error("expected hash with weaker params to be rehashed")
+	}
+
+	legacy := current
+	legacy.Algo, legacy.BcryptCost = AlgoBcrypt, 4
+	hash, err = HashPasswordWith("password", legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPasswordHash("password", hash) || !NeedsRehash(hash) {
+		t.Error("expected legacy bcrypt hash to be verified and rehashed")
+	}
+}
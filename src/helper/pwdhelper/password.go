@@ -2,8 +2,10 @@ package pwdhelper
 
 import (
 	"crypto/rand"
-
-	"golang.org/x/crypto/bcrypt"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"unicode"
 )
 
 const chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -22,17 +24,43 @@ func GenerateRandomString(length int) (string, error) {
 	return string(bytes), nil
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), DefaultHashLength)
-	return string(bytes), err
+// HashToken makes storable digest of random high-entropy token (no need for slow hash here)
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// CheckPasswordPolicy tells whether password is strong enough to be set
+func CheckPasswordPolicy(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	// bcrypt ignores everything after 72 bytes & old hashes may still be bcrypt ones
+	if len(password) > MaxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooSimple
+	}
+
+	return nil
 }
 
 const (
-	DefaultHashLength        int = 14
 	DefaultRawPasswordLength int = 8
+
+	MinPasswordLength int = 8
+	MaxPasswordBytes  int = 72
+)
+
+var (
+	ErrPasswordTooShort  = errors.New("password must be at least 8 characters long")
+	ErrPasswordTooLong   = errors.New("password must not exceed 72 bytes")
+	ErrPasswordTooSimple = errors.New("password must contain both letters and digits")
 )
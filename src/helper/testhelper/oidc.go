@@ -0,0 +1,190 @@
+package testhelper
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
+)
+
+// OidcIdentity is the account user signs in with at mocked provider
+type OidcIdentity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// OidcProvider is mocked identity provider serving discovery, JWKS & token endpoints.
+// Token endpoint checks client credentials, redirect URI and PKCE verifier like a real one
+type OidcProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	// DiscoveryIssuer is put into discovery document instead of server URL if set
+	DiscoveryIssuer string
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]oidcGrant
+}
+
+type oidcGrant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	identity    OidcIdentity
+}
+
+func NewOidcProvider(t testing.TB) *OidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &OidcProvider{
+		ClientID:     "schat",
+		ClientSecret: "secret",
+		key:          key,
+		grants:       make(map[string]oidcGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.serveDiscovery)
+	mux.HandleFunc("/jwks", idp.serveJwks)
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Server.Close)
+
+	return idp
+}
+
+func (idp *OidcProvider) Config() oidchelper.Config {
+	return oidchelper.Config{
+		Issuer:       idp.Server.URL,
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://schat.test/oidc/callback",
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+// Authorize plays user signing in at authorization URL, returns state & code provider redirects back with
+func (idp *OidcProvider) Authorize(t testing.TB, authURL string, identity OidcIdentity) (string, string) {
+	link, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := link.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != idp.ClientID {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request lacks PKCE challenge %s", authURL)
+	}
+
+	code, err := oidchelper.RandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.grants[code] = oidcGrant{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: query.Get("redirect_uri"),
+		identity:    identity,
+	}
+	idp.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+func (idp *OidcProvider) serveDiscovery(w http.ResponseWriter, _ *http.Request) {
+	issuer := idp.DiscoveryIssuer
+	if issuer == "" {
+		issuer = idp.Server.URL
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": idp.Server.URL + "/authorize",
+		"token_endpoint":         idp.Server.URL + "/token",
+		"jwks_uri":               idp.Server.URL + "/jwks",
+	})
+}
+
+func (idp *OidcProvider) serveJwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *OidcProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if r.Method != http.MethodPost || clientID != idp.ClientID || clientSecret != idp.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// codes are single use
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.PostForm.Get("code")]
+	delete(idp.grants, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		oidchelper.CodeChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.Server.URL,
+		"sub":                grant.identity.Subject,
+		"aud":                idp.ClientID,
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              grant.nonce,
+		"email":              grant.identity.Email,
+		"email_verified":     grant.identity.EmailVerified,
+		"name":               grant.identity.Name,
+		"preferred_username": grant.identity.PreferredUsername,
+	})
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "access_token": "idp-access-token"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,54 @@
+package testhelper
+
+import (
+	"context"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+)
+
+// Server serves collections of mocked mtest database, so handlers & services run without real MongoDB
+type Server struct {
+	Router chi.Router
+	mt     *mtest.T
+}
+
+func NewServer(mt *mtest.T) *Server {
+	return &Server{Router: chi.NewRouter(), mt: mt}
+}
+
+func (srv *Server) GetRouter() chi.Router {
+	return srv.Router
+}
+
+func (srv *Server) GetDB() types.IDatabase {
+	return &database{mt: srv.mt}
+}
+
+func (srv *Server) Shutdown() {}
+
+func (srv *Server) Run() <-chan struct{} {
+	return nil
+}
+
+type database struct {
+	mt *mtest.T
+}
+
+func (db *database) GetCollection(name string) *mongo.Collection {
+	return db.mt.DB.Collection(name)
+}
+
+func (db *database) Shutdown() {}
+
+func (db *database) StartTransaction() (mongo.Session, error) {
+	return db.mt.Client.StartSession()
+}
+
+// WithUser authorizes request as if it passed AuthOnly middleware
+func WithUser(ctx context.Context, userID string) context.Context {
+	return types.WithTokenPayload(ctx, &types.TokenPayload{UserID: userID})
+}
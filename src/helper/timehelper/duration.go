@@ -0,0 +1,91 @@
+package timehelper
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseDuration understands human durations like "90s", "15m", "1h30m", "2d" or "1w",
+// i.e. time.ParseDuration units (down to seconds) plus days & weeks
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return 0, ErrInvalidDuration
+	}
+
+	var total time.Duration
+	for value != "" {
+		i := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+		if i <= 0 {
+			return 0, ErrInvalidDuration
+		}
+		amount, err := strconv.Atoi(value[:i])
+		if err != nil {
+			return 0, errors.Join(ErrInvalidDuration, err)
+		}
+		value = value[i:]
+
+		j := strings.IndexFunc(value, unicode.IsDigit)
+		if j < 0 {
+			j = len(value)
+		}
+		unit, found := units[value[:j]]
+		if !found {
+			return 0, ErrInvalidDuration
+		}
+		value = value[j:]
+
+		if amount > int(MaxDuration/unit) {
+			return 0, ErrInvalidDuration
+		}
+		total += time.Duration(amount) * unit
+		if total > MaxDuration {
+			return 0, ErrInvalidDuration
+		}
+	}
+
+	if total <= 0 {
+		return 0, ErrInvalidDuration
+	}
+	return total, nil
+}
+
+// FormatDuration is the reverse of ParseDuration, e.g. "1d12h", seconds are the smallest unit
+func FormatDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return "0s"
+	}
+
+	var sb strings.Builder
+	for _, unit := range []string{"w", "d", "h", "m", "s"} {
+		amount := duration / units[unit]
+		if amount > 0 {
+			sb.WriteString(strconv.FormatInt(int64(amount), 10))
+			sb.WriteString(unit)
+			duration -= amount * units[unit]
+		}
+	}
+	return sb.String()
+}
+
+//nolint:gochecknoglobals // constant table
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": Day,
+	"w": 7 * Day,
+}
+
+const (
+	Day = 24 * time.Hour
+	// MaxDuration keeps sums far from time.Duration overflow
+	MaxDuration = 10 * 365 * Day
+)
+
+var (
+	ErrInvalidDuration = errors.New("invalid duration, use e.g. 30m, 1h30m, 2d or 1w")
+)
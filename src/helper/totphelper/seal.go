@@ -0,0 +1,67 @@
+package totphelper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Seal encrypts secret with AES-GCM under TOTP_ENCRYPTION_KEY,
+// so secrets read out of the database are useless without the key
+func Seal(secret string) (string, error) {
+	aead, err := getCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts secret sealed by Seal
+func Open(sealed string) (string, error) {
+	aead, err := getCipher()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrMalformedSealedSecret
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot open totp secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+func getCipher() (cipher.AEAD, error) {
+	key := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if key == "" {
+		return nil, ErrNoEncryptionKey
+	}
+	// any passphrase is stretched to AES-256 key
+	hashedKey := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(hashedKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	ErrNoEncryptionKey       = errors.New("TOTP_ENCRYPTION_KEY is not set")
+	ErrMalformedSealedSecret = errors.New("malformed sealed totp secret")
+)
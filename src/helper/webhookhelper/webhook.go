@@ -0,0 +1,116 @@
+package webhookhelper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sign computes signature receivers check: hex HMAC-SHA256 over "timestamp.body" keyed by webhook secret.
+// Timestamp is signed too so receivers can reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is the receiver side of Sign
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// ValidateUrl accepts only absolute http(s) URLs
+func ValidateUrl(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return errors.Join(ErrInvalidUrl, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidUrl
+	}
+	return nil
+}
+
+//nolint:gochecknoglobals // single client per process
+var (
+	clientOnce sync.Once
+	client     *http.Client
+)
+
+// GetClient returns client for deliveries. Unless WEBHOOK_ALLOW_PRIVATE=true it refuses to connect
+// to loopback, private & link-local addresses so webhooks cannot be used to reach internal services.
+func GetClient() *http.Client {
+	clientOnce.Do(func() {
+		client = NewClient(os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true")
+	})
+	return client
+}
+
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: DialTimeout}
+	if !allowPrivate {
+		// checked on dial, after DNS resolution, so rebinding doesn't help
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || isInternal(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   DialTimeout,
+		ResponseHeaderTimeout: RequestTimeout,
+		MaxIdleConnsPerHost:   2,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   RequestTimeout,
+		// redirect could lead anywhere, receivers have to answer themselves
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast()
+}
+
+const (
+	// SecretPrefix marks signing secrets (e.g. for secret scanners)
+	SecretPrefix    = "whsec_"
+	SignaturePrefix = "sha256="
+
+	HeaderEvent     = "X-Schatgo-Event"
+	HeaderDelivery  = "X-Schatgo-Delivery"
+	HeaderTimestamp = "X-Schatgo-Timestamp"
+	HeaderSignature = "X-Schatgo-Signature"
+
+	DialTimeout    = 5 * time.Second
+	RequestTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("receiver answered with unexpected status")
+	ErrInvalidUrl       = errors.New("webhook url must be absolute http(s) url")
+	ErrForbiddenAddress = errors.New("webhook url resolves to internal address")
+)
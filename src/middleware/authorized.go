@@ -3,30 +3,40 @@ package middleware
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
 
+	"github.com/MykolaSainiuk/schatgo/src/repo/apikeyrepo"
 	"github.com/MykolaSainiuk/schatgo/src/repo/tokenrepo"
 )
 
 var (
-	trOnce sync.Once
-	repo   *tokenrepo.TokenRepo = nil
+	trOnce     sync.Once
+	repo       *tokenrepo.TokenRepo   = nil
+	apiKeyRepo *apikeyrepo.ApiKeyRepo = nil
 )
 
+// Authorized accepts user access token or bot API key.
+// Bots are let in only if route allows them via AllowBots and the key has the scope
 func Authorized(dbRef types.IDatabase) func(http.Handler) http.Handler {
 	trOnce.Do(func() {
 		repo = tokenrepo.NewTokenRepo(dbRef)
+		apiKeyRepo = apikeyrepo.NewApiKeyRepo(dbRef)
 	})
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if repo == nil {
+			if repo == nil || apiKeyRepo == nil {
 				cmnerr.Reply500(w, ErrTokenRepoNotInitialized)
 				return
 			}
@@ -37,6 +47,11 @@ func Authorized(dbRef types.IDatabase) func(http.Handler) http.Handler {
 				return
 			}
 
+			if strings.HasPrefix(accessToken, model.ApiKeyPrefix) {
+				authorizeBot(w, r, next, accessToken)
+				return
+			}
+
 			var payload *types.TokenPayload
 			var err error
 			if payload, err = jwthelper.VerifyToken(accessToken); err != nil {
@@ -51,13 +66,54 @@ func Authorized(dbRef types.IDatabase) func(http.Handler) http.Handler {
 			}
 
 			// r.Header.Set("UserId", payload.UserID)
-			ctx := context.WithValue(r.Context(), types.TokenPayload{}, payload)
+			ctx := types.WithTokenPayload(r.Context(), payload)
+			ctx = context.WithValue(ctx, types.RawAccessToken{}, accessToken)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func authorizeBot(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	apiKey, err := apiKeyRepo.GetActiveKey(r.Context(), pwdhelper.HashToken(key))
+	if err != nil {
+		if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			slog.Error("cannot check api key", slog.Any("error", err))
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	scope, _ := r.Context().Value(types.BotScope{}).(string)
+	if scope == "" || !slices.Contains(apiKey.Scopes, scope) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if err = apiKeyRepo.TouchKey(r.Context(), apiKey.ID, ApiKeyTouchInterval); err != nil {
+		slog.Error("cannot record api key usage", slog.Any("error", err))
+	}
+
+	payload := &types.TokenPayload{
+		UserID: apiKey.Bot.Hex(),
+		IsBot:  true,
+		Scopes: apiKey.Scopes,
+	}
+	ctx := types.WithTokenPayload(r.Context(), payload)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// AllowBots lets bot API keys having the scope through Authorized, so it goes before it
+func AllowBots(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), types.BotScope{}, scope)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func getAuthHeader(r *http.Request) string {
 	accessToken := r.Header.Get("Authorization")
 	if accessToken == "" {
@@ -71,6 +127,8 @@ func getAuthHeader(r *http.Request) string {
 	return ""
 }
 
+const ApiKeyTouchInterval = time.Minute
+
 var (
 	ErrTokenRepoNotInitialized = errors.New("toke repo not initialized")
 )
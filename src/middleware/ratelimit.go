@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/httpexp"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/iphelper"
+)
+
+// RateLimitConfig allows Limit requests per Period with bursts up to Limit (token bucket).
+// Name keeps buckets of different routes apart and lets override the limit by
+// RATE_LIMIT_<NAME>="<limit>/<period>" env var, e.g. RATE_LIMIT_MESSAGES=60/1m.
+// Key picks the bucket of request, by default it's user ID or client IP
+type RateLimitConfig struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Key    func(r *http.Request) string
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type limiter struct {
+	mu      sync.Mutex
+	config  RateLimitConfig
+	buckets map[string]*bucket
+}
+
+// RateLimit keys buckets by user ID if request is authorized (so put it after Authorized) or by client IP otherwise.
+// Buckets live in memory, so every replica limits on its own
+func RateLimit(config RateLimitConfig) func(http.Handler) http.Handler {
+	config = withEnvOverride(config)
+	if config.Key == nil {
+		config.Key = rateLimitKey
+	}
+	l := &limiter{
+		config:  config,
+		buckets: make(map[string]*bucket),
+	}
+	go l.sweep()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			remaining, reset, ok := l.take(config.Key(r), time.Now())
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(config.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", config.Limit, int(config.Period.Seconds())))
+
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(reset))
+				httpexp.From(ErrRateLimited, MsgRateLimited, http.StatusTooManyRequests).Reply(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if payload := types.GetTokenPayload(r.Context()); payload != nil {
+		return "user:" + payload.UserID
+	}
+	return "ip:" + iphelper.ClientIP(r)
+}
+
+// take consumes a token if any; reset is seconds till the next token if refused or till full bucket otherwise
+func (l *limiter) take(key string, now time.Time) (int, int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.config.Limit)
+	perSecond := capacity / l.config.Period.Seconds()
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*perSecond)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return 0, int(math.Ceil((1 - b.tokens) / perSecond)), false
+	}
+
+	b.tokens--
+	return int(b.tokens), int(math.Ceil((capacity - b.tokens) / perSecond)), true
+}
+
+// sweep drops buckets refilled completely, they are equal to absent ones
+func (l *limiter) sweep() {
+	ticker := time.NewTicker(l.config.Period)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if now.Sub(b.updatedAt) >= l.config.Period {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func withEnvOverride(config RateLimitConfig) RateLimitConfig {
+	envName := "RATE_LIMIT_" + strings.ToUpper(config.Name)
+	value := os.Getenv(envName)
+	if value == "" {
+		return config
+	}
+
+	rawLimit, rawPeriod, found := strings.Cut(value, "/")
+	limit, err := strconv.Atoi(rawLimit)
+	if !found || err != nil || limit <= 0 {
+		slog.Warn("malformed rate limit, default is used", slog.String("env", envName), slog.String("value", value))
+		return config
+	}
+	period, err := time.ParseDuration(rawPeriod)
+	if err != nil || period <= 0 {
+		slog.Warn("malformed rate limit, default is used", slog.String("env", envName), slog.String("value", value))
+		return config
+	}
+
+	config.Limit = limit
+	config.Period = period
+	return config
+}
+
+const MsgRateLimited = "too many requests, slow down"
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ApiKey is long-lived credential of bot, only its hash is stored
+type ApiKey struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Bot     primitive.ObjectID `json:"bot" bson:"bot"`
+	Owner   primitive.ObjectID `json:"owner" bson:"owner"`
+	Name    string             `json:"name" bson:"name"`
+	Prefix  string             `json:"prefix" bson:"prefix"`
+	KeyHash string             `json:"-" bson:"keyHash"`
+	Scopes  []string           `json:"scopes" bson:"scopes"`
+
+	LastUsedAt *time.Time `json:"lastUsedAt" bson:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt" bson:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+// ApiKeyPrefix tells API keys from JWT access tokens in Authorization header
+const ApiKeyPrefix = "sck_"
+
+const (
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+	ScopeChatsRead     = "chats:read"
+)
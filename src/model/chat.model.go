@@ -9,11 +9,27 @@ import (
 type Chat struct {
 	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	Name    string             `json:"name" bson:"name"`
-	Muted   bool               `json:"muted" bson:"muted"`
 	IconUri string             `json:"iconUri" bson:"iconUri"`
+	// Description is shown in chat info
+	Description string `json:"description" bson:"description,omitempty"`
+	// Muted isn't stored, it tells if the viewer has muted the chat in the viewer's ChatSettings
+	Muted bool `json:"muted" bson:"-"`
+	// RetentionSeconds makes new messages disappear after that long, 0 keeps them
+	RetentionSeconds int64 `json:"retentionSeconds,omitempty" bson:"retentionSeconds,omitempty"`
 
+	// Group chat grows by invites, direct chat stays between its two users
+	Group bool `json:"group" bson:"group,omitempty"`
+
+	// Creator administers the chat, in direct chat the other user does as well
+	Creator     primitive.ObjectID   `json:"creator" bson:"creator,omitempty"`
 	Users       []primitive.ObjectID `json:"users" bson:"users"`
 	LastMessage primitive.ObjectID   `json:"lastMessage" bson:"lastMessage"`
+	// LastMessageExpiresAt is when disappearing LastMessage is gone, so the chat needs another one
+	LastMessageExpiresAt *time.Time `json:"-" bson:"lastMessageExpiresAt,omitempty"`
+	// Invited users join the group once they accept
+	Invited []primitive.ObjectID `json:"-" bson:"invited,omitempty"`
+	// PinnedMessages are shown above history, the latest pin first
+	PinnedMessages []primitive.ObjectID `json:"pinnedMessages" bson:"pinnedMessages,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
@@ -24,4 +40,6 @@ type ChatPopulated struct {
 
 	Users       []*User  `json:"users" bson:"users"`
 	LastMessage *Message `json:"lastMessage" bson:"lastMessage"`
+	// Settings of the viewer, nil if the viewer has never changed them
+	Settings *ChatSettings `json:"settings" bson:"-"`
 }
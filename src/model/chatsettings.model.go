@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatSettings are preferences of one member for one chat, other members aren't affected
+type ChatSettings struct {
+	ID   primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Chat primitive.ObjectID `json:"chat" bson:"chat"`
+	User primitive.ObjectID `json:"user" bson:"user"`
+
+	MutedUntil *time.Time `json:"mutedUntil" bson:"mutedUntil"`
+	// Pinned chats go on top of the chat list
+	Pinned bool `json:"pinned" bson:"pinned"`
+	// Archived chats are hidden from the chat list unless asked for
+	Archived bool `json:"archived" bson:"archived"`
+	// NotificationLevel is one of NotifyAll, NotifyMentions, NotifyNone, empty means NotifyAll
+	NotificationLevel string `json:"notificationLevel" bson:"notificationLevel"`
+
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+// IsMuted tells if the user has muted the chat, mentions notify the user anyway
+func (settings *ChatSettings) IsMuted(now time.Time) bool {
+	return settings != nil && settings.MutedUntil != nil && settings.MutedUntil.After(now)
+}
+
+// Notifies tells if the user wants to be notified about new message of the chat
+func (settings *ChatSettings) Notifies(now time.Time, mentioned bool) bool {
+	if settings == nil {
+		return true
+	}
+	switch settings.NotificationLevel {
+	case NotifyNone:
+		return false
+	case NotifyMentions:
+		return mentioned
+	}
+	return mentioned || !settings.IsMuted(now)
+}
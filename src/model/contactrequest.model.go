@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ContactRequest struct {
+	ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	From   primitive.ObjectID `json:"from" bson:"from"`
+	To     primitive.ObjectID `json:"to" bson:"to"`
+	Status string             `json:"status" bson:"status"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+type ContactRequestPopulated struct {
+	*ContactRequest
+
+	From *User `json:"from" bson:"from"`
+	To   *User `json:"to" bson:"to"`
+}
+
+const (
+	ContactRequestStatusPending   = "pending"
+	ContactRequestStatusAccepted  = "accepted"
+	ContactRequestStatusDeclined  = "declined"
+	ContactRequestStatusCancelled = "cancelled"
+)
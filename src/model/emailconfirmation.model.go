@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EmailConfirmation proves the user owns Email, it's valid only while the user still has that email
+type EmailConfirmation struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	User      primitive.ObjectID `json:"user" bson:"user"`
+	Email     string             `json:"email" bson:"email"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt" bson:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ForwardedFrom attributes forwarded message to the original one, forwarding it further keeps the origin
+type ForwardedFrom struct {
+	Message primitive.ObjectID `json:"message" bson:"message"`
+	User    primitive.ObjectID `json:"user" bson:"user"`
+	// Chat is kept only if all members of the destination chat can see it
+	Chat *primitive.ObjectID `json:"chat,omitempty" bson:"chat,omitempty"`
+	// Hook is set if the original was posted via incoming hook
+	Hook *MessageHook `json:"hook,omitempty" bson:"hook,omitempty"`
+	Bot  bool         `json:"bot,omitempty" bson:"bot,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IncomingHook lets anyone knowing its secret URL post into the chat on behalf of the creator,
+// only hash of the token is stored
+type IncomingHook struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Chat      primitive.ObjectID `json:"chat" bson:"chat"`
+	Creator   primitive.ObjectID `json:"creator" bson:"creator"`
+	Name      string             `json:"name" bson:"name"`
+	AvatarUri string             `json:"avatarUri" bson:"avatarUri"`
+	Prefix    string             `json:"prefix" bson:"prefix"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+
+	LastUsedAt *time.Time `json:"lastUsedAt" bson:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt" bson:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+// MessageHook is display identity of incoming hook the message was posted through
+type MessageHook struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Name      string             `json:"name" bson:"name"`
+	AvatarUri string             `json:"avatarUri,omitempty" bson:"avatarUri,omitempty"`
+}
+
+// IncomingHookTokenPrefix makes hook tokens recognizable (e.g. by secret scanners)
+const IncomingHookTokenPrefix = "sih_"
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts consecutive failed logins per key (account from client IP, or client IP)
+type LoginAttempt struct {
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Key      string             `json:"key" bson:"key"`
+	Failures int                `json:"failures" bson:"failures"`
+
+	LockedUntil   time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	LastFailureAt time.Time `json:"lastFailureAt" bson:"lastFailureAt"`
+	// ExpiresAt makes counter forgotten after quiet period
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+const (
+	LoginAttemptKeyAccount = "account:"
+	LoginAttemptKeyIP      = "ip:"
+)
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MentionCounter counts messages mentioning the user in the chat since the user has read mentions there
+type MentionCounter struct {
+	ID   primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Chat primitive.ObjectID `json:"chat" bson:"chat"`
+	User primitive.ObjectID `json:"user" bson:"user"`
+
+	UnreadMentions int `json:"unreadMentions" bson:"unreadMentions"`
+
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
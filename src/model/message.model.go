@@ -14,6 +14,18 @@ type Message struct {
 	Sent     bool `json:"sent" bson:"sent"`
 	Received bool `json:"received" bson:"received"`
 	System   bool `json:"system" bson:"system"`
+	// Bot marks messages posted by bot via API key
+	Bot bool `json:"bot,omitempty" bson:"bot,omitempty"`
+	// Hook is set for messages posted via incoming hook, it's shown instead of the user
+	Hook *MessageHook `json:"hook,omitempty" bson:"hook,omitempty"`
+	// Poll makes the message a poll, its text is the question
+	Poll *Poll `json:"poll,omitempty" bson:"poll,omitempty"`
+	// ForwardedFrom is set for copies of messages forwarded from another chat
+	ForwardedFrom *ForwardedFrom `json:"forwardedFrom,omitempty" bson:"forwardedFrom,omitempty"`
+	// Mentions are @handles of chat members found in the text when it was sent
+	Mentions []Mention `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	// ExpiresAt is set in chats with retention timer, the message is deleted then
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
 
 	User primitive.ObjectID `json:"user" bson:"user"`
 	Chat primitive.ObjectID `json:"chat" bson:"chat"`
@@ -26,4 +38,26 @@ type MessagePopulated struct {
 	*Message
 
 	User *User `json:"user" bson:"user"`
-}
\ No newline at end of file
+	// PollResults are tallies of the poll as the viewer sees them
+	PollResults *PollResults `json:"pollResults,omitempty" bson:"-"`
+}
+
+type MessageSearchHit struct {
+	*MessagePopulated
+
+	Score      float64     `json:"score"`
+	Highlights []TextRange `json:"highlights"`
+}
+
+// TextRange points to a highlighted part of message text (in runes)
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Mention is @handle of the user at rune range of the text
+type Mention struct {
+	User  primitive.ObjectID `json:"user" bson:"user"`
+	Start int                `json:"start" bson:"start"`
+	End   int                `json:"end" bson:"end"`
+}
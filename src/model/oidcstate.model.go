@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OidcState keeps secrets of started OIDC authorization until the callback
+type OidcState struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	State        string             `json:"-" bson:"state"`
+	Nonce        string             `json:"-" bson:"nonce"`
+	CodeVerifier string             `json:"-" bson:"codeVerifier"`
+	// LinkUser is set if logged in user links identity to his account
+	LinkUser *primitive.ObjectID `json:"-" bson:"linkUser,omitempty"`
+	// Reauth means LinkUser re-authenticates by already linked identity instead
+	Reauth bool `json:"-" bson:"reauth,omitempty"`
+
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasswordReset struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	User      primitive.ObjectID `json:"user" bson:"user"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt" bson:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Poll turns message into a vote, the question is the message text
+type Poll struct {
+	Options        []PollOption `json:"options" bson:"options"`
+	MultipleChoice bool         `json:"multipleChoice" bson:"multipleChoice"`
+	// Anonymous polls never reveal who voted for what
+	Anonymous bool       `json:"anonymous" bson:"anonymous"`
+	ClosesAt  *time.Time `json:"closesAt" bson:"closesAt"`
+}
+
+type PollOption struct {
+	ID   int    `json:"id" bson:"id"`
+	Text string `json:"text" bson:"text"`
+}
+
+func (poll *Poll) IsClosed(now time.Time) bool {
+	return poll.ClosesAt != nil && !poll.ClosesAt.After(now)
+}
+
+// PollVote is current choice of one user, changing the vote replaces it
+type PollVote struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Message primitive.ObjectID `json:"message" bson:"message"`
+	Chat    primitive.ObjectID `json:"chat" bson:"chat"`
+	User    primitive.ObjectID `json:"user" bson:"user"`
+	Options []int              `json:"options" bson:"options"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PollResults are aggregated votes of the poll
+type PollResults struct {
+	Message primitive.ObjectID `json:"message"`
+	Tallies []PollTally        `json:"tallies"`
+	// Voters is number of users who voted
+	Voters int  `json:"voters"`
+	Closed bool `json:"closed"`
+	// MyVotes are options the viewer has chosen
+	MyVotes []int `json:"myVotes,omitempty"`
+}
+
+type PollTally struct {
+	Option int `json:"option"`
+	Votes  int `json:"votes"`
+	// Voters are listed for open polls only
+	Voters []primitive.ObjectID `json:"voters,omitempty"`
+}
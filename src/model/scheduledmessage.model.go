@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScheduledMessage waits to be posted into the chat at SendAt, the posted message gets the same ID
+type ScheduledMessage struct {
+	ID    primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Chat  primitive.ObjectID `json:"chat" bson:"chat"`
+	User  primitive.ObjectID `json:"user" bson:"user"`
+	Text  string             `json:"text" bson:"text"`
+	Image string             `json:"image" bson:"image"`
+	Bot   bool               `json:"bot,omitempty" bson:"bot,omitempty"`
+
+	SendAt      time.Time `json:"sendAt" bson:"sendAt"`
+	Status      string    `json:"status" bson:"status"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	LockedUntil time.Time `json:"-" bson:"lockedUntil"`
+	LastError   string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	ScheduledStatusPending = "pending"
+	ScheduledStatusSending = "sending"
+	// ScheduledStatusFailed is kept for the author to see, e.g. after leaving the chat
+	ScheduledStatusFailed = "failed"
+)
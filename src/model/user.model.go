@@ -8,10 +8,36 @@ import (
 
 type User struct {
 	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Handle    string             `json:"handle,omitempty" bson:"handle"`
 	Name      string             `json:"name,omitempty" bson:"name"`
+	NameLower string             `json:"-" bson:"nameLower"`
 	AvatarUri string             `json:"avatarUri,omitempty" bson:"avatarUri"`
+	Email     string             `json:"-" bson:"email,omitempty"`
+	// EmailVerified is set once the user has followed the link mailed to Email
+	EmailVerified bool `json:"-" bson:"emailVerified,omitempty"`
 
-	Hash string `json:"-" bson:"hash"`
+	HandleChangedAt time.Time `json:"-" bson:"handleChangedAt"`
+	// LegacyName is the login of user registered before handles existed, still accepted by login
+	LegacyName string `json:"-" bson:"legacyName,omitempty"`
+
+	Bio        string `json:"bio,omitempty" bson:"bio"`
+	StatusText string `json:"statusText,omitempty" bson:"statusText"`
+
+	Hash      string     `json:"-" bson:"hash"`
+	TwoFactor *TwoFactor `json:"-" bson:"twoFactor,omitempty"`
+
+	// Identities are accounts of external OIDC providers the user signs in with
+	Identities []ExternalIdentity `json:"-" bson:"identities,omitempty"`
+
+	// IsBot users act via API keys only and belong to Owner
+	IsBot bool                `json:"isBot,omitempty" bson:"isBot,omitempty"`
+	Owner *primitive.ObjectID `json:"owner,omitempty" bson:"owner,omitempty"`
+	// BotCommands are slash commands the bot handles in chats it's member of
+	BotCommands *BotCommands `json:"-" bson:"botCommands,omitempty"`
+
+	// Discoverable allows others to find user via directory search
+	Discoverable bool                 `json:"discoverable" bson:"discoverable"`
+	Blocked      []primitive.ObjectID `json:"-" bson:"blocked"`
 
 	Contacts []primitive.ObjectID `json:"contacts" bson:"contacts"`
 	Chats    []primitive.ObjectID `json:"chats" bson:"chats"`
@@ -23,6 +49,53 @@ type User struct {
 type UserPopulated struct {
 	*User
 
+	Email         string `json:"email,omitempty" bson:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified" bson:"emailVerified,omitempty"`
+
 	Contacts []*User `json:"contacts" bson:"contacts"`
 	Chats    []*Chat `json:"chats" bson:"chats"`
 }
+
+// TwoFactor is TOTP setup of the user, it's pending until first code is verified
+type TwoFactor struct {
+	// Secret is sealed by totphelper.Seal
+	Secret  string `bson:"secret"`
+	Enabled bool   `bson:"enabled"`
+	// RecoveryCodes are hashed, each one can be used once instead of TOTP code
+	RecoveryCodes []string `bson:"recoveryCodes"`
+	// LastUsedStep prevents the same code from being accepted twice
+	LastUsedStep int64 `bson:"lastUsedStep"`
+
+	EnabledAt *time.Time `bson:"enabledAt"`
+}
+
+func (user *User) HasTwoFactor() bool {
+	return user.TwoFactor != nil && user.TwoFactor.Enabled
+}
+
+// BotCommands are dispatched to CallbackUrl as signed POST requests, the bot answers with reply
+type BotCommands struct {
+	CallbackUrl string       `json:"callbackUrl" bson:"callbackUrl"`
+	Secret      string       `json:"-" bson:"secret"`
+	Commands    []BotCommand `json:"commands" bson:"commands"`
+}
+
+type BotCommand struct {
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+}
+
+type ExternalIdentity struct {
+	Issuer   string    `json:"issuer" bson:"issuer"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+// HandleRedirect keeps former handle of the user pointing to him
+type HandleRedirect struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Handle    string             `json:"handle" bson:"handle"`
+	User      primitive.ObjectID `json:"user" bson:"user"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
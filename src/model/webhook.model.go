@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook subscribes external URL to events of the chat
+type Webhook struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Chat    primitive.ObjectID `json:"chat" bson:"chat"`
+	Creator primitive.ObjectID `json:"creator" bson:"creator"`
+	Url     string             `json:"url" bson:"url"`
+	// Secret signs payloads, it's shown only on creation
+	Secret string   `json:"-" bson:"secret"`
+	Events []string `json:"events" bson:"events"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WebhookDelivery is one event to deliver to one webhook, it's kept as delivery log
+type WebhookDelivery struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Webhook primitive.ObjectID `json:"webhook" bson:"webhook"`
+	Chat    primitive.ObjectID `json:"chat" bson:"chat"`
+	Event   string             `json:"event" bson:"event"`
+	Payload string             `json:"payload" bson:"payload"`
+
+	Status         string    `json:"status" bson:"status"`
+	Attempts       int       `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LockedUntil    time.Time `json:"-" bson:"lockedUntil"`
+	LastStatusCode int       `json:"lastStatusCode,omitempty" bson:"lastStatusCode,omitempty"`
+	LastError      string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+
+	DeliveredAt *time.Time `json:"deliveredAt" bson:"deliveredAt"`
+	// ExpiresAt is set once delivery succeeds or dies, to remove it DeliveryLogRetention later
+	ExpiresAt *time.Time `json:"-" bson:"expiresAt,omitempty"`
+	// PayloadExpiresAt is when the disappearing message the payload carries is gone, the delivery is removed then
+	PayloadExpiresAt *time.Time `json:"-" bson:"payloadExpiresAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusDelivering = "delivering"
+	DeliveryStatusSucceeded  = "succeeded"
+	// DeliveryStatusDead is dead-letter state after all retries failed
+	DeliveryStatusDead = "dead"
+
+	// DeliveryLogRetention is how long finished deliveries (and payloads they carry) are kept
+	DeliveryLogRetention = 7 * 24 * time.Hour
+)
@@ -0,0 +1,111 @@
+package apikeyrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type ApiKeyRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewApiKeyRepo(db types.IDatabase) *ApiKeyRepo {
+	name := "apiKeys"
+	return &ApiKeyRepo{
+		name:       "apiKeys",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *ApiKeyRepo) SaveApiKey(ctx context.Context, data *model.ApiKey) (primitive.ObjectID, error) {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("cannot save api key into apiKeys collection: %w", err)
+	}
+
+	slog.Debug("saved api key", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return r.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetActiveKey finds not revoked key by hash
+func (repo *ApiKeyRepo) GetActiveKey(ctx context.Context, keyHash string) (*model.ApiKey, error) {
+	var apiKey *model.ApiKey
+	err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "keyHash", Value: keyHash},
+		{Key: "revokedAt", Value: nil},
+	}).Decode(&apiKey)
+	if err != nil || apiKey == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || apiKey == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve api key from apiKeys collection: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+func (repo *ApiKeyRepo) GetBotKeys(ctx context.Context, botID primitive.ObjectID) ([]model.ApiKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "bot", Value: botID}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve api keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	keys := make([]model.ApiKey, 0)
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("cannot decode api keys from cursor: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (repo *ApiKeyRepo) RevokeKey(ctx context.Context, id primitive.ObjectID, botID primitive.ObjectID) error {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "bot", Value: botID},
+		{Key: "revokedAt", Value: nil},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "revokedAt", Value: time.Now()}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot revoke api key: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+// TouchKey records usage at most once per interval not to write on every request
+func (repo *ApiKeyRepo) TouchKey(ctx context.Context, id primitive.ObjectID, interval time.Duration) error {
+	now := time.Now()
+	_, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "lastUsedAt", Value: nil}},
+			bson.D{{Key: "lastUsedAt", Value: bson.D{{Key: "$lt", Value: now.Add(-interval)}}}},
+		}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "lastUsedAt", Value: now}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot update api key usage: %w", err)
+	}
+	return nil
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
@@ -31,12 +33,16 @@ func NewChatRepo(db types.IDatabase) *ChatRepo {
 
 func (repo *ChatRepo) GetExistingChat(ctx context.Context, userId primitive.ObjectID, anotherUserId primitive.ObjectID) (*model.Chat, error) {
 	var chat *model.Chat
-	if err := repo.collection.FindOne(ctx, bson.D{{
-		Key: "$or",
-		Value: []bson.D{
-			{{Key: "users", Value: []primitive.ObjectID{userId, anotherUserId}}},
-			{{Key: "users", Value: []primitive.ObjectID{anotherUserId, userId}}},
-		}},
+	if err := repo.collection.FindOne(ctx, bson.D{
+		{
+			Key: "$or",
+			Value: []bson.D{
+				{{Key: "users", Value: []primitive.ObjectID{userId, anotherUserId}}},
+				{{Key: "users", Value: []primitive.ObjectID{anotherUserId, userId}}},
+			},
+		},
+		// group of the two is no direct chat of theirs
+		{Key: "group", Value: bson.D{{Key: "$ne", Value: true}}},
 	}).Decode(&chat); err != nil || chat == nil {
 		if errors.Is(err, mongo.ErrNoDocuments) || chat == nil {
 			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
@@ -69,7 +75,13 @@ func (repo *ChatRepo) GetChatByID(ctx context.Context, id primitive.ObjectID) (*
 	return chat, nil
 }
 
-func (repo *ChatRepo) GetChatsByUserID(ctx context.Context, id string, params ...any) ([]model.ChatPopulated, error) {
+// ChatsFilter narrows chat list down, nil Archived lists both archived and not archived chats
+type ChatsFilter struct {
+	Archived *bool
+}
+
+// GetChatsByUserID lists chats of the user with the user's settings joined, pinned chats go first
+func (repo *ChatRepo) GetChatsByUserID(ctx context.Context, id string, pgParam types.PaginationParams, filter ChatsFilter) ([]model.ChatPopulated, error) {
 	_id, _ := primitive.ObjectIDFromHex(id)
 
 	match := bson.D{{Key: "$match", Value: bson.D{{
@@ -91,12 +103,45 @@ func (repo *ChatRepo) GetChatsByUserID(ctx context.Context, id string, params ..
 		{Key: "as", Value: "lastMessage"},
 	}
 	lookup2 := bson.D{{Key: "$lookup", Value: ls2}}
-	// {Key: "preserveNullAndEmptyArrays", Value: true}
-	unwind2 := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$lastMessage"}}}}
+	// chats without messages (e.g. all disappeared) are listed too
+	unwind2 := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$lastMessage"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
 
-	pipelineStages := mongo.Pipeline{match, lookup1, lookup2, unwind2}
+	// only settings of the viewer are joined
+	ls3 := bson.D{
+		{Key: "from", Value: "chatSettings"},
+		{Key: "let", Value: bson.D{{Key: "chatId", Value: "$_id"}}},
+		{Key: "pipeline", Value: mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{
+				{Key: "user", Value: _id},
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$chat", "$$chatId"}}}},
+			}}},
+		}},
+		{Key: "as", Value: "settings"},
+	}
+	lookup3 := bson.D{{Key: "$lookup", Value: ls3}}
+	setSettings := bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "settings", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{"$settings", 0}}}},
+	}}}
+
+	pipelineStages := mongo.Pipeline{match, lookup1, lookup2, unwind2, lookup3, setSettings}
+
+	if filter.Archived != nil {
+		archived := bson.D{{Key: "$eq", Value: true}}
+		if !*filter.Archived {
+			archived = bson.D{{Key: "$ne", Value: true}}
+		}
+		pipelineStages = append(pipelineStages, bson.D{{Key: "$match", Value: bson.D{{Key: "settings.archived", Value: archived}}}})
+	}
+
+	sort := bson.D{{Key: "$sort", Value: bson.D{
+		{Key: "settings.pinned", Value: -1},
+		{Key: "_id", Value: 1},
+	}}}
+	pipelineStages = append(pipelineStages, sort)
 
-	pgParam := params[0].(types.PaginationParams)
 	if pgParam.Limit != 0 {
 		limit := bson.D{{Key: "$limit", Value: pgParam.Limit}}
 		pipelineStages = append(pipelineStages, limit)
@@ -134,30 +179,230 @@ func (repo *ChatRepo) GetChatsByUserID(ctx context.Context, id string, params ..
 			}
 		}
 
-		rawLM := chat["lastMessage"]
+		// lastMessage is missing for chats without messages
+		rawLM, _ := chat["lastMessage"].(primitive.D)
 
 		chatsPopulated = append(chatsPopulated, model.ChatPopulated{
 			Chat:        repohelper.RawDocToChatModel(chat),
 			Users:       users,
-			LastMessage: repohelper.RawPlainDocToMessageModel(rawLM.(primitive.D).Map()),
+			LastMessage: repohelper.RawPlainDocToMessageModel(rawLM.Map()),
+			Settings:    repohelper.RawDocToChatSettingsModel(chat["settings"]),
 		})
 	}
 
 	return chatsPopulated, nil
 }
 
-func (repo *ChatRepo) SetLastMessage(ctx context.Context, chatID, messageID primitive.ObjectID) error {
+// SetLastMessage points the chat to its last message, expiresAt tells when the message disappears if it does
+func (repo *ChatRepo) SetLastMessage(ctx context.Context, chatID, messageID primitive.ObjectID, expiresAt *time.Time) error {
+	update := bson.D{
+		{Key: "$set", Value: primitive.D{{Key: "lastMessage", Value: messageID}}},
+		{Key: "$unset", Value: bson.D{{Key: "lastMessageExpiresAt", Value: ""}}},
+	}
+	if expiresAt != nil {
+		update = bson.D{{Key: "$set", Value: primitive.D{
+			{Key: "lastMessage", Value: messageID},
+			{Key: "lastMessageExpiresAt", Value: expiresAt},
+		}}}
+	}
+	r, err := repo.collection.UpdateByID(ctx, chatID, update)
+	if err != nil {
+		return fmt.Errorf("cannot update chat of chats collection: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+
+	slog.Debug("updated last message for chat", slog.String("ID", chatID.Hex()))
+	return nil
+}
+
+// UpdateChat sets given fields of the chat and bumps its updatedAt
+func (repo *ChatRepo) UpdateChat(ctx context.Context, chatID primitive.ObjectID, keyValueMap map[string]any) error {
+	setData := bson.D{{Key: "updatedAt", Value: time.Now()}}
+	for key, value := range keyValueMap {
+		setData = append(setData, primitive.E{Key: key, Value: value})
+	}
+
 	r, err := repo.collection.UpdateByID(ctx, chatID, bson.D{{
 		Key:   "$set",
-		Value: primitive.D{{Key: "lastMessage", Value: messageID}},
+		Value: setData,
 	}})
 	if err != nil {
 		return fmt.Errorf("cannot update chat of chats collection: %w", err)
 	}
 	if r.MatchedCount == 0 {
-		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+		return cmnerr.ErrNotFoundEntity
 	}
+	return nil
+}
 
-	slog.Debug("updated last message for chat", slog.String("ID", chatID.Hex()))
+// SetRetention sets timer of disappearing messages, 0 turns it off
+func (repo *ChatRepo) SetRetention(ctx context.Context, chatID primitive.ObjectID, seconds int64) error {
+	r, err := repo.collection.UpdateByID(ctx, chatID, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "retentionSeconds", Value: seconds},
+			{Key: "updatedAt", Value: time.Now()},
+		},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot update chat of chats collection: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+// GetChatsWithExpiredLastMessage finds chats whose last message has disappeared (or is about to)
+func (repo *ChatRepo) GetChatsWithExpiredLastMessage(ctx context.Context, now time.Time, limit int64) ([]primitive.ObjectID, error) {
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit)
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "lastMessageExpiresAt", Value: bson.D{{Key: "$lte", Value: now}}}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve chats from chats collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var chats []model.Chat
+	if err = cursor.All(ctx, &chats); err != nil {
+		return nil, fmt.Errorf("cannot decode chats from cursor: %w", err)
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(chats))
+	for i := range chats {
+		ids = append(ids, chats[i].ID)
+	}
+	return ids, nil
+}
+
+// PinMessage puts message on top of pinned ones unless it's there already or the chat has limit pins
+func (repo *ChatRepo) PinMessage(ctx context.Context, chatID primitive.ObjectID, messageID primitive.ObjectID, limit int) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: chatID},
+		{Key: "pinnedMessages", Value: bson.D{{Key: "$ne", Value: messageID}}},
+		{Key: fmt.Sprintf("pinnedMessages.%d", limit-1), Value: bson.D{{Key: "$exists", Value: false}}},
+	}, bson.D{{
+		Key: "$push",
+		Value: bson.D{{Key: "pinnedMessages", Value: bson.D{
+			{Key: "$each", Value: bson.A{messageID}},
+			{Key: "$position", Value: 0},
+		}}},
+	}})
+	if err != nil {
+		return false, fmt.Errorf("cannot pin message in chat: %w", err)
+	}
+	return r.ModifiedCount == 1, nil
+}
+
+// UnpinMessages drops given messages from pinned ones, it reports false if none of them was pinned
+func (repo *ChatRepo) UnpinMessages(ctx context.Context, chatID primitive.ObjectID, messageIDs []primitive.ObjectID) (bool, error) {
+	r, err := repo.collection.UpdateByID(ctx, chatID, bson.D{{
+		Key:   "$pullAll",
+		Value: bson.D{{Key: "pinnedMessages", Value: messageIDs}},
+	}})
+	if err != nil {
+		return false, fmt.Errorf("cannot unpin messages in chat: %w", err)
+	}
+	return r.ModifiedCount == 1, nil
+}
+
+func (repo *ChatRepo) ClearPinnedMessages(ctx context.Context, chatID primitive.ObjectID) error {
+	_, err := repo.collection.UpdateByID(ctx, chatID, bson.D{{
+		Key:   "$unset",
+		Value: bson.D{{Key: "pinnedMessages", Value: ""}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot unpin messages in chat: %w", err)
+	}
 	return nil
 }
+
+// AddInvite marks the user invited into the group, it reports false if he is member or invited already
+func (repo *ChatRepo) AddInvite(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: chatID},
+		{Key: "group", Value: true},
+		{Key: "users", Value: bson.D{{Key: "$ne", Value: userID}}},
+		{Key: "invited", Value: bson.D{{Key: "$ne", Value: userID}}},
+	}, bson.D{
+		{Key: "$push", Value: bson.D{{Key: "invited", Value: userID}}},
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot invite user into chat: %w", err)
+	}
+	return r.ModifiedCount == 1, nil
+}
+
+// AddChatUser adds member to the group, dropping his invite if any; it reports false if he is there already
+func (repo *ChatRepo) AddChatUser(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: chatID},
+		{Key: "group", Value: true},
+		{Key: "users", Value: bson.D{{Key: "$ne", Value: userID}}},
+	}, bson.D{
+		{Key: "$push", Value: bson.D{{Key: "users", Value: userID}}},
+		{Key: "$pull", Value: bson.D{{Key: "invited", Value: userID}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now()}}},
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot add user into chat: %w", err)
+	}
+	return r.ModifiedCount == 1, nil
+}
+
+// RemoveInvite drops pending invite of the user, it reports false if there was none
+func (repo *ChatRepo) RemoveInvite(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: chatID},
+		{Key: "invited", Value: userID},
+	}, bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "invited", Value: userID}}},
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot remove invite from chat: %w", err)
+	}
+	return r.ModifiedCount == 1, nil
+}
+
+// GetInvitedChats returns groups the user is invited into but hasn't joined yet
+func (repo *ChatRepo) GetInvitedChats(ctx context.Context, userID primitive.ObjectID) ([]model.Chat, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "invited", Value: userID}}, options.Find().SetSort(bson.D{
+		{Key: "updatedAt", Value: -1},
+	}))
+	if err != nil || cursor == nil {
+		return nil, fmt.Errorf("cannot retrieve chats from chats collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	chats := make([]model.Chat, 0)
+	if err = cursor.All(ctx, &chats); err != nil {
+		return nil, fmt.Errorf("cannot decode chats from cursor: %w", err)
+	}
+
+	return chats, nil
+}
+
+func (repo *ChatRepo) GetChatIDsByUserID(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "users", Value: userID}}, options.Find().SetProjection(bson.D{
+		{Key: "_id", Value: 1},
+	}))
+	if err != nil || cursor == nil {
+		return nil, fmt.Errorf("cannot retrieve chats from chats collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var chats []model.Chat
+	if err = cursor.All(ctx, &chats); err != nil {
+		return nil, fmt.Errorf("cannot decode chats from cursor: %w", err)
+	}
+
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	for i := range chats {
+		chatIDs = append(chatIDs, chats[i].ID)
+	}
+
+	return chatIDs, nil
+}
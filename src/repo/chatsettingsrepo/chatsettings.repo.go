@@ -0,0 +1,71 @@
+package chatsettingsrepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type ChatSettingsRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewChatSettingsRepo(db types.IDatabase) *ChatSettingsRepo {
+	name := "chatSettings"
+	return &ChatSettingsRepo{
+		name:       "chatSettings",
+		collection: db.GetCollection(name),
+	}
+}
+
+// UpdateSettings sets only given fields of the user's settings for the chat, creating them if absent,
+// so concurrent updates of other fields aren't lost. It returns settings after the update
+func (repo *ChatSettingsRepo) UpdateSettings(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID, keyValueMap map[string]any) (*model.ChatSettings, error) {
+	setData := make(bson.D, 0, len(keyValueMap)+1)
+	for key, value := range keyValueMap {
+		setData = append(setData, primitive.E{Key: key, Value: value})
+	}
+	setData = append(setData, primitive.E{Key: "updatedAt", Value: time.Now()})
+
+	var settings *model.ChatSettings
+	err := repo.collection.FindOneAndUpdate(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: userID},
+	}, bson.D{{
+		Key:   "$set",
+		Value: setData,
+	}}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&settings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot update chat settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// GetChatSettingsOfUsers returns settings of those members of the chat who have any
+func (repo *ChatSettingsRepo) GetChatSettingsOfUsers(ctx context.Context, chatID primitive.ObjectID, userIDs []primitive.ObjectID) ([]model.ChatSettings, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: bson.D{{Key: "$in", Value: userIDs}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve chat settings: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	settings := make([]model.ChatSettings, 0)
+	if err = cursor.All(ctx, &settings); err != nil {
+		return nil, fmt.Errorf("cannot decode chat settings from cursor: %w", err)
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,181 @@
+package contactrequestrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/repohelper"
+)
+
+type ContactRequestRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewContactRequestRepo(db types.IDatabase) *ContactRequestRepo {
+	name := "contactRequests"
+	return &ContactRequestRepo{
+		name:       "contactRequests",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *ContactRequestRepo) SaveContactRequest(ctx context.Context, data *model.ContactRequest) (*model.ContactRequest, error) {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err == nil {
+		slog.Debug("saved contact request", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+		data.ID = r.InsertedID.(primitive.ObjectID)
+		return data, nil
+	}
+
+	errText := err.Error()
+	if strings.Contains(errText, "duplicate key error collection") {
+		return nil, errors.Join(cmnerr.ErrAlreadyExists, err)
+	}
+
+	return nil, fmt.Errorf("cannot save contact request into contactRequests collection: %w", err)
+}
+
+// GetPendingRequest returns pending request sent by one user to another
+func (repo *ContactRequestRepo) GetPendingRequest(ctx context.Context, from, to primitive.ObjectID) (*model.ContactRequest, error) {
+	var request *model.ContactRequest
+	if err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "from", Value: from},
+		{Key: "to", Value: to},
+		{Key: "status", Value: model.ContactRequestStatusPending},
+	}).Decode(&request); err != nil || request == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || request == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve contact request from contactRequests collection: %w", err)
+	}
+
+	return request, nil
+}
+
+// ResolvePendingRequest moves pending request into the final status.
+// roleKey ("from" or "to") defines which side of the request is allowed to do that.
+func (repo *ContactRequestRepo) ResolvePendingRequest(ctx context.Context, id primitive.ObjectID, roleKey string, userID primitive.ObjectID, status string) (*model.ContactRequest, error) {
+	var request *model.ContactRequest
+	err := repo.collection.FindOneAndUpdate(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: roleKey, Value: userID},
+		{Key: "status", Value: model.ContactRequestStatusPending},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "updatedAt", Value: time.Now()},
+		},
+	}}).Decode(&request)
+	if err != nil || request == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || request == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot update contact request of contactRequests collection: %w", err)
+	}
+
+	slog.Debug("resolved contact request", slog.String("ID", id.Hex()), slog.String("status", status))
+	request.Status = status
+	return request, nil
+}
+
+// CancelPendingRequestsBetween cancels pending requests of both directions
+func (repo *ContactRequestRepo) CancelPendingRequestsBetween(ctx context.Context, id1, id2 primitive.ObjectID) error {
+	_, err := repo.collection.UpdateMany(ctx, bson.D{
+		{Key: "$or", Value: []bson.D{
+			{{Key: "from", Value: id1}, {Key: "to", Value: id2}},
+			{{Key: "from", Value: id2}, {Key: "to", Value: id1}},
+		}},
+		{Key: "status", Value: model.ContactRequestStatusPending},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "status", Value: model.ContactRequestStatusCancelled},
+			{Key: "updatedAt", Value: time.Now()},
+		},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot cancel contact requests of contactRequests collection: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingRequestsByUserID lists pending requests where user plays the role (roleKey is "from" or "to")
+func (repo *ContactRequestRepo) GetPendingRequestsByUserID(ctx context.Context, roleKey string, userID primitive.ObjectID, params ...any) ([]model.ContactRequestPopulated, error) {
+	match := bson.D{{Key: "$match", Value: bson.D{
+		{Key: roleKey, Value: userID},
+		{Key: "status", Value: model.ContactRequestStatusPending},
+	}}}
+	sort := bson.D{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}}}}
+	pipelineStages := mongo.Pipeline{match, sort}
+
+	pgParam := params[0].(types.PaginationParams)
+	if pgParam.Page > 1 {
+		skip := bson.D{{Key: "$skip", Value: (pgParam.Page - 1) * pgParam.Limit}}
+		pipelineStages = append(pipelineStages, skip)
+	}
+	if pgParam.Limit != 0 {
+		limit := bson.D{{Key: "$limit", Value: pgParam.Limit}}
+		pipelineStages = append(pipelineStages, limit)
+	}
+
+	for _, key := range []string{"from", "to"} {
+		ls := bson.D{
+			{Key: "from", Value: "users"},
+			{Key: "localField", Value: key},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: key},
+		}
+		lookup := bson.D{{Key: "$lookup", Value: ls}}
+		unwind := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$" + key}}}}
+		pipelineStages = append(pipelineStages, lookup, unwind)
+	}
+
+	var requests []any
+	cursor, err := repo.collection.Aggregate(ctx, pipelineStages)
+	if err != nil {
+		return nil, fmt.Errorf("cannot aggregate from contactRequests collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, fmt.Errorf("cannot decode contact requests from cursor: %w", err)
+	}
+
+	requestsPopulated := make([]model.ContactRequestPopulated, 0, len(requests))
+	for i := range requests {
+		d, _ := requests[i].(primitive.D)
+		request := d.Map()
+
+		from := repohelper.RawDocToUserModel(request["from"].(primitive.D).Map())
+		to := repohelper.RawDocToUserModel(request["to"].(primitive.D).Map())
+
+		requestsPopulated = append(requestsPopulated, model.ContactRequestPopulated{
+			ContactRequest: &model.ContactRequest{
+				ID:        request["_id"].(primitive.ObjectID),
+				From:      from.ID,
+				To:        to.ID,
+				Status:    request["status"].(string),
+				CreatedAt: request["createdAt"].(primitive.DateTime).Time(),
+				UpdatedAt: request["updatedAt"].(primitive.DateTime).Time(),
+			},
+			From: from,
+			To:   to,
+		})
+	}
+
+	return requestsPopulated, nil
+}
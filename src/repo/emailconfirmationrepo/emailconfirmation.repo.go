@@ -0,0 +1,75 @@
+package emailconfirmationrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type EmailConfirmationRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewEmailConfirmationRepo(db types.IDatabase) *EmailConfirmationRepo {
+	name := "emailConfirmations"
+	return &EmailConfirmationRepo{
+		name:       name,
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *EmailConfirmationRepo) SaveEmailConfirmation(ctx context.Context, data *model.EmailConfirmation) error {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return fmt.Errorf("cannot save email confirmation into emailConfirmations collection: %w", err)
+	}
+
+	slog.Debug("saved email confirmation", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return nil
+}
+
+// CountRecentConfirmations counts confirmations sent to the user since the moment
+func (repo *EmailConfirmationRepo) CountRecentConfirmations(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error) {
+	count, err := repo.collection.CountDocuments(ctx, bson.D{
+		{Key: "user", Value: userID},
+		{Key: "createdAt", Value: bson.D{{Key: "$gte", Value: since}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count email confirmations: %w", err)
+	}
+	return count, nil
+}
+
+// UseToken atomically marks unused & unexpired confirmation as used, so it cannot be used twice
+func (repo *EmailConfirmationRepo) UseToken(ctx context.Context, tokenHash string) (*model.EmailConfirmation, error) {
+	now := time.Now()
+
+	var confirmation *model.EmailConfirmation
+	err := repo.collection.FindOneAndUpdate(ctx, bson.D{
+		{Key: "tokenHash", Value: tokenHash},
+		{Key: "usedAt", Value: nil},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: now}}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "usedAt", Value: now}},
+	}}).Decode(&confirmation)
+	if err != nil || confirmation == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || confirmation == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot use email confirmation of emailConfirmations collection: %w", err)
+	}
+
+	return confirmation, nil
+}
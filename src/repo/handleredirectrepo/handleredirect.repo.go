@@ -0,0 +1,73 @@
+package handleredirectrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type HandleRedirectRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewHandleRedirectRepo(db types.IDatabase) *HandleRedirectRepo {
+	name := "handleRedirects"
+	return &HandleRedirectRepo{
+		name:       "handleRedirects",
+		collection: db.GetCollection(name),
+	}
+}
+
+// SaveRedirect points former handle to the user
+func (repo *HandleRedirectRepo) SaveRedirect(ctx context.Context, handle string, userID primitive.ObjectID) error {
+	_, err := repo.collection.UpdateOne(ctx, bson.D{{Key: "handle", Value: handle}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "user", Value: userID},
+			{Key: "createdAt", Value: time.Now()},
+		},
+	}}, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("cannot save redirect into handleRedirects collection: %w", err)
+	}
+
+	slog.Debug("saved handle redirect", slog.String("handle", handle))
+	return nil
+}
+
+func (repo *HandleRedirectRepo) GetRedirect(ctx context.Context, handle string) (*model.HandleRedirect, error) {
+	var redirect *model.HandleRedirect
+	if err := repo.collection.FindOne(ctx, bson.D{{Key: "handle", Value: handle}}).Decode(&redirect); err != nil || redirect == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || redirect == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve redirect from handleRedirects collection: %w", err)
+	}
+
+	return redirect, nil
+}
+
+// DeleteRedirect removes redirect when the user takes his former handle back
+func (repo *HandleRedirectRepo) DeleteRedirect(ctx context.Context, handle string, userID primitive.ObjectID) error {
+	_, err := repo.collection.DeleteOne(ctx, bson.D{
+		{Key: "handle", Value: handle},
+		{Key: "user", Value: userID},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete redirect from handleRedirects collection: %w", err)
+	}
+
+	return nil
+}
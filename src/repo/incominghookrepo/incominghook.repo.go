@@ -0,0 +1,122 @@
+package incominghookrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type IncomingHookRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewIncomingHookRepo(db types.IDatabase) *IncomingHookRepo {
+	name := "incomingHooks"
+	return &IncomingHookRepo{
+		name:       "incomingHooks",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *IncomingHookRepo) SaveHook(ctx context.Context, data *model.IncomingHook) (primitive.ObjectID, error) {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("cannot save hook into incomingHooks collection: %w", err)
+	}
+
+	slog.Debug("saved incoming hook", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return r.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetActiveHook finds not revoked hook by token hash
+func (repo *IncomingHookRepo) GetActiveHook(ctx context.Context, tokenHash string) (*model.IncomingHook, error) {
+	var hook *model.IncomingHook
+	err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "tokenHash", Value: tokenHash},
+		{Key: "revokedAt", Value: nil},
+	}).Decode(&hook)
+	if err != nil || hook == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || hook == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve hook from incomingHooks collection: %w", err)
+	}
+
+	return hook, nil
+}
+
+func (repo *IncomingHookRepo) GetChatHooks(ctx context.Context, chatID primitive.ObjectID) ([]model.IncomingHook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "chat", Value: chatID}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve incoming hooks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	hooks := make([]model.IncomingHook, 0)
+	if err = cursor.All(ctx, &hooks); err != nil {
+		return nil, fmt.Errorf("cannot decode incoming hooks from cursor: %w", err)
+	}
+
+	return hooks, nil
+}
+
+func (repo *IncomingHookRepo) CountActiveChatHooks(ctx context.Context, chatID primitive.ObjectID) (int64, error) {
+	count, err := repo.collection.CountDocuments(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "revokedAt", Value: nil},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count incoming hooks: %w", err)
+	}
+	return count, nil
+}
+
+func (repo *IncomingHookRepo) RevokeHook(ctx context.Context, chatID primitive.ObjectID, id primitive.ObjectID) error {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+		{Key: "revokedAt", Value: nil},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "revokedAt", Value: time.Now()}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot revoke incoming hook: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+// TouchHook records usage at most once per interval not to write on every post
+func (repo *IncomingHookRepo) TouchHook(ctx context.Context, id primitive.ObjectID, interval time.Duration) error {
+	now := time.Now()
+	_, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "lastUsedAt", Value: nil}},
+			bson.D{{Key: "lastUsedAt", Value: bson.D{{Key: "$lt", Value: now.Add(-interval)}}}},
+		}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "lastUsedAt", Value: now}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot update incoming hook usage: %w", err)
+	}
+	return nil
+}
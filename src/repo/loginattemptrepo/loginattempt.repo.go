@@ -0,0 +1,87 @@
+package loginattemptrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type LoginAttemptRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepo(db types.IDatabase) *LoginAttemptRepo {
+	name := "loginAttempts"
+	return &LoginAttemptRepo{
+		name:       "loginAttempts",
+		collection: db.GetCollection(name),
+	}
+}
+
+// GetAttempts returns counters of existing keys only
+func (repo *LoginAttemptRepo) GetAttempts(ctx context.Context, keys []string) ([]model.LoginAttempt, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "key", Value: bson.D{{Key: "$in", Value: keys}}}})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve login attempts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	attempts := make([]model.LoginAttempt, 0, len(keys))
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return nil, fmt.Errorf("cannot decode login attempts from cursor: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// AddFailure atomically increments failures of the key and returns updated counter
+func (repo *LoginAttemptRepo) AddFailure(ctx context.Context, key string, at time.Time, expiresAt time.Time) (*model.LoginAttempt, error) {
+	var attempt *model.LoginAttempt
+	err := repo.collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "key", Value: key}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "lastFailureAt", Value: at},
+				{Key: "expiresAt", Value: expiresAt},
+			}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("cannot register failed login attempt: %w", err)
+	}
+
+	return attempt, nil
+}
+
+// LockUntil never shortens lock already set by concurrent failure
+func (repo *LoginAttemptRepo) LockUntil(ctx context.Context, key string, until time.Time, expiresAt time.Time) error {
+	_, err := repo.collection.UpdateOne(ctx, bson.D{{Key: "key", Value: key}}, bson.D{
+		{Key: "$max", Value: bson.D{
+			{Key: "lockedUntil", Value: until},
+			{Key: "expiresAt", Value: expiresAt},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot lock login attempts key: %w", err)
+	}
+	return nil
+}
+
+func (repo *LoginAttemptRepo) ResetAttempts(ctx context.Context, key string) error {
+	_, err := repo.collection.DeleteOne(ctx, bson.D{{Key: "key", Value: key}})
+	if err != nil {
+		return fmt.Errorf("cannot reset login attempts: %w", err)
+	}
+	return nil
+}
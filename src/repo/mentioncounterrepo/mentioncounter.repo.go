@@ -0,0 +1,92 @@
+package mentioncounterrepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type MentionCounterRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewMentionCounterRepo(db types.IDatabase) *MentionCounterRepo {
+	name := "mentionCounters"
+	return &MentionCounterRepo{
+		name:       "mentionCounters",
+		collection: db.GetCollection(name),
+	}
+}
+
+// IncUnreadMentions counts new mention of each of users in the chat
+func (repo *MentionCounterRepo) IncUnreadMentions(ctx context.Context, chatID primitive.ObjectID, userIDs []primitive.ObjectID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(userIDs))
+	for _, userID := range userIDs {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{Key: "chat", Value: chatID},
+				{Key: "user", Value: userID},
+			}).
+			SetUpdate(bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "unreadMentions", Value: 1}}},
+				{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: now}}},
+			}).
+			SetUpsert(true))
+	}
+
+	if _, err := repo.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("cannot update unread mentions: %w", err)
+	}
+	return nil
+}
+
+// ResetUnreadMentions marks mentions of the user in the chat as read
+func (repo *MentionCounterRepo) ResetUnreadMentions(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) error {
+	_, err := repo.collection.DeleteOne(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: userID},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete from mentionCounters collection: %w", err)
+	}
+	return nil
+}
+
+// ResetChatUnreadMentions marks mentions of all members as read, e.g. when the chat is cleared
+func (repo *MentionCounterRepo) ResetChatUnreadMentions(ctx context.Context, chatID primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "chat", Value: chatID}}); err != nil {
+		return fmt.Errorf("cannot delete from mentionCounters collection: %w", err)
+	}
+	return nil
+}
+
+// GetUnreadMentions returns counters of chats where the user has unread mentions, recently mentioned first
+func (repo *MentionCounterRepo) GetUnreadMentions(ctx context.Context, userID primitive.ObjectID) ([]model.MentionCounter, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "user", Value: userID}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve mention counters: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	counters := make([]model.MentionCounter, 0)
+	if err = cursor.All(ctx, &counters); err != nil {
+		return nil, fmt.Errorf("cannot decode mention counters from cursor: %w", err)
+	}
+
+	return counters, nil
+}
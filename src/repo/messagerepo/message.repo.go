@@ -2,13 +2,17 @@ package messagerepo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/model"
 	"github.com/MykolaSainiuk/schatgo/src/repo/repohelper"
@@ -37,6 +41,23 @@ func (repo *MessageRepo) SaveMessage(ctx context.Context, newToken *model.Messag
 	return primitive.NilObjectID, fmt.Errorf("cannot save message into messages collection: %w", err)
 }
 
+// GetChatMessage finds message by ID within the chat
+func (repo *MessageRepo) GetChatMessage(ctx context.Context, chatID primitive.ObjectID, id primitive.ObjectID) (*model.Message, error) {
+	var message *model.Message
+	err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+	}).Decode(&message)
+	if err != nil || message == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || message == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve message from messages collection: %w", err)
+	}
+
+	return message, nil
+}
+
 func (repo *MessageRepo) GetMessagesByChatID(ctx context.Context, id string, params ...any) ([]model.MessagePopulated, error) {
 	_id, _ := primitive.ObjectIDFromHex(id)
 
@@ -98,9 +119,165 @@ func (repo *MessageRepo) GetMessagesByChatID(ctx context.Context, id string, par
 	return messagesPopulated, nil
 }
 
+func (repo *MessageRepo) SearchMessages(ctx context.Context, query string, chatIDs []primitive.ObjectID, params ...any) ([]model.MessageSearchHit, error) {
+	// $text has to be the very first stage of the pipeline
+	match := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}},
+		{Key: "chat", Value: bson.D{{Key: "$in", Value: chatIDs}}},
+	}}}
+	addScore := bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+	}}}
+	sort := bson.D{{Key: "$sort", Value: bson.D{
+		{Key: "score", Value: -1},
+		{Key: "createdAt", Value: -1},
+	}}}
+	pipelineStages := mongo.Pipeline{match, addScore, sort}
+
+	pgParam := params[0].(types.PaginationParams)
+	if pgParam.Page > 1 {
+		skip := bson.D{{Key: "$skip", Value: (pgParam.Page - 1) * pgParam.Limit}}
+		pipelineStages = append(pipelineStages, skip)
+	}
+	if pgParam.Limit != 0 {
+		limit := bson.D{{Key: "$limit", Value: pgParam.Limit}}
+		pipelineStages = append(pipelineStages, limit)
+	}
+
+	ls1 := bson.D{
+		{Key: "from", Value: "users"},
+		{Key: "localField", Value: "user"},
+		{Key: "foreignField", Value: "_id"},
+		{Key: "as", Value: "user"},
+	}
+	lookup1 := bson.D{{Key: "$lookup", Value: ls1}}
+	unwind1 := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$user"}}}}
+	pipelineStages = append(pipelineStages, lookup1, unwind1)
+
+	var messages []any
+	cursor, err := repo.collection.Aggregate(ctx, pipelineStages)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search in messages collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("cannot decode messages from cursor: %w", err)
+	}
+
+	hits := make([]model.MessageSearchHit, 0, len(messages))
+	for i := range messages {
+		d, _ := messages[i].(primitive.D)
+		msg := d.Map()
+
+		rawUser := msg["user"]
+		score, _ := msg["score"].(float64)
+
+		hits = append(hits, model.MessageSearchHit{
+			MessagePopulated: &model.MessagePopulated{
+				Message: repohelper.RawDocToMessageModel(msg),
+				User:    repohelper.RawDocToUserModel(rawUser.(primitive.D).Map()),
+			},
+			Score: score,
+		})
+	}
+
+	return hits, nil
+}
+
 func (repo *MessageRepo) RemoveAllMessagesByChatID(ctx context.Context, id string) error {
 	_id, _ := primitive.ObjectIDFromHex(id)
-	_, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "chat", Value: _id}})
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "chat", Value: _id}}); err != nil {
+		return fmt.Errorf("cannot delete messages from messages collection: %w", err)
+	}
+	return nil
+}
+
+// GetExpiredMessages returns IDs & chats of messages past their expiry, TTL monitor may not have deleted yet
+func (repo *MessageRepo) GetExpiredMessages(ctx context.Context, now time.Time, limit int64) ([]model.Message, error) {
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "_id", Value: 1}, {Key: "chat", Value: 1}}).
+		SetSort(bson.D{{Key: "expiresAt", Value: 1}}).
+		SetLimit(limit)
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$lte", Value: now}}}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve expired messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := make([]model.Message, 0)
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("cannot decode expired messages from cursor: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (repo *MessageRepo) RemoveMessagesByIDs(ctx context.Context, ids []primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}); err != nil {
+		return fmt.Errorf("cannot delete messages from messages collection: %w", err)
+	}
+	return nil
+}
+
+// GetLatestChatMessage returns the newest message of the chat which hasn't expired, nil if there's none
+func (repo *MessageRepo) GetLatestChatMessage(ctx context.Context, chatID primitive.ObjectID, now time.Time) (*model.Message, error) {
+	var message *model.Message
+	err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expiresAt", Value: nil}},
+			bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: now}}}},
+		}},
+	}, options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot retrieve message from messages collection: %w", err)
+	}
+
+	return message, nil
+}
+
+// GetChatMessagesByIDs returns those of given messages which belong to the chat
+func (repo *MessageRepo) GetChatMessagesByIDs(ctx context.Context, chatID primitive.ObjectID, ids []primitive.ObjectID) ([]model.Message, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}},
+		{Key: "chat", Value: chatID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := make([]model.Message, 0)
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("cannot decode messages from cursor: %w", err)
+	}
+
+	return messages, nil
+}
+
+// GetUserMentions returns messages of given chats mentioning the user, the latest first
+func (repo *MessageRepo) GetUserMentions(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, pgParams types.PaginationParams) ([]model.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((pgParams.Page - 1) * pgParams.Limit)).
+		SetLimit(int64(pgParams.Limit))
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "mentions.user", Value: userID},
+		{Key: "chat", Value: bson.D{{Key: "$in", Value: chatIDs}}},
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve mentions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := make([]model.Message, 0)
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("cannot decode mentions from cursor: %w", err)
+	}
 
-	return fmt.Errorf("cannot delete messages from messages collection: %w", err)
+	return messages, nil
 }
@@ -0,0 +1,57 @@
+package oidcstaterepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type OidcStateRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewOidcStateRepo(db types.IDatabase) *OidcStateRepo {
+	name := "oidcStates"
+	return &OidcStateRepo{
+		name:       "oidcStates",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *OidcStateRepo) SaveState(ctx context.Context, data *model.OidcState) error {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return fmt.Errorf("cannot save state into oidcStates collection: %w", err)
+	}
+
+	slog.Debug("saved oidc state", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return nil
+}
+
+// ConsumeState takes unexpired state out, so each one is usable once
+func (repo *OidcStateRepo) ConsumeState(ctx context.Context, state string) (*model.OidcState, error) {
+	var oidcState *model.OidcState
+	err := repo.collection.FindOneAndDelete(ctx, bson.D{
+		{Key: "state", Value: state},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}).Decode(&oidcState)
+	if err != nil || oidcState == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || oidcState == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot consume state of oidcStates collection: %w", err)
+	}
+
+	return oidcState, nil
+}
@@ -0,0 +1,90 @@
+package passwordresetrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type PasswordResetRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewPasswordResetRepo(db types.IDatabase) *PasswordResetRepo {
+	name := "passwordResets"
+	return &PasswordResetRepo{
+		name:       "passwordResets",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *PasswordResetRepo) SavePasswordReset(ctx context.Context, data *model.PasswordReset) error {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return fmt.Errorf("cannot save password reset into passwordResets collection: %w", err)
+	}
+
+	slog.Debug("saved password reset", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return nil
+}
+
+// CountRecentResets counts resets requested by the user since the moment
+func (repo *PasswordResetRepo) CountRecentResets(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error) {
+	count, err := repo.collection.CountDocuments(ctx, bson.D{
+		{Key: "user", Value: userID},
+		{Key: "createdAt", Value: bson.D{{Key: "$gte", Value: since}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count password resets: %w", err)
+	}
+	return count, nil
+}
+
+// UseToken atomically marks unused & unexpired reset as used, so it cannot be used twice
+func (repo *PasswordResetRepo) UseToken(ctx context.Context, tokenHash string) (*model.PasswordReset, error) {
+	now := time.Now()
+
+	var reset *model.PasswordReset
+	err := repo.collection.FindOneAndUpdate(ctx, bson.D{
+		{Key: "tokenHash", Value: tokenHash},
+		{Key: "usedAt", Value: nil},
+		{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: now}}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "usedAt", Value: now}},
+	}}).Decode(&reset)
+	if err != nil || reset == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || reset == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot use password reset of passwordResets collection: %w", err)
+	}
+
+	return reset, nil
+}
+
+// InvalidateUserResets burns every outstanding reset of the user
+func (repo *PasswordResetRepo) InvalidateUserResets(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := repo.collection.UpdateMany(ctx, bson.D{
+		{Key: "user", Value: userID},
+		{Key: "usedAt", Value: nil},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "usedAt", Value: time.Now()}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot invalidate password resets: %w", err)
+	}
+	return nil
+}
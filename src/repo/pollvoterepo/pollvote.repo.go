@@ -0,0 +1,160 @@
+package pollvoterepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type PollVoteRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewPollVoteRepo(db types.IDatabase) *PollVoteRepo {
+	name := "pollVotes"
+	return &PollVoteRepo{
+		name:       "pollVotes",
+		collection: db.GetCollection(name),
+	}
+}
+
+// SaveVote records choice of the user replacing the previous one
+func (repo *PollVoteRepo) SaveVote(ctx context.Context, vote *model.PollVote) error {
+	now := time.Now()
+	_, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "message", Value: vote.Message},
+		{Key: "user", Value: vote.User},
+	}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "options", Value: vote.Options},
+			{Key: "updatedAt", Value: now},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "chat", Value: vote.Chat},
+			{Key: "createdAt", Value: now},
+		}},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("cannot save vote into pollVotes collection: %w", err)
+	}
+	return nil
+}
+
+func (repo *PollVoteRepo) DeleteVote(ctx context.Context, messageID primitive.ObjectID, userID primitive.ObjectID) error {
+	_, err := repo.collection.DeleteOne(ctx, bson.D{
+		{Key: "message", Value: messageID},
+		{Key: "user", Value: userID},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete vote from pollVotes collection: %w", err)
+	}
+	return nil
+}
+
+func (repo *PollVoteRepo) DeleteChatVotes(ctx context.Context, chatID primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "chat", Value: chatID}}); err != nil {
+		return fmt.Errorf("cannot delete votes from pollVotes collection: %w", err)
+	}
+	return nil
+}
+
+func (repo *PollVoteRepo) DeleteMessagesVotes(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "message", Value: bson.D{{Key: "$in", Value: messageIDs}}}}); err != nil {
+		return fmt.Errorf("cannot delete votes from pollVotes collection: %w", err)
+	}
+	return nil
+}
+
+// Tally is number of votes for one option of the poll
+type Tally struct {
+	Message primitive.ObjectID   `bson:"message"`
+	Option  int                  `bson:"option"`
+	Votes   int                  `bson:"votes"`
+	Voters  []primitive.ObjectID `bson:"voters"`
+}
+
+// VoterCount is number of users who voted in the poll
+type VoterCount struct {
+	Message primitive.ObjectID `bson:"_id"`
+	Count   int                `bson:"count"`
+}
+
+// GetTallies aggregates votes of given polls per option
+func (repo *PollVoteRepo) GetTallies(ctx context.Context, messageIDs []primitive.ObjectID) ([]Tally, []VoterCount, error) {
+	match := bson.D{{Key: "$match", Value: bson.D{{
+		Key: "message", Value: bson.D{{Key: "$in", Value: messageIDs}},
+	}}}}
+	tallies := bson.A{
+		bson.D{{Key: "$unwind", Value: "$options"}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "message", Value: "$message"}, {Key: "option", Value: "$options"}}},
+			{Key: "votes", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "voters", Value: bson.D{{Key: "$push", Value: "$user"}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "message", Value: "$_id.message"},
+			{Key: "option", Value: "$_id.option"},
+			{Key: "votes", Value: 1},
+			{Key: "voters", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "option", Value: 1}}}},
+	}
+	voters := bson.A{
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$message"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	facet := bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "tallies", Value: tallies},
+		{Key: "voters", Value: voters},
+	}}}
+
+	cursor, err := repo.collection.Aggregate(ctx, mongo.Pipeline{match, facet})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot aggregate poll votes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Tallies []Tally      `bson:"tallies"`
+		Voters  []VoterCount `bson:"voters"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode poll votes from cursor: %w", err)
+	}
+	if len(result) == 0 {
+		return []Tally{}, []VoterCount{}, nil
+	}
+
+	return result[0].Tallies, result[0].Voters, nil
+}
+
+// GetUserVotes returns votes of the user in given polls
+func (repo *PollVoteRepo) GetUserVotes(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) ([]model.PollVote, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "message", Value: bson.D{{Key: "$in", Value: messageIDs}}},
+		{Key: "user", Value: userID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve poll votes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	votes := make([]model.PollVote, 0)
+	if err = cursor.All(ctx, &votes); err != nil {
+		return nil, fmt.Errorf("cannot decode poll votes from cursor: %w", err)
+	}
+
+	return votes, nil
+}
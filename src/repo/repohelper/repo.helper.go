@@ -1,7 +1,10 @@
 package repohelper
 
 import (
+	"time"
+
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -9,13 +12,35 @@ func RawDocToUserModel(rawDoc map[string]any) *model.User {
 	rcontacts, _ := rawDoc["contacts"].(primitive.A)
 	rchats, _ := rawDoc["chats"].(primitive.A)
 
+	rblocked, _ := rawDoc["blocked"].(primitive.A)
+
 	contacts, chats := shrinkObjectsToItsIDs(rcontacts), shrinkObjectsToItsIDs(rchats)
 
+	discoverable, ok := rawDoc["discoverable"].(bool)
+	if !ok {
+		discoverable = true
+	}
+	handle, _ := rawDoc["handle"].(string)
+	email, _ := rawDoc["email"].(string)
+	emailVerified, _ := rawDoc["emailVerified"].(bool)
+	bio, _ := rawDoc["bio"].(string)
+	statusText, _ := rawDoc["statusText"].(string)
+
 	return &model.User{
 		ID:        rawDoc["_id"].(primitive.ObjectID),
+		Handle:    handle,
 		Name:      rawDoc["name"].(string),
 		AvatarUri: rawDoc["avatarUri"].(string),
 		Hash:      rawDoc["hash"].(string),
+		Email:     email,
+
+		EmailVerified: emailVerified,
+
+		Bio:        bio,
+		StatusText: statusText,
+
+		Discoverable: discoverable,
+		Blocked:      shrinkObjectsToItsIDs(rblocked),
 
 		Contacts: contacts,
 		Chats:    chats,
@@ -55,6 +80,10 @@ func RawPlainDocToChatModel(rawDoc map[string]any) *model.Chat {
 	if !ok {
 		iconUri = ""
 	}
+	description, _ := rawDoc["description"].(string)
+	retentionSeconds, _ := rawDoc["retentionSeconds"].(int64)
+	rpinned, _ := rawDoc["pinnedMessages"].(primitive.A)
+	pinnedMessages := shrinkObjectsToItsIDs(rpinned)
 	lastMessage, ok := rawDoc["lastMessage"].(primitive.ObjectID)
 	if !ok {
 		lastMessage = primitive.NilObjectID
@@ -63,11 +92,14 @@ func RawPlainDocToChatModel(rawDoc map[string]any) *model.Chat {
 	return &model.Chat{
 		ID:      rawDoc["_id"].(primitive.ObjectID),
 		Name:    name,
-		Muted:   rawDoc["muted"].(bool),
 		IconUri: iconUri,
 
-		Users:       users,
-		LastMessage: lastMessage,
+		Description:      description,
+		RetentionSeconds: retentionSeconds,
+
+		Users:          users,
+		LastMessage:    lastMessage,
+		PinnedMessages: pinnedMessages,
 
 		CreatedAt: rawDoc["createdAt"].(primitive.DateTime).Time(),
 		UpdatedAt: rawDoc["updatedAt"].(primitive.DateTime).Time(),
@@ -89,16 +121,26 @@ func RawDocToChatModel(rawDoc map[string]any) *model.Chat {
 	if !ok {
 		iconUri = ""
 	}
+	// chats created before creators were stored have none
+	creator, _ := rawDoc["creator"].(primitive.ObjectID)
+	group, _ := rawDoc["group"].(bool)
+	description, _ := rawDoc["description"].(string)
+	retentionSeconds, _ := rawDoc["retentionSeconds"].(int64)
+	rpinned, _ := rawDoc["pinnedMessages"].(primitive.A)
 
 	return &model.Chat{
-		ID:          rawDoc["_id"].(primitive.ObjectID),
-		Name:        name,
-		Muted:       rawDoc["muted"].(bool),
-		IconUri:     iconUri,
-		CreatedAt:   rawDoc["createdAt"].(primitive.DateTime).Time(),
-		UpdatedAt:   rawDoc["updatedAt"].(primitive.DateTime).Time(),
-		Users:       users,
-		LastMessage: primitive.NilObjectID,
+		ID:               rawDoc["_id"].(primitive.ObjectID),
+		Name:             name,
+		IconUri:          iconUri,
+		Group:            group,
+		Description:      description,
+		RetentionSeconds: retentionSeconds,
+		CreatedAt:        rawDoc["createdAt"].(primitive.DateTime).Time(),
+		UpdatedAt:        rawDoc["updatedAt"].(primitive.DateTime).Time(),
+		Creator:          creator,
+		Users:            users,
+		LastMessage:      primitive.NilObjectID,
+		PinnedMessages:   shrinkObjectsToItsIDs(rpinned),
 	}
 }
 
@@ -111,6 +153,7 @@ func RawPlainDocToMessageModel(rawDoc map[string]any) *model.Message {
 	if !ok {
 		image = ""
 	}
+	bot, _ := rawDoc["bot"].(bool)
 
 	return &model.Message{
 		ID:    rawDoc["_id"].(primitive.ObjectID),
@@ -121,6 +164,15 @@ func RawPlainDocToMessageModel(rawDoc map[string]any) *model.Message {
 		Received: rawDoc["received"].(bool),
 		System:   rawDoc["system"].(bool),
 
+		Bot:  bot,
+		Hook: decodeEmbedded[model.MessageHook](rawDoc["hook"]),
+		Poll: decodeEmbedded[model.Poll](rawDoc["poll"]),
+
+		ForwardedFrom: decodeEmbedded[model.ForwardedFrom](rawDoc["forwardedFrom"]),
+		Mentions:      decodeEmbeddedList[model.Mention](rawDoc["mentions"]),
+
+		ExpiresAt: rawTime(rawDoc["expiresAt"]),
+
 		User: rawDoc["user"].(primitive.ObjectID),
 		Chat: rawDoc["chat"].(primitive.ObjectID),
 
@@ -139,6 +191,7 @@ func RawDocToMessageModel(rawDoc map[string]any) *model.Message {
 	if !ok {
 		image = ""
 	}
+	bot, _ := rawDoc["bot"].(bool)
 
 	return &model.Message{
 		ID:    rawDoc["_id"].(primitive.ObjectID),
@@ -149,6 +202,15 @@ func RawDocToMessageModel(rawDoc map[string]any) *model.Message {
 		Received: rawDoc["received"].(bool),
 		System:   rawDoc["system"].(bool),
 
+		Bot:  bot,
+		Hook: decodeEmbedded[model.MessageHook](rawDoc["hook"]),
+		Poll: decodeEmbedded[model.Poll](rawDoc["poll"]),
+
+		ForwardedFrom: decodeEmbedded[model.ForwardedFrom](rawDoc["forwardedFrom"]),
+		Mentions:      decodeEmbeddedList[model.Mention](rawDoc["mentions"]),
+
+		ExpiresAt: rawTime(rawDoc["expiresAt"]),
+
 		User: primitive.NilObjectID,
 		Chat: rawDoc["chat"].(primitive.ObjectID),
 
@@ -156,3 +218,51 @@ func RawDocToMessageModel(rawDoc map[string]any) *model.Message {
 		UpdatedAt: rawDoc["updatedAt"].(primitive.DateTime).Time(),
 	}
 }
+
+// rawTime converts optional date of raw doc
+func rawTime(raw any) *time.Time {
+	date, ok := raw.(primitive.DateTime)
+	if !ok {
+		return nil
+	}
+	value := date.Time()
+	return &value
+}
+
+// decodeEmbeddedList decodes array of embedded documents of raw doc, nil if it's absent
+func decodeEmbeddedList[T any](raw any) []T {
+	docs, ok := raw.(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	values := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		if value := decodeEmbedded[T](doc); value != nil {
+			values = append(values, *value)
+		}
+	}
+	return values
+}
+
+func RawDocToChatSettingsModel(raw any) *model.ChatSettings {
+	return decodeEmbedded[model.ChatSettings](raw)
+}
+
+// decodeEmbedded decodes embedded document of raw doc, nil if it's absent
+func decodeEmbedded[T any](raw any) *T {
+	doc, ok := raw.(primitive.D)
+	if !ok {
+		return nil
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+
+	var value T
+	if err := bson.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	return &value
+}
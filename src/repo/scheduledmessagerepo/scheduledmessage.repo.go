@@ -0,0 +1,192 @@
+package scheduledmessagerepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type ScheduledMessageRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewScheduledMessageRepo(db types.IDatabase) *ScheduledMessageRepo {
+	name := "scheduledMessages"
+	return &ScheduledMessageRepo{
+		name:       "scheduledMessages",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *ScheduledMessageRepo) SaveScheduled(ctx context.Context, data *model.ScheduledMessage) (primitive.ObjectID, error) {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("cannot save message into scheduledMessages collection: %w", err)
+	}
+
+	slog.Debug("saved scheduled message", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return r.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetUserScheduled returns messages the user has scheduled into the chat, soonest first
+func (repo *ScheduledMessageRepo) GetUserScheduled(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) ([]model.ScheduledMessage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sendAt", Value: 1}})
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: userID},
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve scheduled messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := make([]model.ScheduledMessage, 0)
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("cannot decode scheduled messages from cursor: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (repo *ScheduledMessageRepo) CountUserPending(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	count, err := repo.collection.CountDocuments(ctx, bson.D{
+		{Key: "user", Value: userID},
+		{Key: "status", Value: model.ScheduledStatusPending},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count scheduled messages: %w", err)
+	}
+	return count, nil
+}
+
+// UpdatePending changes message which isn't being sent yet, nil values are left as they are
+func (repo *ScheduledMessageRepo) UpdatePending(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID, id primitive.ObjectID, text *string, image *string, sendAt *time.Time) (*model.ScheduledMessage, error) {
+	fields := bson.D{{Key: "updatedAt", Value: time.Now()}}
+	if text != nil {
+		fields = append(fields, bson.E{Key: "text", Value: *text})
+	}
+	if image != nil {
+		fields = append(fields, bson.E{Key: "image", Value: *image})
+	}
+	if sendAt != nil {
+		fields = append(fields, bson.E{Key: "sendAt", Value: *sendAt})
+	}
+
+	var message *model.ScheduledMessage
+	err := repo.collection.FindOneAndUpdate(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: userID},
+		{Key: "status", Value: model.ScheduledStatusPending},
+	}, bson.D{{Key: "$set", Value: fields}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&message)
+	if err != nil || message == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || message == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot update scheduled message: %w", err)
+	}
+
+	return message, nil
+}
+
+// DeleteUnsent removes pending or failed message, one being sent right now cannot be cancelled
+func (repo *ScheduledMessageRepo) DeleteUnsent(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID, id primitive.ObjectID) error {
+	r, err := repo.collection.DeleteOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+		{Key: "user", Value: userID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{model.ScheduledStatusPending, model.ScheduledStatusFailed}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete scheduled message: %w", err)
+	}
+	if r.DeletedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+// ClaimDue atomically takes one message due to be sent (or one left by crashed replica) for lockFor
+func (repo *ScheduledMessageRepo) ClaimDue(ctx context.Context, lockFor time.Duration) (*model.ScheduledMessage, error) {
+	now := time.Now()
+
+	var message *model.ScheduledMessage
+	err := repo.collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "status", Value: model.ScheduledStatusPending},
+				{Key: "sendAt", Value: bson.D{{Key: "$lte", Value: now}}},
+			},
+			bson.D{
+				{Key: "status", Value: model.ScheduledStatusSending},
+				{Key: "lockedUntil", Value: bson.D{{Key: "$lt", Value: now}}},
+			},
+		}}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: model.ScheduledStatusSending},
+				{Key: "lockedUntil", Value: now.Add(lockFor)},
+				{Key: "updatedAt", Value: now},
+			}},
+			{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "sendAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot claim scheduled message: %w", err)
+	}
+
+	return message, nil
+}
+
+// MarkFailed keeps the message as failed for its author to see
+func (repo *ScheduledMessageRepo) MarkFailed(ctx context.Context, id primitive.ObjectID, reason string) error {
+	_, err := repo.collection.UpdateByID(ctx, id, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.ScheduledStatusFailed},
+		{Key: "lastError", Value: reason},
+		{Key: "updatedAt", Value: time.Now()},
+	}}})
+	if err != nil {
+		return fmt.Errorf("cannot update scheduled message: %w", err)
+	}
+	return nil
+}
+
+// Release leaves the message claimed with the error, it's retried when the lock expires
+func (repo *ScheduledMessageRepo) Release(ctx context.Context, id primitive.ObjectID, reason string) error {
+	_, err := repo.collection.UpdateByID(ctx, id, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "lastError", Value: reason},
+		{Key: "updatedAt", Value: time.Now()},
+	}}})
+	if err != nil {
+		return fmt.Errorf("cannot update scheduled message: %w", err)
+	}
+	return nil
+}
+
+// DeleteSent removes message once it's posted into the chat
+func (repo *ScheduledMessageRepo) DeleteSent(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}}); err != nil {
+		return fmt.Errorf("cannot delete scheduled message: %w", err)
+	}
+	return nil
+}
@@ -41,6 +41,26 @@ func (repo *TokenRepo) DeleteUserAccessTokens(ctx context.Context, userId primit
 	return nil
 }
 
+func (repo *TokenRepo) DeleteAllUserTokens(ctx context.Context, userId primitive.ObjectID) error {
+	_, err := repo.collection.DeleteMany(ctx, bson.M{"userId": userId})
+	if err != nil {
+		return fmt.Errorf("cannot delete user tokens: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserTokensExcept drops every token of the user besides the one to keep
+func (repo *TokenRepo) DeleteUserTokensExcept(ctx context.Context, userId primitive.ObjectID, keepEncoded string) error {
+	_, err := repo.collection.DeleteMany(ctx, bson.M{
+		"userId":  userId,
+		"encoded": bson.M{"$ne": keepEncoded},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete user tokens: %w", err)
+	}
+	return nil
+}
+
 func (repo *TokenRepo) SaveToken(ctx context.Context, newToken *model.Token) (string, error) {
 	r, err := repo.collection.InsertOne(ctx, newToken)
 	if err == nil {
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
 	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -32,6 +34,8 @@ func NewUserRepo(db types.IDatabase) *UserRepo {
 }
 
 func (repo *UserRepo) SaveUser(ctx context.Context, newUser *model.User) (string, error) {
+	newUser.NameLower = strings.ToLower(newUser.Name)
+
 	r, err := repo.collection.InsertOne(ctx, newUser)
 	if err == nil {
 		slog.Debug("saved user", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
@@ -116,10 +120,14 @@ func (repo *UserRepo) GetUserByIdPopulated(ctx context.Context, id string) (*mod
 		}
 	}
 
+	userModel := repohelper.RawDocToUserModel(user)
 	return &model.UserPopulated{
-		User:     repohelper.RawDocToUserModel(user),
+		User:     userModel,
+		Email:    userModel.Email,
 		Contacts: contacts,
 		Chats:    chats,
+
+		EmailVerified: userModel.EmailVerified,
 	}, nil
 }
 
@@ -140,9 +148,10 @@ func (repo *UserRepo) GetUsers(ctx context.Context, page int, limit int) (*[]mod
 	return &users, nil
 }
 
-func (repo *UserRepo) GetUserByName(ctx context.Context, name string) (*model.User, error) {
+// GetUserByHandle expects normalized handle
+func (repo *UserRepo) GetUserByHandle(ctx context.Context, handle string) (*model.User, error) {
 	var user *model.User
-	if err := repo.collection.FindOne(ctx, bson.D{{Key: "name", Value: name}}).Decode(&user); err != nil || user == nil {
+	if err := repo.collection.FindOne(ctx, bson.D{{Key: "handle", Value: handle}}).Decode(&user); err != nil || user == nil {
 		if errors.Is(err, mongo.ErrNoDocuments) || user == nil {
 			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
 		}
@@ -152,16 +161,71 @@ func (repo *UserRepo) GetUserByName(ctx context.Context, name string) (*model.Us
 	return user, nil
 }
 
-func (repo *UserRepo) AddContact(ctx context.Context, id string, name string) error {
-	contactUser, err := repo.GetUserByName(ctx, name)
-	if err != nil {
+// GetUserByLegacyName finds user migrated to handles by the name he used to log in with
+func (repo *UserRepo) GetUserByLegacyName(ctx context.Context, name string) (*model.User, error) {
+	var user *model.User
+	if err := repo.collection.FindOne(ctx, bson.D{{Key: "legacyName", Value: name}}).Decode(&user); err != nil || user == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || user == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve user from users collection: %w", err)
+	}
+
+	return user, nil
+}
+
+// AddMutualContacts makes both users contacts of each other
+func (repo *UserRepo) AddMutualContacts(ctx context.Context, id1 primitive.ObjectID, id2 primitive.ObjectID) error {
+	r, err := repo.collection.UpdateByID(ctx, id1, bson.M{"$addToSet": bson.M{"contacts": id2}})
+	if err = handleUpdateError(err, matchedCount(r), id1.Hex()); err != nil {
 		return err
 	}
 
-	_id, _ := primitive.ObjectIDFromHex(id)
-	r, err := repo.collection.UpdateByID(ctx, _id, bson.M{"$addToSet": bson.M{"contacts": contactUser.ID}})
+	r, err = repo.collection.UpdateByID(ctx, id2, bson.M{"$addToSet": bson.M{"contacts": id1}})
+
+	return handleUpdateError(err, matchedCount(r), id2.Hex())
+}
+
+// RemoveMutualContacts drops both users from contacts of each other
+func (repo *UserRepo) RemoveMutualContacts(ctx context.Context, id1 primitive.ObjectID, id2 primitive.ObjectID) error {
+	r, err := repo.collection.UpdateByID(ctx, id1, bson.M{"$pull": bson.M{"contacts": id2}})
+	if err = handleUpdateError(err, matchedCount(r), id1.Hex()); err != nil {
+		return err
+	}
+
+	r, err = repo.collection.UpdateByID(ctx, id2, bson.M{"$pull": bson.M{"contacts": id1}})
+
+	return handleUpdateError(err, matchedCount(r), id2.Hex())
+}
+
+func (repo *UserRepo) AddToBlocked(ctx context.Context, id primitive.ObjectID, blockedID primitive.ObjectID) error {
+	r, err := repo.collection.UpdateByID(ctx, id, bson.M{"$addToSet": bson.M{"blocked": blockedID}})
+
+	return handleUpdateError(err, matchedCount(r), id.Hex())
+}
+
+func (repo *UserRepo) RemoveFromBlocked(ctx context.Context, id primitive.ObjectID, blockedID primitive.ObjectID) error {
+	r, err := repo.collection.UpdateByID(ctx, id, bson.M{"$pull": bson.M{"blocked": blockedID}})
+
+	return handleUpdateError(err, matchedCount(r), id.Hex())
+}
+
+func (repo *UserRepo) GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]model.User, error) {
+	cursor, err := repo.collection.Find(ctx, bson.D{{
+		Key:   "_id",
+		Value: bson.D{{Key: "$in", Value: ids}},
+	}}, options.Find().SetProjection(bson.D{{Key: "hash", Value: 0}}))
+	if err != nil || cursor == nil {
+		return nil, fmt.Errorf("cannot retrieve users from collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]model.User, 0, len(ids))
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("cannot decode users from cursor: %w", err)
+	}
 
-	return handleUpdateError(err, r.MatchedCount, id)
+	return users, nil
 }
 
 func (repo *UserRepo) UpdateUser(ctx context.Context, id primitive.ObjectID, keyValueMap map[string]any) error {
@@ -171,13 +235,164 @@ func (repo *UserRepo) UpdateUser(ctx context.Context, id primitive.ObjectID, key
 		setData[i] = primitive.E{Key: Key, Value: Value}
 		i++
 	}
+	if name, ok := keyValueMap["name"].(string); ok {
+		setData = append(setData, primitive.E{Key: "nameLower", Value: strings.ToLower(name)})
+	}
 
 	r, err := repo.collection.UpdateByID(ctx, id, bson.D{{
 		Key:   "$set",
 		Value: setData,
 	}})
+	if err != nil && strings.Contains(err.Error(), "duplicate key error collection") {
+		return errors.Join(cmnerr.ErrUniqueViolation, err)
+	}
+
+	return handleUpdateError(err, matchedCount(r), id.Hex())
+}
+
+func matchedCount(r *mongo.UpdateResult) int64 {
+	if r == nil {
+		return 0
+	}
+	return r.MatchedCount
+}
 
-	return handleUpdateError(err, r.MatchedCount, id.String())
+func (repo *UserRepo) UnsetUserFields(ctx context.Context, id primitive.ObjectID, keys ...string) error {
+	unsetData := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		unsetData = append(unsetData, primitive.E{Key: key, Value: ""})
+	}
+
+	r, err := repo.collection.UpdateByID(ctx, id, bson.D{{
+		Key:   "$unset",
+		Value: unsetData,
+	}})
+
+	return handleUpdateError(err, matchedCount(r), id.Hex())
+}
+
+func (repo *UserRepo) GetUserByIdentity(ctx context.Context, issuer string, subject string) (*model.User, error) {
+	var user *model.User
+	err := repo.collection.FindOne(ctx, bson.D{{
+		Key: "identities",
+		Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "issuer", Value: issuer},
+			{Key: "subject", Value: subject},
+		}}},
+	}}).Decode(&user)
+	if err != nil || user == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || user == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve user from users collection: %w", err)
+	}
+
+	return user, nil
+}
+
+func (repo *UserRepo) AddIdentity(ctx context.Context, id primitive.ObjectID, identity model.ExternalIdentity) error {
+	r, err := repo.collection.UpdateByID(ctx, id, bson.D{{
+		Key:   "$push",
+		Value: bson.D{{Key: "identities", Value: identity}},
+	}})
+	if err != nil && strings.Contains(err.Error(), "duplicate key error collection") {
+		return errors.Join(cmnerr.ErrUniqueViolation, err)
+	}
+
+	return handleUpdateError(err, matchedCount(r), id.Hex())
+}
+
+func (repo *UserRepo) GetBotsByOwner(ctx context.Context, ownerID primitive.ObjectID) ([]model.User, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetProjection(bson.D{{Key: "hash", Value: 0}, {Key: "contacts", Value: 0}, {Key: "chats", Value: 0}})
+	cursor, err := repo.collection.Find(ctx, bson.D{
+		{Key: "isBot", Value: true},
+		{Key: "owner", Value: ownerID},
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve bots from users collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	bots := make([]model.User, 0)
+	if err = cursor.All(ctx, &bots); err != nil {
+		return nil, fmt.Errorf("cannot decode users from cursor: %w", err)
+	}
+
+	return bots, nil
+}
+
+// ReplaceUserHash swaps password hash only if it's still the old one
+func (repo *UserRepo) ReplaceUserHash(ctx context.Context, id primitive.ObjectID, oldHash string, newHash string) error {
+	_, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "hash", Value: oldHash},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "hash", Value: newHash}},
+	}})
+	if err != nil {
+		return fmt.Errorf("cannot update user of users collection: %w", err)
+	}
+	return nil
+}
+
+// ConsumeTotpStep remembers accepted TOTP time step; false means the step (or later one) was used already
+func (repo *UserRepo) ConsumeTotpStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "twoFactor.lastUsedStep", Value: bson.D{{Key: "$lt", Value: step}}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "twoFactor.lastUsedStep", Value: step}},
+	}})
+	if err != nil {
+		return false, fmt.Errorf("cannot update user of users collection: %w", err)
+	}
+	return r.ModifiedCount > 0, nil
+}
+
+// ConsumeRecoveryCode burns hashed recovery code; false means there was no such code
+func (repo *UserRepo) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) (bool, error) {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "twoFactor.recoveryCodes", Value: codeHash},
+	}, bson.D{{
+		Key:   "$pull",
+		Value: bson.D{{Key: "twoFactor.recoveryCodes", Value: codeHash}},
+	}})
+	if err != nil {
+		return false, fmt.Errorf("cannot update user of users collection: %w", err)
+	}
+	return r.ModifiedCount > 0, nil
+}
+
+// GetUserByVerifiedEmail finds the only user who has confirmed the email, expects it normalized (lowercased)
+func (repo *UserRepo) GetUserByVerifiedEmail(ctx context.Context, email string) (*model.User, error) {
+	var user *model.User
+	filter := bson.D{{Key: "email", Value: email}, {Key: "emailVerified", Value: true}}
+	if err := repo.collection.FindOne(ctx, filter).Decode(&user); err != nil || user == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || user == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve user from users collection: %w", err)
+	}
+
+	return user, nil
+}
+
+// UnsetUnverifiedEmails drops unconfirmed claims of other users on the email its owner has just confirmed
+func (repo *UserRepo) UnsetUnverifiedEmails(ctx context.Context, email string, ownerID primitive.ObjectID) error {
+	_, err := repo.collection.UpdateMany(ctx, bson.D{
+		{Key: "email", Value: email},
+		{Key: "emailVerified", Value: bson.D{{Key: "$ne", Value: true}}},
+		{Key: "_id", Value: bson.D{{Key: "$ne", Value: ownerID}}},
+	}, bson.D{{Key: "$unset", Value: bson.D{{Key: "email", Value: ""}, {Key: "emailVerified", Value: ""}}}})
+	if err != nil {
+		return fmt.Errorf("cannot unset unverified emails of users collection: %w", err)
+	}
+	return nil
 }
 
 func handleUpdateError(err error, matchedCount int64, id string) error {
@@ -260,19 +475,59 @@ func (repo *UserRepo) AddChatIdToUsers(ctx context.Context, chatId primitive.Obj
 	return handleUpdateError(err, r.MatchedCount, id2)
 }
 
-func (repo *UserRepo) GetAllUsers(ctx context.Context, userID string) ([]model.User, error) {
-	_userId, _ := primitive.ObjectIDFromHex(userID)
+func (repo *UserRepo) AddChatIdToUser(ctx context.Context, chatId primitive.ObjectID, id primitive.ObjectID) error {
+	r, err := repo.collection.UpdateByID(ctx, id, bson.M{"$addToSet": bson.M{"chats": chatId}})
+	return handleUpdateError(err, r.MatchedCount, id.Hex())
+}
 
-	cursor, err := repo.collection.Find(ctx, bson.D{{
-		Key:   "_id",
-		Value: bson.D{{Key: "$ne", Value: _userId}},
+// SetBotCommands replaces commands the bot handles together with their callback
+func (repo *UserRepo) SetBotCommands(ctx context.Context, botID primitive.ObjectID, commands *model.BotCommands) error {
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: botID},
+		{Key: "isBot", Value: true},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "botCommands", Value: commands},
+			{Key: "updatedAt", Value: time.Now()},
+		},
 	}})
+	if err != nil {
+		return fmt.Errorf("cannot update bot commands: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+// SearchUsersByPrefix finds discoverable users by lowercased name or normalized handle prefix,
+// skipping the searcher himself and anyone who is blocked by or blocks him
+func (repo *UserRepo) SearchUsersByPrefix(ctx context.Context, searcher *model.User, namePrefix string, handlePrefix string, limit int) ([]model.User, error) {
+	excludedIDs := append([]primitive.ObjectID{searcher.ID}, searcher.Blocked...)
+
+	filter := bson.D{
+		{Key: "$or", Value: []bson.D{
+			// both are stored normalized, so anchored case-sensitive prefixes can use the indices
+			{{Key: "nameLower", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(namePrefix)}}},
+			{{Key: "handle", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(handlePrefix)}}},
+		}},
+		{Key: "_id", Value: bson.D{{Key: "$nin", Value: excludedIDs}}},
+		{Key: "blocked", Value: bson.D{{Key: "$ne", Value: searcher.ID}}},
+		{Key: "discoverable", Value: bson.D{{Key: "$ne", Value: false}}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "nameLower", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.D{{Key: "hash", Value: 0}, {Key: "contacts", Value: 0}, {Key: "chats", Value: 0}})
+
+	cursor, err := repo.collection.Find(ctx, filter, opts)
 	if err != nil || cursor == nil {
-		return nil, fmt.Errorf("cannot retrieve users from collection: %w", err)
+		return nil, fmt.Errorf("cannot search users in collection: %w", err)
 	}
 	defer cursor.Close(ctx)
 
-	var users []model.User
+	users := make([]model.User, 0, limit)
 	if err = cursor.All(ctx, &users); err != nil {
 		return nil, fmt.Errorf("cannot decode users from cursor: %w", err)
 	}
@@ -0,0 +1,164 @@
+package webhookdeliveryrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type WebhookDeliveryRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewWebhookDeliveryRepo(db types.IDatabase) *WebhookDeliveryRepo {
+	name := "webhookDeliveries"
+	return &WebhookDeliveryRepo{
+		name:       "webhookDeliveries",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *WebhookDeliveryRepo) SaveDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	docs := make([]any, len(deliveries))
+	for i := range deliveries {
+		docs[i] = deliveries[i]
+	}
+
+	if _, err := repo.collection.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("cannot save deliveries into webhookDeliveries collection: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue atomically takes one due delivery (or one left by crashed worker) for lockFor
+func (repo *WebhookDeliveryRepo) ClaimDue(ctx context.Context, lockFor time.Duration) (*model.WebhookDelivery, error) {
+	now := time.Now()
+
+	var delivery *model.WebhookDelivery
+	err := repo.collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "status", Value: model.DeliveryStatusPending},
+				{Key: "nextAttemptAt", Value: bson.D{{Key: "$lte", Value: now}}},
+			},
+			bson.D{
+				{Key: "status", Value: model.DeliveryStatusDelivering},
+				{Key: "lockedUntil", Value: bson.D{{Key: "$lt", Value: now}}},
+			},
+		}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.DeliveryStatusDelivering},
+			{Key: "lockedUntil", Value: now.Add(lockFor)},
+			{Key: "updatedAt", Value: now},
+		}}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot claim webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// FinishAttempt stores result of delivery attempt and sets its next state,
+// finished deliveries are kept as log for model.DeliveryLogRetention only
+func (repo *WebhookDeliveryRepo) FinishAttempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	delivery.UpdatedAt = time.Now()
+	delivery.ExpiresAt = nil
+	if delivery.Status == model.DeliveryStatusSucceeded || delivery.Status == model.DeliveryStatusDead {
+		expiresAt := delivery.UpdatedAt.Add(model.DeliveryLogRetention)
+		delivery.ExpiresAt = &expiresAt
+	}
+	_, err := repo.collection.UpdateByID(ctx, delivery.ID, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: delivery.Status},
+		{Key: "attempts", Value: delivery.Attempts},
+		{Key: "nextAttemptAt", Value: delivery.NextAttemptAt},
+		{Key: "lastStatusCode", Value: delivery.LastStatusCode},
+		{Key: "lastError", Value: delivery.LastError},
+		{Key: "deliveredAt", Value: delivery.DeliveredAt},
+		{Key: "expiresAt", Value: delivery.ExpiresAt},
+		{Key: "updatedAt", Value: delivery.UpdatedAt},
+	}}})
+	if err != nil {
+		return fmt.Errorf("cannot update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Redeliver puts dead or succeeded delivery back into the queue
+func (repo *WebhookDeliveryRepo) Redeliver(ctx context.Context, webhookID primitive.ObjectID, id primitive.ObjectID) error {
+	now := time.Now()
+	r, err := repo.collection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "webhook", Value: webhookID},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{model.DeliveryStatusDead, model.DeliveryStatusSucceeded}}}},
+	}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.DeliveryStatusPending},
+		{Key: "attempts", Value: 0},
+		{Key: "nextAttemptAt", Value: now},
+		{Key: "updatedAt", Value: now},
+	}}, {Key: "$unset", Value: bson.D{{Key: "expiresAt", Value: ""}}}})
+	if err != nil {
+		return fmt.Errorf("cannot requeue webhook delivery: %w", err)
+	}
+	if r.MatchedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+func (repo *WebhookDeliveryRepo) GetDeliveries(ctx context.Context, webhookID primitive.ObjectID, params ...any) ([]model.WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if len(params) > 0 {
+		if pgParams, ok := params[0].(types.PaginationParams); ok && pgParams.Limit > 0 {
+			opts.SetSkip(int64((max(pgParams.Page, 1) - 1) * pgParams.Limit)).SetLimit(int64(pgParams.Limit))
+		}
+	}
+
+	cursor, err := repo.collection.Find(ctx, bson.D{{Key: "webhook", Value: webhookID}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve webhook deliveries: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := make([]model.WebhookDelivery, 0)
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, fmt.Errorf("cannot decode webhook deliveries from cursor: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (repo *WebhookDeliveryRepo) DeleteWebhookDeliveries(ctx context.Context, webhookID primitive.ObjectID) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "webhook", Value: webhookID}}); err != nil {
+		return fmt.Errorf("cannot delete webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredPayloads removes deliveries carrying disappeared messages, TTL monitor removes them as well but later
+func (repo *WebhookDeliveryRepo) DeleteExpiredPayloads(ctx context.Context, now time.Time) error {
+	if _, err := repo.collection.DeleteMany(ctx, bson.D{{Key: "payloadExpiresAt", Value: bson.D{{Key: "$lte", Value: now}}}}); err != nil {
+		return fmt.Errorf("cannot delete webhook deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package webhookrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+type WebhookRepo struct {
+	name       string
+	collection *mongo.Collection
+}
+
+func NewWebhookRepo(db types.IDatabase) *WebhookRepo {
+	name := "webhooks"
+	return &WebhookRepo{
+		name:       "webhooks",
+		collection: db.GetCollection(name),
+	}
+}
+
+func (repo *WebhookRepo) SaveWebhook(ctx context.Context, data *model.Webhook) (primitive.ObjectID, error) {
+	r, err := repo.collection.InsertOne(ctx, data)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("cannot save webhook into webhooks collection: %w", err)
+	}
+
+	slog.Debug("saved webhook", slog.String("ID", r.InsertedID.(primitive.ObjectID).String()))
+	return r.InsertedID.(primitive.ObjectID), nil
+}
+
+func (repo *WebhookRepo) GetChatWebhook(ctx context.Context, chatID primitive.ObjectID, id primitive.ObjectID) (*model.Webhook, error) {
+	var webhook *model.Webhook
+	err := repo.collection.FindOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+	}).Decode(&webhook)
+	if err != nil || webhook == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || webhook == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve webhook from webhooks collection: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (repo *WebhookRepo) GetWebhookByID(ctx context.Context, id primitive.ObjectID) (*model.Webhook, error) {
+	var webhook *model.Webhook
+	err := repo.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&webhook)
+	if err != nil || webhook == nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || webhook == nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		return nil, fmt.Errorf("cannot retrieve webhook from webhooks collection: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetChatWebhooks returns webhooks of the chat, only ones subscribed to the event if it's given
+func (repo *WebhookRepo) GetChatWebhooks(ctx context.Context, chatID primitive.ObjectID, event string) ([]model.Webhook, error) {
+	filter := bson.D{{Key: "chat", Value: chatID}}
+	if event != "" {
+		filter = append(filter, bson.E{Key: "events", Value: event})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := repo.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve webhooks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := make([]model.Webhook, 0)
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		return nil, fmt.Errorf("cannot decode webhooks from cursor: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (repo *WebhookRepo) CountChatWebhooks(ctx context.Context, chatID primitive.ObjectID) (int64, error) {
+	count, err := repo.collection.CountDocuments(ctx, bson.D{{Key: "chat", Value: chatID}})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count webhooks: %w", err)
+	}
+	return count, nil
+}
+
+func (repo *WebhookRepo) DeleteWebhook(ctx context.Context, chatID primitive.ObjectID, id primitive.ObjectID) error {
+	r, err := repo.collection.DeleteOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "chat", Value: chatID},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete webhook: %w", err)
+	}
+	if r.DeletedCount == 0 {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
@@ -32,11 +32,11 @@ func SetupRouter() chi.Router {
 	r.Use(httplog.RequestLogger(customerLogger, logger.LogPathsToSkip))
 
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(customMiddleware.Timeout(60*time.Second, TimeoutPathsToSkip))
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
@@ -61,3 +61,8 @@ func SetupRouter() chi.Router {
 
 	return r
 }
+
+// TimeoutPathsToSkip are long-lived event streams, they end when client disconnects
+var TimeoutPathsToSkip = []string{
+	"/api/events",
+}
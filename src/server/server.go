@@ -13,6 +13,7 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
 	"github.com/MykolaSainiuk/schatgo/src/db"
 	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
 	"github.com/MykolaSainiuk/schatgo/src/server/router"
 )
 
@@ -83,6 +84,12 @@ func init() {
 		slog.Error("cannot load jwt data")
 		os.Exit(1)
 	}
+
+	// validate password hashing params
+	if err := pwdhelper.InitHashParams(); err != nil {
+		slog.Error("cannot load password hash params", slog.Any("error", err.Error()))
+		os.Exit(1)
+	}
 }
 
 func getEnvFilePath() string {
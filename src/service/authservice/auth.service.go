@@ -3,7 +3,11 @@ package authservice
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,26 +15,76 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/api/dto"
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
 	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/mailhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
 	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/loginattemptrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/oidcstaterepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/passwordresetrepo"
 	"github.com/MykolaSainiuk/schatgo/src/repo/tokenrepo"
 	"github.com/MykolaSainiuk/schatgo/src/repo/userrepo"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
 )
 
 type AuthService struct {
 	userRepo  *userrepo.UserRepo
 	tokenRepo *tokenrepo.TokenRepo
+	resetRepo *passwordresetrepo.PasswordResetRepo
+
+	loginAttemptRepo *loginattemptrepo.LoginAttemptRepo
+	oidcStateRepo    *oidcstaterepo.OidcStateRepo
+	oidcProvider     *oidchelper.Provider
+
+	userService *userservice.UserService
 }
 
 func NewAuthService(srv types.IServer) *AuthService {
 	return &AuthService{
 		userRepo:  userrepo.NewUserRepo(srv.GetDB()),
 		tokenRepo: tokenrepo.NewTokenRepo(srv.GetDB()),
+		resetRepo: passwordresetrepo.NewPasswordResetRepo(srv.GetDB()),
+
+		loginAttemptRepo: loginattemptrepo.NewLoginAttemptRepo(srv.GetDB()),
+		oidcStateRepo:    oidcstaterepo.NewOidcStateRepo(srv.GetDB()),
+		oidcProvider:     oidchelper.GetProvider(),
+
+		userService: userservice.NewUserService(srv),
 	}
 }
 
 func (service *AuthService) RegisterNewUser(ctx context.Context, dto *dto.RegisterInputDto) (string, error) {
+	if err := pwdhelper.CheckPasswordPolicy(dto.Password); err != nil {
+		return "", err
+	}
+
+	var handle string
+	if dto.Handle != "" {
+		handle = handlehelper.Normalize(dto.Handle)
+		if !handlehelper.IsValid(handle) {
+			return "", userservice.ErrInvalidHandle
+		}
+		// former handles stay reserved for redirects
+		_, err := service.userService.GetUserByHandle(ctx, handle)
+		if err == nil {
+			return "", cmnerr.ErrUniqueViolation
+		}
+		if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return "", err
+		}
+	} else {
+		var err error
+		if handle, err = service.userService.GenerateHandle(ctx, dto.Name); err != nil {
+			return "", err
+		}
+	}
+
+	// email stays unconfirmed whoever owns it, so registration doesn't reveal who is registered
+	email := userservice.NormalizeEmail(dto.Email)
+
 	hash, err := pwdhelper.HashPassword(dto.Password)
 	if err != nil {
 		slog.Error("failed to generate hash", slog.Any("error", err))
@@ -38,11 +92,16 @@ func (service *AuthService) RegisterNewUser(ctx context.Context, dto *dto.Regist
 	}
 
 	newUser := &model.User{
+		Handle:    handle,
 		Name:      dto.Name,
 		AvatarUri: dto.AvatarUri,
+		Email:     email,
 
 		Hash: hash,
 
+		Discoverable: true,
+		Blocked:      make([]primitive.ObjectID, 0),
+
 		Contacts: make([]primitive.ObjectID, 0),
 		Chats:    make([]primitive.ObjectID, 0),
 
@@ -51,23 +110,92 @@ func (service *AuthService) RegisterNewUser(ctx context.Context, dto *dto.Regist
 	}
 
 	newUserId, err := service.userRepo.SaveUser(ctx, newUser)
+	if err != nil {
+		return "", err
+	}
+
+	if email != "" {
+		newUser.ID, _ = primitive.ObjectIDFromHex(newUserId)
+		// registration succeeds anyway, the letter can be requested again
+		if err = service.userService.SendEmailConfirmation(ctx, newUser); err != nil {
+			slog.Error("cannot send email confirmation", slog.String("userId", newUserId), slog.Any("error", err))
+		}
+	}
 
-	return newUserId, err
+	return newUserId, nil
 }
 
-func (service *AuthService) LoginUser(ctx context.Context, name string, rawPassword string) (string, error) {
-	user, err := service.userRepo.GetUserByName(ctx, name)
+// LoginUser checks credentials and issues access token,
+// or only 2FA challenge token if User has two-factor authentication enabled.
+// Failed attempts are counted per account from client IP & per client IP, see LoginLockedError
+func (service *AuthService) LoginUser(ctx context.Context, handle string, rawPassword string, clientIP string) (*dto.LoginOutputDto, error) {
+	guardKeys := loginGuardKeys(handle, clientIP)
+	if err := service.checkLoginLock(ctx, guardKeys); err != nil {
+		return nil, err
+	}
+
+	user, err := service.getUserByLogin(ctx, handle)
 	if err != nil {
-		slog.Info("no such user found by name")
-		return "", err
+		slog.Info("no such user found by handle")
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			service.registerLoginFailure(ctx, guardKeys)
+		}
+		return nil, err
 	}
 
 	if !pwdhelper.CheckPasswordHash(rawPassword, user.Hash) {
 		slog.Info("bad password")
-		return "", cmnerr.ErrHashMismatch
+		service.registerLoginFailure(ctx, guardKeys)
+		return nil, cmnerr.ErrHashMismatch
+	}
+	service.resetLoginFailures(ctx, guardKeys)
+	service.rehashIfOutdated(ctx, user, rawPassword)
+
+	return service.completeFirstFactor(ctx, user)
+}
+
+// completeFirstFactor issues access token, or only 2FA challenge token if User has two-factor authentication enabled
+func (service *AuthService) completeFirstFactor(ctx context.Context, user *model.User) (*dto.LoginOutputDto, error) {
+	if user.HasTwoFactor() {
+		challengeToken, err := jwthelper.GenerateChallengeToken(user.ID.Hex(), user.Handle)
+		if err != nil {
+			slog.Error("failed to generate challenge token", slog.Any("error", err))
+			return nil, errors.Join(cmnerr.ErrGenerateAccessToken, err)
+		}
+		return &dto.LoginOutputDto{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		}, nil
+	}
+
+	accessToken, err := service.issueAccessToken(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginOutputDto{AccessToken: accessToken}, nil
+}
+
+// rehashIfOutdated upgrades hash made by old algorithm or parameters while raw password is at hand
+func (service *AuthService) rehashIfOutdated(ctx context.Context, user *model.User, rawPassword string) {
+	if !pwdhelper.NeedsRehash(user.Hash) {
+		return
 	}
 
-	accessToken, err := jwthelper.GenerateToken(user.ID.Hex(), user.Name)
+	hash, err := pwdhelper.HashPassword(rawPassword)
+	if err != nil {
+		slog.Error("failed to rehash password", slog.Any("error", err))
+		return
+	}
+	// compare-and-set not to overwrite password changed meanwhile
+	if err = service.userRepo.ReplaceUserHash(ctx, user.ID, user.Hash, hash); err != nil {
+		slog.Error("failed to store rehashed password", slog.Any("error", err))
+		return
+	}
+	slog.Info("password rehashed", slog.String("userId", user.ID.Hex()))
+}
+
+func (service *AuthService) issueAccessToken(ctx context.Context, user *model.User) (string, error) {
+	accessToken, err := jwthelper.GenerateToken(user.ID.Hex(), user.Handle)
 	if err != nil {
 		slog.Error("failed to generate token", slog.Any("error", err))
 		return "", errors.Join(cmnerr.ErrGenerateAccessToken, err)
@@ -77,12 +205,189 @@ func (service *AuthService) LoginUser(ctx context.Context, name string, rawPassw
 	if err != nil {
 		return "", err
 	}
+	publishTokensRevoked(user.ID)
 	_, err = service.tokenRepo.SaveToken(ctx, &model.Token{
 		UserId:   user.ID,
-		Username: user.Name,
+		Username: user.Handle,
 		Type:     model.TokenTypeAccess,
 		Encoded:  accessToken,
 	})
 
 	return accessToken, err
 }
+
+// ChangePassword sets new password and revokes every session but the current one.
+// Users without password (signed up via OIDC) set the first one by reauthToken
+func (service *AuthService) ChangePassword(ctx context.Context, userID string, currentToken string, rawPassword string, reauthToken string, newRawPassword string) error {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = userservice.CheckCurrentPassword(user, rawPassword, reauthToken); err != nil {
+		return err
+	}
+	if err = pwdhelper.CheckPasswordPolicy(newRawPassword); err != nil {
+		return err
+	}
+	if rawPassword == newRawPassword {
+		return ErrSamePassword
+	}
+
+	hash, err := pwdhelper.HashPassword(newRawPassword)
+	if err != nil {
+		slog.Error("failed to generate hash", slog.Any("error", err))
+		return errors.Join(cmnerr.ErrHashGeneration, err)
+	}
+
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"hash":      hash,
+		"updatedAt": time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	if err = service.tokenRepo.DeleteUserTokensExcept(ctx, user.ID, currentToken); err != nil {
+		return err
+	}
+	publishTokensRevoked(user.ID)
+
+	return nil
+}
+
+// getUserByLogin finds user by handle, falling back to the former name of users migrated to handles
+func (service *AuthService) getUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	user, err := service.userRepo.GetUserByHandle(ctx, handlehelper.Normalize(login))
+	if err == nil || !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return user, err
+	}
+	return service.userRepo.GetUserByLegacyName(ctx, login)
+}
+
+// IsTokenActive tells whether access token is neither expired nor revoked
+func (service *AuthService) IsTokenActive(ctx context.Context, accessToken string) bool {
+	if _, err := jwthelper.VerifyToken(accessToken); err != nil {
+		return false
+	}
+	ok, err := service.tokenRepo.ExistToken(ctx, &accessToken)
+	return err == nil && ok
+}
+
+// publishTokensRevoked lets open event streams of the user re-check their tokens right away
+func publishTokensRevoked(userID primitive.ObjectID) {
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventTokensRevoked,
+		Recipients: []primitive.ObjectID{userID},
+	})
+}
+
+// RequestPasswordReset mails one-time reset token to the owner of confirmed email.
+// Unknown & unconfirmed emails are ignored silently not to reveal who is registered
+func (service *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := service.userRepo.GetUserByVerifiedEmail(ctx, userservice.NormalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			slog.Info("password reset requested for unknown or unconfirmed email")
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	recent, err := service.resetRepo.CountRecentResets(ctx, user.ID, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent >= MaxResetsPerHour {
+		slog.Info("too many password resets requested", slog.String("userId", user.ID.Hex()))
+		return nil
+	}
+
+	token, err := pwdhelper.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	lifetime := getResetTokenLifetime()
+	if err = service.resetRepo.SavePasswordReset(ctx, &model.PasswordReset{
+		User:      user.ID,
+		TokenHash: pwdhelper.HashToken(token),
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	return mailhelper.GetMailer().Send(ctx, mailhelper.Mail{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nsomeone (hopefully you) asked to reset your password.\n"+
+				"Use the link below within %d minutes, it works only once:\n\n%s\n\n"+
+				"If it wasn't you just ignore this letter.\n",
+			user.Name, int(lifetime.Minutes()), resetLink(token),
+		),
+	})
+}
+
+// ResetPassword sets new password by reset token and logs User out everywhere
+func (service *AuthService) ResetPassword(ctx context.Context, token string, newRawPassword string) error {
+	if err := pwdhelper.CheckPasswordPolicy(newRawPassword); err != nil {
+		return err
+	}
+
+	reset, err := service.resetRepo.UseToken(ctx, pwdhelper.HashToken(token))
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hash, err := pwdhelper.HashPassword(newRawPassword)
+	if err != nil {
+		slog.Error("failed to generate hash", slog.Any("error", err))
+		return errors.Join(cmnerr.ErrHashGeneration, err)
+	}
+
+	if err = service.userRepo.UpdateUser(ctx, reset.User, map[string]any{
+		"hash":      hash,
+		"updatedAt": time.Now(),
+	}); err != nil {
+		return err
+	}
+	if err = service.resetRepo.InvalidateUserResets(ctx, reset.User); err != nil {
+		return err
+	}
+
+	return service.tokenRepo.DeleteAllUserTokens(ctx, reset.User)
+}
+
+func getResetTokenLifetime() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TOKEN_LIFETIME_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func resetLink(token string) string {
+	base := os.Getenv("PASSWORD_RESET_URL")
+	if base == "" {
+		return token
+	}
+	link, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+const MaxResetsPerHour int64 = 5
+
+var (
+	ErrInvalidResetToken = errors.New("reset token is invalid, expired or already used")
+	ErrSamePassword      = errors.New("new password must differ from the current one")
+)
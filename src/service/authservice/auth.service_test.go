@@ -0,0 +1,72 @@
+package authservice
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+)
+
+func TestLoginUserRehashesOutdatedHash(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	if !jwthelper.InitJwtData() {
+		t.Fatal("cannot init jwt data")
+	}
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	legacyParams := pwdhelper.GetHashParams()
+	legacyParams.Algo, legacyParams.BcryptCost = pwdhelper.AlgoBcrypt, 4
+	legacyHash, err := pwdhelper.HashPasswordWith("password", legacyParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt.Run("upgrades hash with compare-and-set", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			// no login attempts
+			mtest.CreateCursorResponse(0, "schat.loginAttempts", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "handle", Value: "alice"},
+				{Key: "hash", Value: legacyHash},
+			}),
+			// reset login attempts, rehash, drop old tokens, save new token
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewAuthService(testhelper.NewServer(mt))
+		output, err := service.LoginUser(context.Background(), "alice", "password", "10.0.0.1")
+		if err != nil || output.AccessToken == "" {
+			mt.Fatalf("expected access token, got %v, error %v", output, err)
+		}
+
+		var update bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "update" && event.Command.Lookup("update").StringValue() == "users" {
+				update = event.Command
+			}
+		}
+		if update == nil {
+			mt.Fatal("expected outdated hash to be replaced")
+		}
+
+		statement := update.Lookup("updates", "0").Document()
+		if oldHash := statement.Lookup("q", "hash").StringValue(); oldHash != legacyHash {
+			mt.Errorf("expected update to be conditional on old hash, got %q", oldHash)
+		}
+		newHash := statement.Lookup("u", "$set", "hash").StringValue()
+		if !pwdhelper.CheckPasswordHash("password", newHash) || pwdhelper.NeedsRehash(newHash) {
+			mt.Errorf("expected new hash with current params, got %q", newHash)
+		}
+	})
+}
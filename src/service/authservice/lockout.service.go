@@ -0,0 +1,126 @@
+package authservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// LoginLockedError is returned while account (from the client IP) or client IP itself
+// is locked out after failed logins
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLoginLocked.Error(), e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
+
+type loginGuardKey struct {
+	key          string
+	freeAttempts int
+}
+
+// loginGuardKeys counts account failures per client IP, so guessing from one address
+// doesn't lock the owner out from theirs; IP key throttles guessing across accounts
+func loginGuardKeys(handle string, clientIP string) []loginGuardKey {
+	keys := []loginGuardKey{{
+		key:          model.LoginAttemptKeyAccount + handlehelper.Normalize(handle) + "@" + clientIP,
+		freeAttempts: AccountFreeAttempts,
+	}}
+	if clientIP != "" {
+		keys = append(keys, loginGuardKey{
+			key:          model.LoginAttemptKeyIP + clientIP,
+			freeAttempts: IPFreeAttempts,
+		})
+	}
+	return keys
+}
+
+// checkLoginLock must be called before any password hashing, so locked out attempts cost nothing
+func (service *AuthService) checkLoginLock(ctx context.Context, keys []loginGuardKey) error {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.key
+	}
+
+	attempts, err := service.loginAttemptRepo.GetAttempts(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	now := time.Now()
+	for _, attempt := range attempts {
+		if left := attempt.LockedUntil.Sub(now); left > retryAfter {
+			retryAfter = left
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// registerLoginFailure counts the failure for every key and locks ones over their free attempts
+// for exponentially growing time
+func (service *AuthService) registerLoginFailure(ctx context.Context, keys []loginGuardKey) {
+	now := time.Now()
+	for _, k := range keys {
+		attempt, err := service.loginAttemptRepo.AddFailure(ctx, k.key, now, now.Add(LoginAttemptsMemory))
+		if err != nil || attempt == nil {
+			slog.Error("cannot register failed login", slog.Any("error", err))
+			continue
+		}
+		if attempt.Failures < k.freeAttempts {
+			continue
+		}
+
+		lock := lockDuration(attempt.Failures - k.freeAttempts)
+		if err = service.loginAttemptRepo.LockUntil(ctx, k.key, now.Add(lock), now.Add(lock+LoginAttemptsMemory)); err != nil {
+			slog.Error("cannot lock login", slog.Any("error", err))
+			continue
+		}
+		slog.Warn("login locked out", slog.String("key", k.key), slog.Int("failures", attempt.Failures), slog.Duration("for", lock))
+	}
+}
+
+// resetLoginFailures forgets failures of the account from the IP; IP counter is kept not to let
+// attacker reset it by logging into own account in between
+func (service *AuthService) resetLoginFailures(ctx context.Context, keys []loginGuardKey) {
+	if err := service.loginAttemptRepo.ResetAttempts(ctx, keys[0].key); err != nil {
+		slog.Error("cannot reset failed logins", slog.Any("error", err))
+	}
+}
+
+func lockDuration(overLimit int) time.Duration {
+	if overLimit > 16 {
+		return MaxLockDuration
+	}
+	lock := time.Duration(float64(BaseLockDuration) * math.Pow(2, float64(overLimit)))
+	return min(lock, MaxLockDuration)
+}
+
+const (
+	AccountFreeAttempts = 5
+	IPFreeAttempts      = 20
+
+	BaseLockDuration = 30 * time.Second
+	MaxLockDuration  = 15 * time.Minute
+	// LoginAttemptsMemory is quiet period after which failures are forgotten
+	LoginAttemptsMemory = time.Hour
+)
+
+var (
+	ErrLoginLocked = errors.New("too many failed login attempts")
+)
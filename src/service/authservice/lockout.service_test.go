@@ -0,0 +1,32 @@
+package authservice
+
+import (
+	"testing"
+)
+
+func TestLoginGuardKeys(t *testing.T) {
+	ownerKeys := loginGuardKeys("Alice", "10.0.0.1")
+	attackerKeys := loginGuardKeys("alice", "10.0.0.2")
+
+	if len(ownerKeys) != 2 {
+		t.Fatalf("expected account & IP keys, got %d", len(ownerKeys))
+	}
+	if ownerKeys[0].key == attackerKeys[0].key {
+		t.Error("expected account failures from another IP not to lock owner out")
+	}
+	if ownerKeys[0].key != loginGuardKeys("ALICE", "10.0.0.1")[0].key {
+		t.Error("expected account key to use normalized handle")
+	}
+	if len(loginGuardKeys("alice", "")) != 1 {
+		t.Error("expected no IP key without client IP")
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	if lockDuration(0) != BaseLockDuration || lockDuration(1) != 2*BaseLockDuration {
+		t.Error("expected lock to double with every failure over the limit")
+	}
+	if lockDuration(10) != MaxLockDuration || lockDuration(100) != MaxLockDuration {
+		t.Error("expected lock to be capped")
+	}
+}
@@ -0,0 +1,280 @@
+package authservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+// StartOidcLogin returns provider authorization URL; linkUserID is set to link identity to logged in user instead
+func (service *AuthService) StartOidcLogin(ctx context.Context, linkUserID string) (string, error) {
+	return service.startOidcFlow(ctx, linkUserID, false)
+}
+
+// StartOidcReauth returns provider authorization URL for logged in user to prove his presence
+// by linked identity instead of password
+func (service *AuthService) StartOidcReauth(ctx context.Context, userID string) (string, error) {
+	return service.startOidcFlow(ctx, userID, true)
+}
+
+func (service *AuthService) startOidcFlow(ctx context.Context, linkUserID string, reauth bool) (string, error) {
+	provider := service.oidcProvider
+	if !provider.Config.Enabled() {
+		return "", oidchelper.ErrNotConfigured
+	}
+
+	state, err := oidchelper.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidchelper.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidchelper.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	oidcState := &model.OidcState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OidcStateLifetime),
+		CreatedAt:    now,
+	}
+	if linkUserID != "" {
+		_linkUserID, _ := primitive.ObjectIDFromHex(linkUserID)
+		oidcState.LinkUser = &_linkUserID
+		oidcState.Reauth = reauth
+	}
+	if err = service.oidcStateRepo.SaveState(ctx, oidcState); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// FinishOidcLogin redeems code of login flow and issues schatgo access token for the identity owner,
+// or only 2FA challenge token if he has two-factor authentication enabled (same as password login)
+func (service *AuthService) FinishOidcLogin(ctx context.Context, state string, code string) (*dto.LoginOutputDto, error) {
+	oidcState, claims, err := service.redeemOidcCode(ctx, state, code)
+	if err != nil {
+		return nil, err
+	}
+	if oidcState.LinkUser != nil {
+		// link flow must be finished by the same logged in user
+		return nil, ErrOidcStateMismatch
+	}
+
+	provider := service.oidcProvider
+	user, err := service.userRepo.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		user, err = service.userForNewIdentity(ctx, provider.Config, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return service.completeFirstFactor(ctx, user)
+}
+
+// FinishOidcLink redeems code of link flow started by the same user
+func (service *AuthService) FinishOidcLink(ctx context.Context, userID string, state string, code string) error {
+	oidcState, claims, err := service.redeemOidcCode(ctx, state, code)
+	if err != nil {
+		return err
+	}
+	if oidcState.LinkUser == nil || oidcState.Reauth || oidcState.LinkUser.Hex() != userID {
+		return ErrOidcStateMismatch
+	}
+
+	owner, err := service.userRepo.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		if owner.ID == *oidcState.LinkUser {
+			return nil
+		}
+		return ErrIdentityTaken
+	}
+	if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return err
+	}
+
+	return service.linkIdentity(ctx, *oidcState.LinkUser, claims)
+}
+
+// FinishOidcReauth redeems code of reauth flow started by the same user and returns short-lived reauth token,
+// accepted instead of current password. The identity must be already linked to the user
+func (service *AuthService) FinishOidcReauth(ctx context.Context, userID string, state string, code string) (string, error) {
+	oidcState, claims, err := service.redeemOidcCode(ctx, state, code)
+	if err != nil {
+		return "", err
+	}
+	if oidcState.LinkUser == nil || !oidcState.Reauth || oidcState.LinkUser.Hex() != userID {
+		return "", ErrOidcStateMismatch
+	}
+
+	owner, err := service.userRepo.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if errors.Is(err, cmnerr.ErrNotFoundEntity) || (err == nil && owner.ID != *oidcState.LinkUser) {
+		return "", ErrIdentityNotLinked
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return jwthelper.GenerateReauthToken(owner.ID.Hex(), owner.Handle)
+}
+
+func (service *AuthService) redeemOidcCode(ctx context.Context, state string, code string) (*model.OidcState, *oidchelper.Claims, error) {
+	oidcState, err := service.oidcStateRepo.ConsumeState(ctx, state)
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return nil, nil, ErrOidcStateMismatch
+		}
+		return nil, nil, err
+	}
+
+	claims, err := service.oidcProvider.Exchange(ctx, code, oidcState.CodeVerifier, oidcState.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oidcState, claims, nil
+}
+
+// userForNewIdentity links identity to user with the same email, verified by both sides, or signs up new one, if allowed.
+// Unconfirmed schatgo email could be anyone's, so it's never linked to
+func (service *AuthService) userForNewIdentity(ctx context.Context, config oidchelper.Config, claims *oidchelper.Claims) (*model.User, error) {
+	email := ""
+	if claims.EmailVerified {
+		email = userservice.NormalizeEmail(claims.Email)
+	}
+
+	if config.LinkByEmail && email != "" {
+		user, err := service.userRepo.GetUserByVerifiedEmail(ctx, email)
+		if err == nil {
+			if err = service.linkIdentity(ctx, user.ID, claims); err != nil {
+				return nil, err
+			}
+			return user, nil
+		}
+		if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return nil, err
+		}
+	}
+
+	if !config.AllowSignup {
+		return nil, ErrOidcSignupDisabled
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	handleBase := claims.PreferredUsername
+	if handleBase == "" {
+		handleBase = name
+	}
+	handle, err := service.userService.GenerateHandle(ctx, handleBase)
+	if err != nil {
+		return nil, err
+	}
+	if email != "" {
+		if err = service.userService.CheckEmailFree(ctx, email, primitive.NilObjectID); err != nil {
+			// already someone else's email, just don't copy it
+			email = ""
+		}
+	}
+
+	avatarUri := claims.Picture
+	if len(avatarUri) > MaxAvatarUriLength {
+		avatarUri = ""
+	}
+
+	now := time.Now()
+	newUser := &model.User{
+		Handle:    handle,
+		Name:      name,
+		AvatarUri: avatarUri,
+		Email:     email,
+
+		// provider has verified it, otherwise it's not copied
+		EmailVerified: email != "",
+
+		// no password, so password login is impossible until reset
+		Hash: "",
+
+		Identities: []model.ExternalIdentity{{
+			Issuer:   claims.Issuer,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+			LinkedAt: now,
+		}},
+
+		Discoverable: true,
+		Blocked:      make([]primitive.ObjectID, 0),
+
+		Contacts: make([]primitive.ObjectID, 0),
+		Chats:    make([]primitive.ObjectID, 0),
+
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	newUserID, err := service.userRepo.SaveUser(ctx, newUser)
+	if err != nil {
+		return nil, err
+	}
+	newUser.ID, _ = primitive.ObjectIDFromHex(newUserID)
+	slog.Info("user signed up via oidc", slog.String("userId", newUserID))
+
+	if email != "" {
+		// same as confirmation of email, other unconfirmed claims are dropped
+		if err = service.userRepo.UnsetUnverifiedEmails(ctx, email, newUser.ID); err != nil {
+			slog.Error("cannot drop unconfirmed claims of email", slog.Any("error", err))
+		}
+	}
+
+	return newUser, nil
+}
+
+func (service *AuthService) linkIdentity(ctx context.Context, userID primitive.ObjectID, claims *oidchelper.Claims) error {
+	err := service.userRepo.AddIdentity(ctx, userID, model.ExternalIdentity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	})
+	if errors.Is(err, cmnerr.ErrUniqueViolation) {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+const (
+	OidcStateLifetime  = 10 * time.Minute
+	MaxAvatarUriLength = 2047
+)
+
+var (
+	ErrOidcStateMismatch  = errors.New("oidc state is unknown, expired or belongs to another flow")
+	ErrOidcSignupDisabled = errors.New("no user is linked to this identity and sign up is disabled")
+	ErrIdentityTaken      = errors.New("identity is already linked to another user")
+
+	ErrIdentityNotLinked = errors.New("identity is not linked to the user")
+)
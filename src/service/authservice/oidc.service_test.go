@@ -0,0 +1,202 @@
+package authservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/oidchelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+func TestFinishOidcLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	t.Setenv("ACCESS_TOKEN_EXPIRATION_SECONDS", "3600")
+	jwthelper.InitJwtData()
+
+	identity := testhelper.OidcIdentity{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name string
+		// linked is the user already linked to the identity, otherwise one with the same email is looked up
+		linked        bool
+		emailVerified bool
+		twoFactor     bool
+		expectedErr   error
+		expectLink    bool
+		expect2FA     bool
+	}{
+		{name: "links identity to user with confirmed email", emailVerified: true, expectLink: true},
+		{name: "asks second factor after linking by email", emailVerified: true, twoFactor: true, expectLink: true, expect2FA: true},
+		{name: "doesn't link unconfirmed email", emailVerified: false, expectedErr: ErrOidcSignupDisabled},
+		{name: "asks second factor of linked user", linked: true, twoFactor: true, expect2FA: true},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			ctx := context.Background()
+			idp := testhelper.NewOidcProvider(mt)
+			config := idp.Config()
+			config.LinkByEmail = true
+
+			service := NewAuthService(testhelper.NewServer(mt))
+			service.oidcProvider = oidchelper.NewProvider(config, idp.Server.Client())
+
+			// StartOidcLogin stores state, nonce & PKCE verifier
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+			authURL, err := service.StartOidcLogin(ctx, "")
+			if err != nil {
+				mt.Fatal(err)
+			}
+			saved := startedCommand(mt, "insert")
+			stateDoc := saved.Lookup("documents").Array().Index(0).Value().Document()
+
+			state, code := idp.Authorize(mt, authURL, identity)
+			if state != stateDoc.Lookup("state").StringValue() {
+				mt.Fatalf("provider got state %q which wasn't stored", state)
+			}
+
+			user := bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "handle", Value: "jane"},
+				{Key: "name", Value: "Jane"},
+				{Key: "email", Value: identity.Email},
+				{Key: "emailVerified", Value: tt.emailVerified},
+				{Key: "twoFactor", Value: bson.D{{Key: "secret", Value: "S"}, {Key: "enabled", Value: tt.twoFactor}}},
+				{Key: "createdAt", Value: time.Now()},
+				{Key: "updatedAt", Value: time.Now()},
+			}
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: stateDoc}))
+			if tt.linked {
+				mt.AddMockResponses(mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch, user))
+			} else {
+				// only confirmed email is looked up
+				byEmail := mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch)
+				if tt.emailVerified {
+					byEmail = mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch, user)
+				}
+				mt.AddMockResponses(mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch), byEmail)
+			}
+			if tt.expectLink {
+				mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+			}
+			if tt.expectedErr == nil && !tt.expect2FA {
+				mt.AddMockResponses(
+					mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+					mtest.CreateSuccessResponse(),
+				)
+			}
+			mt.ClearEvents()
+
+			output, err := service.FinishOidcLogin(ctx, state, code)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					mt.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				mt.Fatal(err)
+			}
+
+			linked := false
+			for _, event := range mt.GetAllStartedEvents() {
+				linked = linked || event.CommandName == "update"
+			}
+			if linked != tt.expectLink {
+				mt.Errorf("expected identity linked %v, got %v", tt.expectLink, linked)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+			if tt.expect2FA && (!output.TwoFactorRequired || output.ChallengeToken == "" || output.AccessToken != "") {
+				mt.Errorf("expected only challenge token, got %+v", output)
+			}
+			if !tt.expect2FA && (output.TwoFactorRequired || output.AccessToken == "") {
+				mt.Errorf("expected access token, got %+v", output)
+			}
+		})
+	}
+}
+
+func TestFinishOidcReauth(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	jwthelper.InitJwtData()
+
+	identity := testhelper.OidcIdentity{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	userID := primitive.NewObjectID()
+	tests := []struct {
+		name        string
+		ownerID     primitive.ObjectID
+		expectedErr error
+	}{
+		{name: "issues reauth token for linked identity", ownerID: userID},
+		{name: "refuses identity linked to another user", ownerID: primitive.NewObjectID(), expectedErr: ErrIdentityNotLinked},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			ctx := context.Background()
+			idp := testhelper.NewOidcProvider(mt)
+
+			service := NewAuthService(testhelper.NewServer(mt))
+			service.oidcProvider = oidchelper.NewProvider(idp.Config(), idp.Server.Client())
+
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+			authURL, err := service.StartOidcReauth(ctx, userID.Hex())
+			if err != nil {
+				mt.Fatal(err)
+			}
+			stateDoc := startedCommand(mt, "insert").Lookup("documents").Array().Index(0).Value().Document()
+			state, code := idp.Authorize(mt, authURL, identity)
+
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: stateDoc}),
+				mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch, bson.D{
+					{Key: "_id", Value: tt.ownerID},
+					{Key: "handle", Value: "jane"},
+				}),
+			)
+
+			reauthToken, err := service.FinishOidcReauth(ctx, userID.Hex(), state, code)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					mt.Fatalf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				mt.Fatal(err)
+			}
+
+			// works instead of password, even for user without one
+			user := &model.User{ID: userID, Hash: ""}
+			if err = userservice.CheckCurrentPassword(user, "", reauthToken); err != nil {
+				mt.Errorf("expected reauth token to be accepted, got %v", err)
+			}
+			if err = userservice.CheckCurrentPassword(&model.User{ID: tt.ownerID}, "", "bad"); err == nil {
+				mt.Error("expected bad reauth token to be refused")
+			}
+			if err = userservice.CheckCurrentPassword(user, "", ""); err == nil {
+				mt.Error("expected empty password never to match missing hash")
+			}
+		})
+	}
+}
+
+func startedCommand(mt *mtest.T, name string) bson.Raw {
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("no %s command was sent", name)
+	return nil
+}
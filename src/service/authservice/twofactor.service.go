@@ -0,0 +1,242 @@
+package authservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/totphelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+// EnrollTwoFactor generates new TOTP secret, proven by current password (or reauth token) like DisableTwoFactor.
+// 2FA stays off until VerifyTwoFactor
+func (service *AuthService) EnrollTwoFactor(ctx context.Context, userID string, rawPassword string, reauthToken string) (*dto.TwoFactorEnrollOutputDto, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = userservice.CheckCurrentPassword(user, rawPassword, reauthToken); err != nil {
+		return nil, err
+	}
+	if user.HasTwoFactor() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totphelper.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealedSecret, err := totphelper.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"twoFactor": &model.TwoFactor{
+			Secret:        sealedSecret,
+			RecoveryCodes: make([]string, 0),
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorEnrollOutputDto{
+		Secret:     secret,
+		OtpauthUri: totphelper.URI(getTotpIssuer(), user.Handle, secret),
+	}, nil
+}
+
+// VerifyTwoFactor turns 2FA on by the first valid code and returns recovery codes
+func (service *AuthService) VerifyTwoFactor(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactor == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if user.TwoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if err = service.checkTotpCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"twoFactor.enabled":       true,
+		"twoFactor.enabledAt":     now,
+		"twoFactor.recoveryCodes": hashes,
+		"updatedAt":               now,
+	}); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor requires both password (or reauth token) and second factor
+func (service *AuthService) DisableTwoFactor(ctx context.Context, userID string, rawPassword string, reauthToken string, code string) error {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err = userservice.CheckCurrentPassword(user, rawPassword, reauthToken); err != nil {
+		return err
+	}
+	if !user.HasTwoFactor() {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err = service.checkSecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	return service.userRepo.UnsetUserFields(ctx, user.ID, "twoFactor")
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes with new ones
+func (service *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasTwoFactor() {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err = service.checkTotpCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"twoFactor.recoveryCodes": hashes,
+	}); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CompleteTwoFactorLogin exchanges challenge token & second factor for access token.
+// Wrong codes count as failed logins of the account
+func (service *AuthService) CompleteTwoFactorLogin(ctx context.Context, challengeToken string, code string, clientIP string) (string, error) {
+	payload, err := jwthelper.VerifyChallengeToken(challengeToken)
+	if err != nil {
+		return "", err
+	}
+
+	guardKeys := loginGuardKeys(payload.UserName, clientIP)
+	if err = service.checkLoginLock(ctx, guardKeys); err != nil {
+		return "", err
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, payload.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !user.HasTwoFactor() {
+		// 2FA got disabled meanwhile, so the password step must be passed again
+		return "", cmnerr.ErrInvalidToken
+	}
+	if err = service.checkSecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			service.registerLoginFailure(ctx, guardKeys)
+		}
+		return "", err
+	}
+	service.resetLoginFailures(ctx, guardKeys)
+
+	return service.issueAccessToken(ctx, user)
+}
+
+// checkSecondFactor accepts TOTP code or, if it doesn't look like one, recovery code
+func (service *AuthService) checkSecondFactor(ctx context.Context, user *model.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totphelper.Digits {
+		return service.checkTotpCode(ctx, user, code)
+	}
+
+	ok, err := service.userRepo.ConsumeRecoveryCode(ctx, user.ID, pwdhelper.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	slog.Info("recovery code used", slog.String("userId", user.ID.Hex()))
+	return nil
+}
+
+func (service *AuthService) checkTotpCode(ctx context.Context, user *model.User, code string) error {
+	secret, err := totphelper.Open(user.TwoFactor.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := totphelper.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := service.userRepo.ConsumeTotpStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		slog.Info("totp code replay refused", slog.String("userId", user.ID.Hex()))
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		raw, err := pwdhelper.GenerateRandomString(RecoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw = strings.ToLower(raw)
+		codes[i] = raw[:RecoveryCodeLength/2] + "-" + raw[RecoveryCodeLength/2:]
+		hashes[i] = pwdhelper.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func getTotpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "schatgo"
+}
+
+const (
+	RecoveryCodesCount = 10
+	RecoveryCodeLength = 10
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	ErrInvalidTwoFactorCode = errors.New("two-factor code is invalid")
+)
@@ -0,0 +1,263 @@
+package botservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/apikeyrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/userrepo"
+	"github.com/MykolaSainiuk/schatgo/src/service/commandservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+type BotService struct {
+	userRepo   *userrepo.UserRepo
+	apiKeyRepo *apikeyrepo.ApiKeyRepo
+
+	userService *userservice.UserService
+}
+
+func NewBotService(srv types.IServer) *BotService {
+	return &BotService{
+		userRepo:   userrepo.NewUserRepo(srv.GetDB()),
+		apiKeyRepo: apikeyrepo.NewApiKeyRepo(srv.GetDB()),
+
+		userService: userservice.NewUserService(srv),
+	}
+}
+
+// CreateBot makes bot user owned by the user; bots are not discoverable and have no password
+func (service *BotService) CreateBot(ctx context.Context, ownerID string, data *dto.NewBotInputDto) (*model.User, error) {
+	owner, err := service.userRepo.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.IsBot {
+		return nil, cmnerr.ErrForbidden
+	}
+
+	bots, err := service.userRepo.GetBotsByOwner(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bots) >= MaxBotsPerOwner {
+		return nil, ErrTooManyBots
+	}
+
+	var handle string
+	if data.Handle != "" {
+		handle = handlehelper.Normalize(data.Handle)
+		if !handlehelper.IsValid(handle) {
+			return nil, userservice.ErrInvalidHandle
+		}
+		_, err := service.userService.GetUserByHandle(ctx, handle)
+		if err == nil {
+			return nil, cmnerr.ErrUniqueViolation
+		}
+		if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return nil, err
+		}
+	} else if handle, err = service.userService.GenerateHandle(ctx, data.Name+" bot"); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	bot := &model.User{
+		Handle:    handle,
+		Name:      data.Name,
+		AvatarUri: data.AvatarUri,
+
+		IsBot: true,
+		Owner: &owner.ID,
+
+		Discoverable: false,
+		Blocked:      make([]primitive.ObjectID, 0),
+
+		Contacts: make([]primitive.ObjectID, 0),
+		Chats:    make([]primitive.ObjectID, 0),
+
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	botID, err := service.userRepo.SaveUser(ctx, bot)
+	if err != nil {
+		return nil, err
+	}
+	bot.ID, _ = primitive.ObjectIDFromHex(botID)
+
+	return bot, nil
+}
+
+func (service *BotService) ListBots(ctx context.Context, ownerID string) ([]model.User, error) {
+	_ownerID, _ := primitive.ObjectIDFromHex(ownerID)
+	return service.userRepo.GetBotsByOwner(ctx, _ownerID)
+}
+
+// GetOwnBot hides bots of others behind ErrNotFoundEntity
+func (service *BotService) GetOwnBot(ctx context.Context, ownerID string, botID string) (*model.User, error) {
+	bot, err := service.userRepo.GetUserByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	if !bot.IsBot || bot.Owner == nil || bot.Owner.Hex() != ownerID {
+		return nil, cmnerr.ErrNotFoundEntity
+	}
+	return bot, nil
+}
+
+// CreateApiKey returns the key only here, just its hash is kept
+func (service *BotService) CreateApiKey(ctx context.Context, ownerID string, botID string, data *dto.NewApiKeyInputDto) (*dto.NewApiKeyOutputDto, error) {
+	bot, err := service.GetOwnBot(ctx, ownerID, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := pwdhelper.GenerateRandomString(ApiKeySecretLength)
+	if err != nil {
+		return nil, err
+	}
+	key := model.ApiKeyPrefix + secret
+	scopes := slices.Clone(data.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	apiKey := &model.ApiKey{
+		Bot:       bot.ID,
+		Owner:     *bot.Owner,
+		Name:      data.Name,
+		Prefix:    key[:ApiKeyShownPrefixLength],
+		KeyHash:   pwdhelper.HashToken(key),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	keyID, err := service.apiKeyRepo.SaveApiKey(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("api key created", slog.String("botId", bot.ID.Hex()), slog.String("keyId", keyID.Hex()))
+
+	return &dto.NewApiKeyOutputDto{
+		ID:     keyID.Hex(),
+		Key:    key,
+		Prefix: apiKey.Prefix,
+		Scopes: scopes,
+	}, nil
+}
+
+func (service *BotService) ListApiKeys(ctx context.Context, ownerID string, botID string) ([]model.ApiKey, error) {
+	bot, err := service.GetOwnBot(ctx, ownerID, botID)
+	if err != nil {
+		return nil, err
+	}
+	return service.apiKeyRepo.GetBotKeys(ctx, bot.ID)
+}
+
+func (service *BotService) RevokeApiKey(ctx context.Context, ownerID string, botID string, keyID string) error {
+	bot, err := service.GetOwnBot(ctx, ownerID, botID)
+	if err != nil {
+		return err
+	}
+	_keyID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return service.apiKeyRepo.RevokeKey(ctx, _keyID, bot.ID)
+}
+
+// SetBotCommands replaces commands of own bot and rotates secret its callback calls are signed with
+func (service *BotService) SetBotCommands(ctx context.Context, ownerID string, botID string, data *dto.BotCommandsInputDto) (*dto.BotCommandsOutputDto, error) {
+	bot, err := service.GetOwnBot(ctx, ownerID, botID)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhookhelper.ValidateUrl(data.CallbackUrl); err != nil {
+		return nil, err
+	}
+
+	commands := make([]model.BotCommand, 0, len(data.Commands))
+	for _, command := range data.Commands {
+		name := strings.ToLower(strings.TrimPrefix(command.Name, "/"))
+		if !commandservice.IsValidName(name) {
+			return nil, ErrInvalidCommandName
+		}
+		if slices.ContainsFunc(commands, func(c model.BotCommand) bool { return c.Name == name }) {
+			continue
+		}
+		commands = append(commands, model.BotCommand{Name: name, Description: command.Description})
+	}
+
+	secret, err := pwdhelper.GenerateRandomString(CommandSecretLength)
+	if err != nil {
+		return nil, err
+	}
+	botCommands := &model.BotCommands{
+		CallbackUrl: data.CallbackUrl,
+		Secret:      webhookhelper.SecretPrefix + secret,
+		Commands:    commands,
+	}
+	if err := service.userRepo.SetBotCommands(ctx, bot.ID, botCommands); err != nil {
+		return nil, err
+	}
+
+	output := ToBotCommandsOutputDto(botCommands)
+	output.Secret = botCommands.Secret
+	return &output, nil
+}
+
+func (service *BotService) GetBotCommands(ctx context.Context, ownerID string, botID string) (*dto.BotCommandsOutputDto, error) {
+	bot, err := service.GetOwnBot(ctx, ownerID, botID)
+	if err != nil {
+		return nil, err
+	}
+	if bot.BotCommands == nil {
+		return &dto.BotCommandsOutputDto{Commands: make([]dto.BotCommandDto, 0)}, nil
+	}
+	output := ToBotCommandsOutputDto(bot.BotCommands)
+	return &output, nil
+}
+
+func ToBotCommandsOutputDto(botCommands *model.BotCommands) dto.BotCommandsOutputDto {
+	commands := make([]dto.BotCommandDto, len(botCommands.Commands))
+	for i, command := range botCommands.Commands {
+		commands[i] = dto.BotCommandDto{Name: command.Name, Description: command.Description}
+	}
+	return dto.BotCommandsOutputDto{CallbackUrl: botCommands.CallbackUrl, Commands: commands}
+}
+
+func ToBotOutputDto(bot *model.User) dto.BotOutputDto {
+	return dto.BotOutputDto{
+		ID:        bot.ID.Hex(),
+		Handle:    bot.Handle,
+		Name:      bot.Name,
+		AvatarUri: bot.AvatarUri,
+		CreatedAt: bot.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+const (
+	MaxBotsPerOwner = 20
+
+	ApiKeySecretLength      = 40
+	ApiKeyShownPrefixLength = 10
+
+	CommandSecretLength = 32
+)
+
+var (
+	ErrTooManyBots        = errors.New("bots limit per owner is reached")
+	ErrInvalidCommandName = errors.New("command name may contain only latin letters, digits and underscores")
+)
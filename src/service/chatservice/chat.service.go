@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,33 +12,46 @@ import (
 	"github.com/MykolaSainiuk/schatgo/src/api/dto"
 	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
 	"github.com/MykolaSainiuk/schatgo/src/model"
 	"github.com/MykolaSainiuk/schatgo/src/repo/chatrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/chatsettingsrepo"
 	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
 )
 
 type ChatService struct {
-	chatRepo *chatrepo.ChatRepo
+	chatRepo         *chatrepo.ChatRepo
+	chatSettingsRepo *chatsettingsrepo.ChatSettingsRepo
 
 	userService *userservice.UserService
 }
 
 func NewChatService(srv types.IServer) *ChatService {
 	return &ChatService{
-		chatRepo: chatrepo.NewChatRepo(srv.GetDB()),
+		chatRepo:         chatrepo.NewChatRepo(srv.GetDB()),
+		chatSettingsRepo: chatsettingsrepo.NewChatSettingsRepo(srv.GetDB()),
 
 		userService: userservice.NewUserService(srv),
 	}
 }
 
 func (service *ChatService) CreateChat(ctx context.Context, userId string, data *dto.AddChatInputDto) (*model.Chat, error) {
-	anotherUser, err := service.userService.GetUserByName(ctx, data.UserName)
+	anotherUser, err := service.userService.GetUserByHandle(ctx, data.Handle)
 	if err != nil || anotherUser == nil {
-		slog.Info("no such user found by name")
+		slog.Info("no such user found by handle")
 		return nil, err
 	}
 
-	_userId, _ := primitive.ObjectIDFromHex(userId)
+	user, err := service.userService.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if userservice.IsBlocked(user, anotherUser) {
+		slog.Info("cannot create chat between blocked users")
+		return nil, cmnerr.ErrForbidden
+	}
+
+	_userId := user.ID
 	existingChat, err := service.chatRepo.GetExistingChat(ctx, _userId, anotherUser.ID)
 	if err != nil && !errors.Is(err, cmnerr.ErrNotFoundEntity) {
 		return nil, err
@@ -48,8 +62,8 @@ func (service *ChatService) CreateChat(ctx context.Context, userId string, data
 
 	newUserItem := &model.Chat{
 		Name:        data.ChatName,
-		Muted:       false,
 		IconUri:     "",
+		Creator:     _userId,
 		Users:       []primitive.ObjectID{_userId, anotherUser.ID},
 		LastMessage: primitive.NilObjectID,
 		CreatedAt:   time.Now(),
@@ -86,27 +100,304 @@ func (service *ChatService) CreateChat(ctx context.Context, userId string, data
 		return nil, err
 	}
 
-	err = service.userService.RegisterNewChat(ctx, newChat.ID, userId, anotherUser.ID.Hex())
+	if err = service.userService.RegisterNewChat(ctx, newChat.ID, userId, anotherUser.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	for _, memberID := range newChat.Users {
+		eventhelper.Publish(eventhelper.Event{
+			Type:       eventhelper.EventMemberJoined,
+			Payload:    MemberPayload{Chat: newChat.ID, User: memberID, By: _userId},
+			Recipients: newChat.Users,
+			Chat:       newChat.ID,
+		})
+	}
 
-	return newChat, err
+	return newChat, nil
 }
 
-func (service *ChatService) GetAllChats(ctx context.Context, userID string) ([]model.ChatPopulated, error) {
-	return service.chatRepo.GetChatsByUserID(ctx, userID, types.PaginationParams{})
+func (service *ChatService) GetAllChats(ctx context.Context, userID string, filter chatrepo.ChatsFilter) ([]model.ChatPopulated, error) {
+	return service.GetChatsPaginated(ctx, userID, types.PaginationParams{}, filter)
 }
 
-func (service *ChatService) GetChatsPaginated(ctx context.Context, userID string, pgParams types.PaginationParams) ([]model.ChatPopulated, error) {
-	return service.chatRepo.GetChatsByUserID(ctx, userID, pgParams)
+func (service *ChatService) GetChatsPaginated(ctx context.Context, userID string, pgParams types.PaginationParams, filter chatrepo.ChatsFilter) ([]model.ChatPopulated, error) {
+	chats, err := service.chatRepo.GetChatsByUserID(ctx, userID, pgParams, filter)
+	if err != nil {
+		return nil, err
+	}
+	applySettings(chats)
+
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	for i := range chats {
+		userservice.HideFromBlocked(_userID, chats[i].Users...)
+	}
+
+	return chats, nil
 }
 
 func (service *ChatService) GetChatByID(ctx context.Context, chatID primitive.ObjectID) (*model.Chat, error) {
 	return service.chatRepo.GetChatByID(ctx, chatID)
 }
 
-func (service *ChatService) SetLastMessage(ctx context.Context, chatID, messageID primitive.ObjectID) error {
-	return service.chatRepo.SetLastMessage(ctx, chatID, messageID)
+// GetUserChat returns chat only if the user is its member
+func (service *ChatService) GetUserChat(ctx context.Context, chatID, userID primitive.ObjectID) (*model.Chat, error) {
+	chat, err := service.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(chat.Users, userID) {
+		return nil, cmnerr.ErrNotFoundEntity
+	}
+
+	return chat, nil
+}
+
+// GetWritableChat returns chat only if the user is allowed to post into it.
+// A block is enforced in direct chats only: it does not stop members of a group
+// from posting there, since the chat is shared with the others.
+func (service *ChatService) GetWritableChat(ctx context.Context, chatID, userID primitive.ObjectID) (*model.Chat, error) {
+	chat, err := service.GetUserChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Group {
+		return chat, nil
+	}
+
+	users, err := service.userService.GetUsersByIDs(ctx, chat.Users)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 2 && userservice.IsBlocked(&users[0], &users[1]) {
+		return nil, cmnerr.ErrForbidden
+	}
+
+	return chat, nil
+}
+
+// GetAdminChat returns chat only if the user may post into it and administer it
+func (service *ChatService) GetAdminChat(ctx context.Context, chatID, userID primitive.ObjectID) (*model.Chat, error) {
+	chat, err := service.GetWritableChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsChatAdmin(chat, userID) {
+		return nil, cmnerr.ErrForbidden
+	}
+
+	return chat, nil
+}
+
+// IsChatAdmin tells if the user is chat creator, or one of direct chat users, who are equal
+func IsChatAdmin(chat *model.Chat, userID primitive.ObjectID) bool {
+	return chat.Creator == userID || (!chat.Group && slices.Contains(chat.Users, userID))
+}
+
+func (service *ChatService) GetUserChatIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return service.chatRepo.GetChatIDsByUserID(ctx, userID)
+}
+
+func (service *ChatService) SetLastMessage(ctx context.Context, chatID, messageID primitive.ObjectID, expiresAt *time.Time) error {
+	return service.chatRepo.SetLastMessage(ctx, chatID, messageID, expiresAt)
+}
+
+func (service *ChatService) RenameChat(ctx context.Context, chatID primitive.ObjectID, name string) error {
+	return service.chatRepo.UpdateChat(ctx, chatID, map[string]any{"name": name})
+}
+
+func (service *ChatService) UpdateChat(ctx context.Context, chatID primitive.ObjectID, changes map[string]any) error {
+	return service.chatRepo.UpdateChat(ctx, chatID, changes)
+}
+
+func (service *ChatService) SetRetention(ctx context.Context, chatID primitive.ObjectID, seconds int64) error {
+	return service.chatRepo.SetRetention(ctx, chatID, seconds)
+}
+
+func (service *ChatService) GetChatsWithExpiredLastMessage(ctx context.Context, now time.Time, limit int64) ([]primitive.ObjectID, error) {
+	return service.chatRepo.GetChatsWithExpiredLastMessage(ctx, now, limit)
+}
+
+func (service *ChatService) PinMessage(ctx context.Context, chatID, messageID primitive.ObjectID, limit int) (bool, error) {
+	return service.chatRepo.PinMessage(ctx, chatID, messageID, limit)
+}
+
+func (service *ChatService) UnpinMessages(ctx context.Context, chatID primitive.ObjectID, messageIDs []primitive.ObjectID) (bool, error) {
+	return service.chatRepo.UnpinMessages(ctx, chatID, messageIDs)
+}
+
+func (service *ChatService) ClearPinnedMessages(ctx context.Context, chatID primitive.ObjectID) error {
+	return service.chatRepo.ClearPinnedMessages(ctx, chatID)
+}
+
+// CreateGroup establishes group chat with its creator alone, others join it by invites
+func (service *ChatService) CreateGroup(ctx context.Context, userID string, data *dto.NewGroupInputDto) (*model.Chat, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	newChat, err := service.chatRepo.SaveChat(ctx, &model.Chat{
+		Name:        data.ChatName,
+		IconUri:     "",
+		Group:       true,
+		Creator:     _userID,
+		Users:       []primitive.ObjectID{_userID},
+		LastMessage: primitive.NilObjectID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := service.userService.RegisterChat(ctx, newChat.ID, _userID); err != nil {
+		return nil, err
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventMemberJoined,
+		Payload:    MemberPayload{Chat: newChat.ID, User: _userID, By: _userID},
+		Recipients: newChat.Users,
+		Chat:       newChat.ID,
+	})
+
+	return newChat, nil
+}
+
+// InviteMember invites user into the group, he joins once he accepts; own bots of the inviter join right away.
+// Only users who are discoverable or have the inviter in contacts can be invited,
+// and none of them who blocks or is blocked by any member.
+// It reports whether the invitee has joined already.
+func (service *ChatService) InviteMember(ctx context.Context, chat *model.Chat, inviterID primitive.ObjectID, handle string) (*model.User, bool, error) {
+	if !chat.Group {
+		return nil, false, ErrNotGroupChat
+	}
+
+	invitee, err := service.userService.GetUserByHandle(ctx, handle)
+	if err != nil {
+		return nil, false, err
+	}
+	if slices.Contains(chat.Users, invitee.ID) {
+		return nil, false, ErrAlreadyMember
+	}
+	ownBot := invitee.IsBot && invitee.Owner != nil && *invitee.Owner == inviterID
+	if !ownBot && !invitee.Discoverable && !slices.Contains(invitee.Contacts, inviterID) {
+		return nil, false, cmnerr.ErrNotFoundEntity
+	}
+	if err := service.checkBlocks(ctx, chat, invitee); err != nil {
+		return nil, false, err
+	}
+
+	if ownBot {
+		if err := service.join(ctx, chat, invitee.ID, inviterID); err != nil {
+			return nil, false, err
+		}
+		return invitee, true, nil
+	}
+
+	invited, err := service.chatRepo.AddInvite(ctx, chat.ID, invitee.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !invited {
+		return nil, false, ErrAlreadyInvited
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventChatInvited,
+		Payload:    MemberPayload{Chat: chat.ID, User: invitee.ID, By: inviterID},
+		Recipients: []primitive.ObjectID{invitee.ID},
+	})
+
+	return invitee, false, nil
+}
+
+// AcceptInvite lets invited user join the group, blocks are checked again as members may have changed
+func (service *ChatService) AcceptInvite(ctx context.Context, chatID, userID primitive.ObjectID) (*model.Chat, error) {
+	chat, err := service.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(chat.Invited, userID) {
+		return nil, cmnerr.ErrNotFoundEntity
+	}
+
+	user, err := service.userService.GetUserByID(ctx, userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if err := service.checkBlocks(ctx, chat, user); err != nil {
+		return nil, err
+	}
+
+	if err := service.join(ctx, chat, userID, userID); err != nil {
+		return nil, err
+	}
+
+	return chat, nil
+}
+
+func (service *ChatService) DeclineInvite(ctx context.Context, chatID, userID primitive.ObjectID) error {
+	removed, err := service.chatRepo.RemoveInvite(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return cmnerr.ErrNotFoundEntity
+	}
+	return nil
+}
+
+func (service *ChatService) GetInvitedChats(ctx context.Context, userID primitive.ObjectID) ([]model.Chat, error) {
+	return service.chatRepo.GetInvitedChats(ctx, userID)
+}
+
+// checkBlocks forbids the user to join the chat if he blocks or is blocked by any of its members
+func (service *ChatService) checkBlocks(ctx context.Context, chat *model.Chat, user *model.User) error {
+	members, err := service.userService.GetUsersByIDs(ctx, chat.Users)
+	if err != nil {
+		return err
+	}
+	for i := range members {
+		if userservice.IsBlocked(&members[i], user) {
+			return cmnerr.ErrForbidden
+		}
+	}
+	return nil
+}
+
+func (service *ChatService) join(ctx context.Context, chat *model.Chat, userID, by primitive.ObjectID) error {
+	added, err := service.chatRepo.AddChatUser(ctx, chat.ID, userID)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrAlreadyMember
+	}
+	if err := service.userService.RegisterChat(ctx, chat.ID, userID); err != nil {
+		return err
+	}
+	chat.Users = append(chat.Users, userID)
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventMemberJoined,
+		Payload:    MemberPayload{Chat: chat.ID, User: userID, By: by},
+		Recipients: chat.Users,
+		Chat:       chat.ID,
+	})
+
+	return nil
 }
 
 // func (service *ChatService) GetChat(ctx context.Context, chatID string) (*model.ChatPopulated, error) {
 // 	return service.chatRepo.GetChatByIdPopulated(ctx, chatID)
 // }
+
+// MemberPayload describes who joined the chat
+type MemberPayload struct {
+	Chat primitive.ObjectID `json:"chat"`
+	User primitive.ObjectID `json:"user"`
+	By   primitive.ObjectID `json:"by"`
+}
+
+var (
+	ErrNotGroupChat   = errors.New("only group chats accept new members")
+	ErrAlreadyMember  = errors.New("user is chat member already")
+	ErrAlreadyInvited = errors.New("user is invited into chat already")
+)
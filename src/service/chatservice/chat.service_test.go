@@ -0,0 +1,210 @@
+package chatservice
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/chatrepo"
+)
+
+func TestGetWritableChat(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	userID, anotherUserID := primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name          string
+		blocked       bool
+		expectedError error
+	}{
+		{name: "allows posting without block", blocked: false},
+		{name: "refuses posting into direct chat with block", blocked: true, expectedError: cmnerr.ErrForbidden},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			chatID := primitive.NewObjectID()
+			blocked := bson.A{}
+			if tt.blocked {
+				blocked = bson.A{userID}
+			}
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, "schat.chats", mtest.FirstBatch, bson.D{
+					{Key: "_id", Value: chatID},
+					{Key: "name", Value: "direct"},
+					{Key: "users", Value: bson.A{userID, anotherUserID}},
+				}),
+				mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch,
+					bson.D{{Key: "_id", Value: userID}, {Key: "name", Value: "user"}, {Key: "blocked", Value: bson.A{}}},
+					bson.D{{Key: "_id", Value: anotherUserID}, {Key: "name", Value: "another"}, {Key: "blocked", Value: blocked}},
+				),
+			)
+
+			service := NewChatService(testhelper.NewServer(mt))
+			chat, err := service.GetWritableChat(context.Background(), chatID, userID)
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					mt.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil || chat == nil || chat.ID != chatID {
+				mt.Fatalf("expected chat %s, got %v, error %v", chatID.Hex(), chat, err)
+			}
+		})
+	}
+}
+
+func TestIsChatAdmin(t *testing.T) {
+	creatorID, userID, anotherUserID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	direct := &model.Chat{Users: []primitive.ObjectID{userID, anotherUserID}}
+	if !IsChatAdmin(direct, userID) || !IsChatAdmin(direct, anotherUserID) {
+		t.Error("expected both users of direct chat to be admins")
+	}
+	if IsChatAdmin(direct, creatorID) {
+		t.Error("expected outsider not to be admin")
+	}
+
+	group := &model.Chat{Group: true, Creator: creatorID, Users: []primitive.ObjectID{creatorID, userID}}
+	if !IsChatAdmin(group, creatorID) || IsChatAdmin(group, userID) {
+		t.Error("expected only creator to administer group even of two users")
+	}
+}
+
+func TestInviteMember(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	creatorID, memberID, inviteeID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name          string
+		group         bool
+		discoverable  bool
+		memberBlocked bson.A
+		expectedError error
+	}{
+		{name: "refuses invite into direct chat", expectedError: ErrNotGroupChat},
+		{name: "hides undiscoverable user who is no contact", group: true, expectedError: cmnerr.ErrNotFoundEntity},
+		{name: "refuses user blocked by any member", group: true, discoverable: true, memberBlocked: bson.A{inviteeID}, expectedError: cmnerr.ErrForbidden},
+		{name: "invites discoverable user", group: true, discoverable: true, memberBlocked: bson.A{}},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			chat := &model.Chat{ID: primitive.NewObjectID(), Group: tt.group, Creator: creatorID, Users: []primitive.ObjectID{creatorID, memberID}}
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch, bson.D{
+					{Key: "_id", Value: inviteeID},
+					{Key: "handle", Value: "invitee"},
+					{Key: "discoverable", Value: tt.discoverable},
+				}),
+				mtest.CreateCursorResponse(0, "schat.users", mtest.FirstBatch,
+					bson.D{{Key: "_id", Value: creatorID}, {Key: "blocked", Value: bson.A{}}},
+					bson.D{{Key: "_id", Value: memberID}, {Key: "blocked", Value: tt.memberBlocked}},
+				),
+				mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			)
+
+			service := NewChatService(testhelper.NewServer(mt))
+			invitee, joined, err := service.InviteMember(context.Background(), chat, creatorID, "invitee")
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					mt.Fatalf("expected error %v, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil || invitee == nil || invitee.ID != inviteeID {
+				mt.Fatalf("expected invitee %s, got %v, error %v", inviteeID.Hex(), invitee, err)
+			}
+			if joined || slices.Contains(chat.Users, inviteeID) {
+				mt.Error("expected invitee not to join before accepting")
+			}
+		})
+	}
+}
+
+func TestGetChatsPaginatedKeepsChatsWithoutMessages(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("lists chat whose messages are gone", func(mt *mtest.T) {
+		userID, chatID := primitive.NewObjectID(), primitive.NewObjectID()
+		now := primitive.NewDateTimeFromTime(time.Now())
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "schat.chats", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: chatID},
+			{Key: "name", Value: "quiet"},
+			{Key: "users", Value: bson.A{bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "name", Value: "user"},
+				{Key: "avatarUri", Value: ""},
+				{Key: "hash", Value: ""},
+				{Key: "createdAt", Value: now},
+				{Key: "updatedAt", Value: now},
+			}}},
+			{Key: "createdAt", Value: now},
+			{Key: "updatedAt", Value: now},
+		}))
+
+		service := NewChatService(testhelper.NewServer(mt))
+		chats, err := service.GetChatsPaginated(context.Background(), userID.Hex(), types.PaginationParams{Page: 1, Limit: 10}, chatrepo.ChatsFilter{})
+		if err != nil || len(chats) != 1 || chats[0].ID != chatID || chats[0].LastMessage != nil {
+			mt.Fatalf("expected chat %s without last message, got %v, error %v", chatID.Hex(), chats, err)
+		}
+
+		pipeline := mt.GetStartedEvent().Command.Lookup("pipeline").String()
+		if !strings.Contains(pipeline, `"preserveNullAndEmptyArrays": true`) {
+			mt.Errorf("expected $unwind to keep chats without last message, got %s", pipeline)
+		}
+	})
+}
+
+func TestUpdateChatSettingsSetsOnlyGivenFields(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("keeps absent fields untouched", func(mt *mtest.T) {
+		userID, chatID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "schat.chats", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: chatID},
+				{Key: "users", Value: bson.A{userID}},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "chat", Value: chatID},
+				{Key: "user", Value: userID},
+				{Key: "pinned", Value: true},
+				{Key: "archived", Value: true},
+			}}),
+		)
+
+		pinned := true
+		service := NewChatService(testhelper.NewServer(mt))
+		settings, err := service.UpdateChatSettings(context.Background(), chatID.Hex(), userID.Hex(), &dto.ChatSettingsInputDto{Pinned: &pinned})
+		if err != nil || settings == nil || !settings.Pinned || !settings.Archived {
+			mt.Fatalf("expected updated settings to be returned, got %v, error %v", settings, err)
+		}
+
+		mt.GetStartedEvent() // the chat lookup
+		command := mt.GetStartedEvent().Command
+		if upsert, _ := command.Lookup("upsert").BooleanOK(); !upsert {
+			mt.Error("expected settings to be created if absent")
+		}
+		set := command.Lookup("update", "$set").Document()
+		if _, err := set.LookupErr("pinned"); err != nil {
+			mt.Errorf("expected pinned to be set, got %s", set)
+		}
+		for _, key := range []string{"mutedUntil", "archived", "notificationLevel"} {
+			if _, err := set.LookupErr(key); err == nil {
+				mt.Errorf("expected %s to be kept, got %s", key, set)
+			}
+		}
+	})
+}
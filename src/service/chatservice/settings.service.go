@@ -0,0 +1,67 @@
+package chatservice
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// UpdateChatSettings changes preferences of the member for the chat, absent fields are kept
+func (service *ChatService) UpdateChatSettings(ctx context.Context, chatID string, userID string, data *dto.ChatSettingsInputDto) (*model.ChatSettings, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, cmnerr.ErrNotFoundEntity
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	chat, err := service.GetUserChat(ctx, _chatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keyValueMap := map[string]any{}
+	if data.MutedUntil != nil {
+		// time in the past unmutes
+		var mutedUntil *time.Time
+		if data.MutedUntil.After(time.Now()) {
+			utc := data.MutedUntil.UTC()
+			mutedUntil = &utc
+		}
+		keyValueMap["mutedUntil"] = mutedUntil
+	}
+	if data.Pinned != nil {
+		keyValueMap["pinned"] = *data.Pinned
+	}
+	if data.Archived != nil {
+		keyValueMap["archived"] = *data.Archived
+	}
+	if data.NotificationLevel != nil {
+		keyValueMap["notificationLevel"] = *data.NotificationLevel
+	}
+
+	return service.chatSettingsRepo.UpdateSettings(ctx, chat.ID, _userID, keyValueMap)
+}
+
+// SetMutedUntil mutes the chat for the member till given time, nil unmutes
+func (service *ChatService) SetMutedUntil(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID, mutedUntil *time.Time) error {
+	_, err := service.chatSettingsRepo.UpdateSettings(ctx, chatID, userID, map[string]any{"mutedUntil": mutedUntil})
+	return err
+}
+
+// GetMembersSettings returns settings of those members of the chat who have any
+func (service *ChatService) GetMembersSettings(ctx context.Context, chatID primitive.ObjectID, userIDs []primitive.ObjectID) ([]model.ChatSettings, error) {
+	return service.chatSettingsRepo.GetChatSettingsOfUsers(ctx, chatID, userIDs)
+}
+
+// applySettings fills what depends on the viewer's settings in
+func applySettings(chats []model.ChatPopulated) {
+	now := time.Now()
+	for i := range chats {
+		chats[i].Muted = chats[i].Settings.IsMuted(now)
+	}
+}
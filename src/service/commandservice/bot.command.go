@@ -0,0 +1,100 @@
+package commandservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// botCallPayload is what bot gets on its callback URL
+type botCallPayload struct {
+	Command   string             `json:"command"`
+	Args      string             `json:"args"`
+	Chat      primitive.ObjectID `json:"chat"`
+	User      primitive.ObjectID `json:"user"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// botReply is what bot may answer with, empty text means no reply
+type botReply struct {
+	Text      string `json:"text"`
+	Ephemeral bool   `json:"ephemeral"`
+}
+
+// callBot posts signed call to the bot callback and waits for its reply.
+// Failures of the bot are reported to the caller only.
+func (service *CommandService) callBot(ctx context.Context, bot *model.User, call *Call) (*Reply, error) {
+	body, err := json.Marshal(botCallPayload{
+		Command:   call.Name,
+		Args:      call.Args,
+		Chat:      call.Chat.ID,
+		User:      call.User,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, BotCallTimeout)
+	defer cancel()
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bot.BotCommands.CallbackUrl, bytes.NewReader(body))
+	if err != nil {
+		return botFailed(bot, err), nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookhelper.HeaderEvent, BotCallEvent)
+	req.Header.Set(webhookhelper.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookhelper.HeaderSignature, webhookhelper.Sign(bot.BotCommands.Secret, timestamp, body))
+
+	res, err := webhookhelper.GetClient().Do(req)
+	if err != nil {
+		return botFailed(bot, err), nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return botFailed(bot, webhookhelper.ErrUnexpectedStatus), nil
+	}
+
+	var reply botReply
+	data, _ := io.ReadAll(io.LimitReader(res.Body, MaxBotReplySize))
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &reply); err != nil {
+			return botFailed(bot, err), nil
+		}
+	}
+
+	text := strings.TrimSpace(reply.Text)
+	if text == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(text) > MaxBotReplyLength {
+		text = string([]rune(text)[:MaxBotReplyLength])
+	}
+	return &Reply{Text: text, Ephemeral: reply.Ephemeral, From: bot.ID}, nil
+}
+
+func botFailed(bot *model.User, err error) *Reply {
+	slog.Info("bot command call has failed", slog.String("botId", bot.ID.Hex()), slog.String("error", err.Error()))
+	return &Reply{Text: "@" + bot.Handle + " didn't answer, try again later", Ephemeral: true}
+}
+
+const (
+	BotCallEvent      = "command"
+	BotCallTimeout    = 5 * time.Second
+	MaxBotReplySize   = 64 << 10
+	MaxBotReplyLength = 4096
+)
@@ -0,0 +1,272 @@
+package commandservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/timehelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/chatservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/pollservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+// Call is slash command the user has sent into the chat
+type Call struct {
+	Name string
+	// Bot is handle given as "/name@bot" to address one of bots having the command
+	Bot  string
+	Args string
+
+	Chat *model.Chat
+	User primitive.ObjectID
+}
+
+// Reply is the answer of command handler, nil means nothing to answer
+type Reply struct {
+	Text string
+	// Ephemeral reply is shown only to the caller and isn't stored, others are posted as system messages
+	Ephemeral bool
+	// From is the bot which answered, its reply is posted as the bot message
+	From primitive.ObjectID
+	// Poll is posted as the caller's message with Text as the question
+	Poll *model.Poll
+}
+
+type Handler func(ctx context.Context, call *Call) (*Reply, error)
+
+type Command struct {
+	Usage       string
+	Description string
+	Run         Handler
+}
+
+type CommandService struct {
+	chatService *chatservice.ChatService
+	userService *userservice.UserService
+
+	commands map[string]Command
+}
+
+func NewCommandService(srv types.IServer) *CommandService {
+	service := &CommandService{
+		chatService: chatservice.NewChatService(srv),
+		userService: userservice.NewUserService(srv),
+	}
+
+	service.commands = map[string]Command{
+		"help":   {Usage: "/help", Description: "list commands available in the chat", Run: service.help},
+		"mute":   {Usage: "/mute 1h", Description: "mute the chat for yourself, e.g. for 30m, 8h, 2d or 1w", Run: service.mute},
+		"unmute": {Usage: "/unmute", Description: "unmute the chat", Run: service.unmute},
+		"rename": {Usage: "/rename New name", Description: "rename the chat", Run: service.rename},
+		"invite": {Usage: "/invite @handle", Description: "invite user into the group", Run: service.invite},
+		"poll":   {Usage: "/poll Question | option | option", Description: "start single-choice poll", Run: service.poll},
+	}
+
+	return service
+}
+
+// Register adds built-in command, it takes precedence over bot commands with the same name
+func (service *CommandService) Register(name string, command Command) {
+	service.commands[name] = command
+}
+
+// Parse recognizes "/name[@bot] args", anything else is a plain message
+func Parse(text string) (*Call, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return nil, false
+	}
+	head, args, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	name, bot, _ := strings.Cut(strings.ToLower(head), "@")
+	if !IsValidName(name) || (bot != "" && !handlehelper.IsValid(bot)) {
+		return nil, false
+	}
+
+	return &Call{Name: name, Bot: bot, Args: strings.TrimSpace(args)}, true
+}
+
+func IsValidName(name string) bool {
+	return nameRegexp.MatchString(name)
+}
+
+// Dispatch runs command the text invokes; handled is false if the text is no known command,
+// so it has to be posted as usual message
+func (service *CommandService) Dispatch(ctx context.Context, chat *model.Chat, userID primitive.ObjectID, text string) (*Reply, bool, error) {
+	call, ok := Parse(text)
+	if !ok {
+		return nil, false, nil
+	}
+	call.Chat = chat
+	call.User = userID
+
+	if command, found := service.commands[call.Name]; found && call.Bot == "" {
+		reply, err := command.Run(ctx, call)
+		return reply, true, err
+	}
+
+	bot, err := service.findBot(ctx, call)
+	if err != nil || bot == nil {
+		return nil, false, err
+	}
+	reply, err := service.callBot(ctx, bot, call)
+	return reply, true, err
+}
+
+func (service *CommandService) help(ctx context.Context, call *Call) (*Reply, error) {
+	lines := make([]string, 0, len(service.commands))
+	for _, command := range service.commands {
+		lines = append(lines, fmt.Sprintf("%s - %s", command.Usage, command.Description))
+	}
+	sort.Strings(lines)
+
+	bots, err := service.chatBots(ctx, call.Chat)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bots {
+		for _, command := range bots[i].BotCommands.Commands {
+			lines = append(lines, fmt.Sprintf("/%s@%s - %s", command.Name, bots[i].Handle, command.Description))
+		}
+	}
+
+	return &Reply{Text: strings.Join(lines, "\n"), Ephemeral: true}, nil
+}
+
+func (service *CommandService) mute(ctx context.Context, call *Call) (*Reply, error) {
+	duration, err := timehelper.ParseDuration(call.Args)
+	if err != nil {
+		return &Reply{Text: err.Error(), Ephemeral: true}, nil
+	}
+
+	mutedUntil := time.Now().Add(duration).UTC()
+	if err := service.chatService.SetMutedUntil(ctx, call.Chat.ID, call.User, &mutedUntil); err != nil {
+		return nil, err
+	}
+
+	return &Reply{Text: "The chat is muted until " + mutedUntil.Format(time.RFC3339), Ephemeral: true}, nil
+}
+
+func (service *CommandService) unmute(ctx context.Context, call *Call) (*Reply, error) {
+	if err := service.chatService.SetMutedUntil(ctx, call.Chat.ID, call.User, nil); err != nil {
+		return nil, err
+	}
+	return &Reply{Text: "The chat is unmuted", Ephemeral: true}, nil
+}
+
+func (service *CommandService) rename(ctx context.Context, call *Call) (*Reply, error) {
+	if call.Args == "" || utf8.RuneCountInString(call.Args) > MaxChatNameLength {
+		return &Reply{Text: fmt.Sprintf("Usage: /rename New name (up to %d characters)", MaxChatNameLength), Ephemeral: true}, nil
+	}
+	if !chatservice.IsChatAdmin(call.Chat, call.User) {
+		return &Reply{Text: "Only the chat creator can rename it", Ephemeral: true}, nil
+	}
+
+	user, err := service.userService.GetUserByID(ctx, call.User.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if err := service.chatService.RenameChat(ctx, call.Chat.ID, call.Args); err != nil {
+		return nil, err
+	}
+
+	return &Reply{Text: fmt.Sprintf("@%s renamed the chat to %q", user.Handle, call.Args)}, nil
+}
+
+func (service *CommandService) invite(ctx context.Context, call *Call) (*Reply, error) {
+	handle := handlehelper.Normalize(call.Args)
+	if !handlehelper.IsValid(handle) {
+		return &Reply{Text: "Usage: /invite @handle", Ephemeral: true}, nil
+	}
+
+	user, err := service.userService.GetUserByID(ctx, call.User.Hex())
+	if err != nil {
+		return nil, err
+	}
+	invitee, joined, err := service.chatService.InviteMember(ctx, call.Chat, call.User, handle)
+	if err != nil {
+		switch {
+		case errors.Is(err, chatservice.ErrNotGroupChat):
+			return &Reply{Text: "Only group chats accept new members", Ephemeral: true}, nil
+		case errors.Is(err, cmnerr.ErrNotFoundEntity):
+			return &Reply{Text: "There is no user @" + handle, Ephemeral: true}, nil
+		case errors.Is(err, cmnerr.ErrForbidden):
+			return &Reply{Text: "You cannot invite @" + handle, Ephemeral: true}, nil
+		case errors.Is(err, chatservice.ErrAlreadyMember):
+			return &Reply{Text: "@" + handle + " is in the chat already", Ephemeral: true}, nil
+		case errors.Is(err, chatservice.ErrAlreadyInvited):
+			return &Reply{Text: "@" + handle + " is invited already", Ephemeral: true}, nil
+		}
+		return nil, err
+	}
+
+	if joined {
+		return &Reply{Text: fmt.Sprintf("@%s added @%s", user.Handle, invitee.Handle)}, nil
+	}
+	return &Reply{Text: fmt.Sprintf("@%s invited @%s", user.Handle, invitee.Handle)}, nil
+}
+
+func (service *CommandService) poll(_ context.Context, call *Call) (*Reply, error) {
+	parts := strings.Split(call.Args, "|")
+	question := strings.TrimSpace(parts[0])
+	usage := &Reply{Text: "Usage: /poll Question | option | option", Ephemeral: true}
+	if question == "" || utf8.RuneCountInString(question) > pollservice.MaxPollQuestionLength || len(parts) < 3 {
+		return usage, nil
+	}
+
+	poll, err := pollservice.BuildPoll(parts[1:], false, false, nil)
+	if err != nil {
+		return &Reply{Text: err.Error(), Ephemeral: true}, nil
+	}
+
+	return &Reply{Text: question, Poll: poll}, nil
+}
+
+// chatBots returns bots of the chat having commands
+func (service *CommandService) chatBots(ctx context.Context, chat *model.Chat) ([]model.User, error) {
+	members, err := service.userService.GetUsersByIDs(ctx, chat.Users)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(members, func(user model.User) bool {
+		return !user.IsBot || user.BotCommands == nil
+	}), nil
+}
+
+// findBot picks chat bot having the command, the first one if the call doesn't address any
+func (service *CommandService) findBot(ctx context.Context, call *Call) (*model.User, error) {
+	bots, err := service.chatBots(ctx, call.Chat)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bots {
+		if call.Bot != "" && bots[i].Handle != call.Bot {
+			continue
+		}
+		hasCommand := slices.ContainsFunc(bots[i].BotCommands.Commands, func(command model.BotCommand) bool {
+			return command.Name == call.Name
+		})
+		if hasCommand {
+			return &bots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+const (
+	MaxChatNameLength = 64
+)
+
+//nolint:gochecknoglobals // compiled once
+var nameRegexp = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
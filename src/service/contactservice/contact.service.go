@@ -0,0 +1,196 @@
+package contactservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/contactrequestrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/userrepo"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
+)
+
+type ContactService struct {
+	userRepo           *userrepo.UserRepo
+	contactRequestRepo *contactrequestrepo.ContactRequestRepo
+
+	userService *userservice.UserService
+}
+
+func NewContactService(srv types.IServer) *ContactService {
+	return &ContactService{
+		userRepo:           userrepo.NewUserRepo(srv.GetDB()),
+		contactRequestRepo: contactrequestrepo.NewContactRequestRepo(srv.GetDB()),
+
+		userService: userservice.NewUserService(srv),
+	}
+}
+
+// SendContactRequest asks another user to become a contact.
+// If that user has already asked us, his request gets accepted instead.
+func (service *ContactService) SendContactRequest(ctx context.Context, userID string, contactHandle string) (*model.ContactRequest, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	contactUser, err := service.userService.GetUserByHandle(ctx, contactHandle)
+	if err != nil {
+		slog.Info("no such user found by handle")
+		return nil, err
+	}
+
+	if contactUser.ID == user.ID {
+		return nil, ErrSelfContact
+	}
+	if userservice.IsBlocked(user, contactUser) {
+		return nil, cmnerr.ErrForbidden
+	}
+	if slices.Contains(user.Contacts, contactUser.ID) {
+		return nil, errors.Join(cmnerr.ErrAlreadyExists, ErrAlreadyContact)
+	}
+
+	counterRequest, err := service.contactRequestRepo.GetPendingRequest(ctx, contactUser.ID, user.ID)
+	if err != nil && !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return nil, err
+	}
+	if counterRequest != nil {
+		return service.AcceptContactRequest(ctx, userID, counterRequest.ID.Hex())
+	}
+
+	return service.contactRequestRepo.SaveContactRequest(ctx, &model.ContactRequest{
+		From:      user.ID,
+		To:        contactUser.ID,
+		Status:    model.ContactRequestStatusPending,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (service *ContactService) AcceptContactRequest(ctx context.Context, userID string, requestID string) (*model.ContactRequest, error) {
+	request, err := service.resolve(ctx, userID, requestID, "to", model.ContactRequestStatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, service.userRepo.AddMutualContacts(ctx, request.From, request.To)
+}
+
+func (service *ContactService) DeclineContactRequest(ctx context.Context, userID string, requestID string) (*model.ContactRequest, error) {
+	return service.resolve(ctx, userID, requestID, "to", model.ContactRequestStatusDeclined)
+}
+
+func (service *ContactService) CancelContactRequest(ctx context.Context, userID string, requestID string) (*model.ContactRequest, error) {
+	return service.resolve(ctx, userID, requestID, "from", model.ContactRequestStatusCancelled)
+}
+
+func (service *ContactService) GetIncomingRequests(ctx context.Context, userID string, pgParams types.PaginationParams) ([]dto.ContactRequestOutputDto, error) {
+	return service.getPendingRequests(ctx, "to", userID, pgParams)
+}
+
+func (service *ContactService) GetOutgoingRequests(ctx context.Context, userID string, pgParams types.PaginationParams) ([]dto.ContactRequestOutputDto, error) {
+	return service.getPendingRequests(ctx, "from", userID, pgParams)
+}
+
+func (service *ContactService) getPendingRequests(ctx context.Context, roleKey string, userID string, pgParams types.PaginationParams) ([]dto.ContactRequestOutputDto, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	requests, err := service.contactRequestRepo.GetPendingRequestsByUserID(ctx, roleKey, _userID, pgParams)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]dto.ContactRequestOutputDto, len(requests))
+	for i, request := range requests {
+		output[i] = ToContactRequestOutputDto(&request)
+	}
+	return output, nil
+}
+
+func (service *ContactService) RemoveContact(ctx context.Context, userID string, contactID string) error {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	_contactID, err := primitive.ObjectIDFromHex(contactID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+
+	return service.userRepo.RemoveMutualContacts(ctx, _userID, _contactID)
+}
+
+// BlockUser puts another user into the block list, dropping contacts & pending requests between the two
+func (service *ContactService) BlockUser(ctx context.Context, userID string, handle string) (*model.User, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	blockedUser, err := service.userService.GetUserByHandle(ctx, handle)
+	if err != nil {
+		slog.Info("no such user found by handle")
+		return nil, err
+	}
+	if blockedUser.ID == _userID {
+		return nil, ErrSelfBlock
+	}
+
+	if err = service.userRepo.AddToBlocked(ctx, _userID, blockedUser.ID); err != nil {
+		return nil, err
+	}
+	if err = service.userRepo.RemoveMutualContacts(ctx, _userID, blockedUser.ID); err != nil {
+		return nil, err
+	}
+
+	return blockedUser, service.contactRequestRepo.CancelPendingRequestsBetween(ctx, _userID, blockedUser.ID)
+}
+
+func (service *ContactService) UnblockUser(ctx context.Context, userID string, blockedID string) error {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	_blockedID, err := primitive.ObjectIDFromHex(blockedID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+
+	return service.userRepo.RemoveFromBlocked(ctx, _userID, _blockedID)
+}
+
+func (service *ContactService) GetBlockedUsers(ctx context.Context, userID string) ([]model.User, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.Blocked) == 0 {
+		return make([]model.User, 0), nil
+	}
+
+	return service.userRepo.GetUsersByIDs(ctx, user.Blocked)
+}
+
+func (service *ContactService) resolve(ctx context.Context, userID string, requestID string, roleKey string, status string) (*model.ContactRequest, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	_requestID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+
+	return service.contactRequestRepo.ResolvePendingRequest(ctx, _requestID, roleKey, _userID, status)
+}
+
+// ToContactRequestOutputDto exposes only public profiles of both parties
+func ToContactRequestOutputDto(request *model.ContactRequestPopulated) dto.ContactRequestOutputDto {
+	return dto.ContactRequestOutputDto{
+		ID:        request.ID.Hex(),
+		From:      userservice.ToUserPublicOutputDto(request.From),
+		To:        userservice.ToUserPublicOutputDto(request.To),
+		Status:    request.Status,
+		CreatedAt: request.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: request.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+var (
+	ErrSelfContact    = errors.New("cannot add yourself as a contact")
+	ErrAlreadyContact = errors.New("user is already a contact")
+	ErrSelfBlock      = errors.New("cannot block yourself")
+)
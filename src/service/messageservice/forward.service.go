@@ -0,0 +1,138 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// ForwardMessages copies messages of one chat of the user into another one in order they were written.
+// Copies keep attribution to originals and their images by reference. Polls are forwarded as their question.
+// Copies of disappearing messages expire no later than the originals
+func (service *MessageService) ForwardMessages(ctx context.Context, userID string, isBot bool, fromChatID string, data *dto.ForwardMessagesInputDto) ([]primitive.ObjectID, error) {
+	_fromChatID, err := primitive.ObjectIDFromHex(fromChatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_toChatID, err := primitive.ObjectIDFromHex(data.To)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	messageIDs := make([]primitive.ObjectID, 0, len(data.Messages))
+	for _, id := range data.Messages {
+		_id, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		if !slices.Contains(messageIDs, _id) {
+			messageIDs = append(messageIDs, _id)
+		}
+	}
+
+	fromChat, err := service.chatService.GetUserChat(ctx, _fromChatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+	toChat, err := service.chatService.GetWritableChat(ctx, _toChatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := service.messageRepo.GetChatMessagesByIDs(ctx, fromChat.ID, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != len(messageIDs) {
+		return nil, cmnerr.ErrNotFoundEntity
+	}
+	now := time.Now()
+	for i := range messages {
+		if messages[i].System {
+			return nil, ErrCannotForwardSystem
+		}
+		// expired message may still wait for the sweeper
+		if messages[i].ExpiresAt != nil && !messages[i].ExpiresAt.After(now) {
+			return nil, cmnerr.ErrNotFoundEntity
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+
+	visibility := map[primitive.ObjectID]bool{fromChat.ID: isVisibleTo(fromChat, toChat)}
+	forwarded := make([]primitive.ObjectID, 0, len(messages))
+	for i := range messages {
+		from := forwardedFrom(&messages[i])
+		if from.Chat != nil {
+			visible, err := service.isChatVisible(ctx, *from.Chat, toChat, visibility)
+			if err != nil {
+				return forwarded, err
+			}
+			if !visible {
+				from.Chat = nil
+			}
+		}
+
+		id, err := service.NewMessage(ctx, toChat.ID.Hex(), userID,
+			&dto.NewMessageInputDto{Text: messages[i].Text, Image: messages[i].Image},
+			AsBot(isBot), AsForwarded(from), ExpiringAt(messages[i].ExpiresAt))
+		if err != nil {
+			return forwarded, err
+		}
+		forwarded = append(forwarded, id)
+	}
+
+	return forwarded, nil
+}
+
+// forwardedFrom attributes copy to the origin of the message
+func forwardedFrom(message *model.Message) *model.ForwardedFrom {
+	if message.ForwardedFrom != nil {
+		from := *message.ForwardedFrom
+		return &from
+	}
+
+	chatID := message.Chat
+	return &model.ForwardedFrom{
+		Message:   message.ID,
+		User:      message.User,
+		Chat:      &chatID,
+		Hook:      message.Hook,
+		Bot:       message.Bot,
+		CreatedAt: message.CreatedAt,
+	}
+}
+
+// isChatVisible tells if the origin chat can be shown in the destination one, answers are cached in visibility
+func (service *MessageService) isChatVisible(ctx context.Context, chatID primitive.ObjectID, toChat *model.Chat, visibility map[primitive.ObjectID]bool) (bool, error) {
+	if visible, found := visibility[chatID]; found {
+		return visible, nil
+	}
+	chat, err := service.chatService.GetChatByID(ctx, chatID)
+	if err != nil && !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return false, err
+	}
+	visibility[chatID] = chat != nil && isVisibleTo(chat, toChat)
+	return visibility[chatID], nil
+}
+
+// isVisibleTo tells if all members of toChat are members of the chat, so they can open it
+func isVisibleTo(chat *model.Chat, toChat *model.Chat) bool {
+	return !slices.ContainsFunc(toChat.Users, func(member primitive.ObjectID) bool {
+		return !slices.Contains(chat.Users, member)
+	})
+}
+
+var (
+	ErrCannotForwardSystem = errors.New("system messages cannot be forwarded")
+)
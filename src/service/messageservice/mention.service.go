@@ -0,0 +1,142 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// Notification tells the user there's new message worth attention
+type Notification struct {
+	Chat    primitive.ObjectID `json:"chat"`
+	Message primitive.ObjectID `json:"message"`
+	User    primitive.ObjectID `json:"user"`
+	Text    string             `json:"text"`
+	// Mention is set if the user is mentioned, it notifies despite mute
+	Mention bool `json:"mention,omitempty"`
+}
+
+// resolveMentions turns @handles of chat members into mentions of the message and returns mentioned users
+// except the author. System messages and forwarded copies mention nobody
+func (service *MessageService) resolveMentions(ctx context.Context, chat *model.Chat, message *model.Message) ([]primitive.ObjectID, error) {
+	if message.System || message.ForwardedFrom != nil {
+		return nil, nil
+	}
+	found := handlehelper.FindMentions(message.Text)
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	members, err := service.userService.GetUsersByIDs(ctx, chat.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	mentioned := []primitive.ObjectID{}
+	for _, mention := range found {
+		i := slices.IndexFunc(members, func(member model.User) bool { return member.Handle == mention.Handle })
+		if i < 0 {
+			continue
+		}
+		message.Mentions = append(message.Mentions, model.Mention{User: members[i].ID, Start: mention.Start, End: mention.End})
+		if members[i].ID != message.User && !slices.Contains(mentioned, members[i].ID) {
+			mentioned = append(mentioned, members[i].ID)
+		}
+	}
+	return mentioned, nil
+}
+
+// notify sends notification about new message to members according to their settings of the chat,
+// mentions break through mute
+func (service *MessageService) notify(ctx context.Context, chat *model.Chat, message *model.Message, mentioned []primitive.ObjectID) error {
+	recipients := slices.DeleteFunc(slices.Clone(chat.Users), func(userID primitive.ObjectID) bool {
+		return userID == message.User
+	})
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	settings, err := service.chatService.GetMembersSettings(ctx, chat.ID, recipients)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	recipients = slices.DeleteFunc(recipients, func(userID primitive.ObjectID) bool {
+		i := slices.IndexFunc(settings, func(setting model.ChatSettings) bool { return setting.User == userID })
+		if i < 0 {
+			return false
+		}
+		return !settings[i].Notifies(now, slices.Contains(mentioned, userID))
+	})
+	mentioned = slices.DeleteFunc(slices.Clone(mentioned), func(userID primitive.ObjectID) bool {
+		return !slices.Contains(recipients, userID)
+	})
+	recipients = slices.DeleteFunc(recipients, func(userID primitive.ObjectID) bool {
+		return slices.Contains(mentioned, userID)
+	})
+
+	notification := Notification{Chat: chat.ID, Message: message.ID, User: message.User, Text: snippet(message.Text)}
+	if len(recipients) > 0 {
+		eventhelper.Publish(eventhelper.Event{
+			Type:       eventhelper.EventNotification,
+			Payload:    notification,
+			Recipients: recipients,
+		})
+	}
+	if len(mentioned) > 0 {
+		notification.Mention = true
+		eventhelper.Publish(eventhelper.Event{
+			Type:       eventhelper.EventNotification,
+			Payload:    notification,
+			Recipients: mentioned,
+		})
+	}
+	return nil
+}
+
+// ListMentions returns messages mentioning the user in chats of the user, the latest first
+func (service *MessageService) ListMentions(ctx context.Context, userID string, pgParams types.PaginationParams) ([]model.MessagePopulated, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	chatIDs, err := service.chatService.GetUserChatIDs(ctx, _userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chatIDs) == 0 {
+		return make([]model.MessagePopulated, 0), nil
+	}
+
+	messages, err := service.messageRepo.GetUserMentions(ctx, _userID, chatIDs, pgParams)
+	if err != nil {
+		return nil, err
+	}
+	return service.populateMessages(ctx, _userID, messages)
+}
+
+// GetUnreadMentions returns chats where the user has unread mentions with their counts
+func (service *MessageService) GetUnreadMentions(ctx context.Context, userID string) ([]model.MentionCounter, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return service.mentionCounterRepo.GetUnreadMentions(ctx, _userID)
+}
+
+// ReadMentions resets unread mentions counter of the user in the chat
+func (service *MessageService) ReadMentions(ctx context.Context, chatID string, userID string) error {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	chat, err := service.chatService.GetUserChat(ctx, _chatID, _userID)
+	if err != nil {
+		return err
+	}
+	return service.mentionCounterRepo.ResetUnreadMentions(ctx, chat.ID, _userID)
+}
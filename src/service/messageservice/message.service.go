@@ -2,42 +2,106 @@ package messageservice
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/mentioncounterrepo"
 	"github.com/MykolaSainiuk/schatgo/src/repo/messagerepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/scheduledmessagerepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/webhookdeliveryrepo"
 	"github.com/MykolaSainiuk/schatgo/src/service/chatservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/commandservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/pollservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/userservice"
 )
 
 type MessageService struct {
-	messageRepo *messagerepo.MessageRepo
+	messageRepo        *messagerepo.MessageRepo
+	scheduledRepo      *scheduledmessagerepo.ScheduledMessageRepo
+	deliveryRepo       *webhookdeliveryrepo.WebhookDeliveryRepo
+	mentionCounterRepo *mentioncounterrepo.MentionCounterRepo
 
-	chatService *chatservice.ChatService
+	chatService    *chatservice.ChatService
+	commandService *commandservice.CommandService
+	pollService    *pollservice.PollService
+	userService    *userservice.UserService
 }
 
 func NewMessageService(srv types.IServer) *MessageService {
 	return &MessageService{
-		messageRepo: messagerepo.NewMessageRepo(srv.GetDB()),
+		messageRepo:        messagerepo.NewMessageRepo(srv.GetDB()),
+		scheduledRepo:      scheduledmessagerepo.NewScheduledMessageRepo(srv.GetDB()),
+		deliveryRepo:       webhookdeliveryrepo.NewWebhookDeliveryRepo(srv.GetDB()),
+		mentionCounterRepo: mentioncounterrepo.NewMentionCounterRepo(srv.GetDB()),
 
-		chatService: chatservice.NewChatService(srv),
+		chatService:    chatservice.NewChatService(srv),
+		commandService: commandservice.NewCommandService(srv),
+		pollService:    pollservice.NewPollService(srv),
+		userService:    userservice.NewUserService(srv),
 	}
 }
 
-func (service *MessageService) NewMessage(ctx context.Context, chatId string, userId string, data *dto.NewMessageInputDto) (primitive.ObjectID, error) {
+// NewMessageOption tunes message before it's saved
+type NewMessageOption func(message *model.Message)
+
+// AsBot marks message as posted by bot
+func AsBot(isBot bool) NewMessageOption {
+	return func(message *model.Message) {
+		message.Bot = isBot
+	}
+}
+
+// ViaHook marks message as posted through incoming hook
+func ViaHook(hook *model.MessageHook) NewMessageOption {
+	return func(message *model.Message) {
+		message.Hook = hook
+	}
+}
+
+// WithPoll makes message a poll, its text is the question
+func WithPoll(poll *model.Poll) NewMessageOption {
+	return func(message *model.Message) {
+		message.Poll = poll
+	}
+}
+
+// AsForwarded attributes message to the original one
+func AsForwarded(from *model.ForwardedFrom) NewMessageOption {
+	return func(message *model.Message) {
+		message.ForwardedFrom = from
+	}
+}
+
+// ExpiringAt makes message disappear no later than at expiresAt, even if the chat has no timer
+func ExpiringAt(expiresAt *time.Time) NewMessageOption {
+	return func(message *model.Message) {
+		message.ExpiresAt = expiresAt
+	}
+}
+
+// NewMessage posts message into the chat. Slash commands of users are dispatched instead of being posted:
+// the ID of stored reply is returned then, or nil ID if there's none (e.g. the reply is ephemeral)
+func (service *MessageService) NewMessage(ctx context.Context, chatId string, userId string, data *dto.NewMessageInputDto, opts ...NewMessageOption) (primitive.ObjectID, error) {
 	_chatId, _ := primitive.ObjectIDFromHex(chatId)
+	_userId, _ := primitive.ObjectIDFromHex(userId)
 
-	chat, err := service.chatService.GetChatByID(ctx, _chatId)
+	chat, err := service.chatService.GetWritableChat(ctx, _chatId, _userId)
 	if err != nil || chat == nil {
-		slog.Info("no chat found by such id")
+		slog.Info("no writable chat found by such id")
 		return primitive.NilObjectID, err
 	}
 
-	_userId, _ := primitive.ObjectIDFromHex(userId)
 	newMessage := &model.Message{
 		Text:      data.Text,
 		Image:     data.Image,
@@ -49,25 +113,297 @@ func (service *MessageService) NewMessage(ctx context.Context, chatId string, us
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	for _, opt := range opts {
+		opt(newMessage)
+	}
+
+	// bots & hooks cannot run commands, poll question is never a command, scheduled & forwarded text is posted as is
+	if !newMessage.Bot && newMessage.Hook == nil && newMessage.Poll == nil && newMessage.ID.IsZero() && newMessage.ForwardedFrom == nil {
+		reply, handled, err := service.commandService.Dispatch(ctx, chat, _userId, data.Text)
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		if handled {
+			return service.postReply(ctx, chat, _userId, reply)
+		}
+	}
+
+	return service.saveMessage(ctx, chat, newMessage)
+}
+
+// postReply stores reply of command or sends it only to the caller if it's ephemeral
+func (service *MessageService) postReply(ctx context.Context, chat *model.Chat, userID primitive.ObjectID, reply *commandservice.Reply) (primitive.ObjectID, error) {
+	if reply == nil {
+		return primitive.NilObjectID, nil
+	}
+
+	if reply.Ephemeral {
+		ephemeral := EphemeralMessage{Text: reply.Text, Chat: chat.ID, CreatedAt: time.Now()}
+		if !reply.From.IsZero() {
+			ephemeral.Bot = &reply.From
+		}
+		eventhelper.Publish(eventhelper.Event{
+			Type:       eventhelper.EventMessageEphemeral,
+			Payload:    ephemeral,
+			Recipients: []primitive.ObjectID{userID},
+		})
+		return primitive.NilObjectID, nil
+	}
+
+	message := &model.Message{
+		Text:      reply.Text,
+		Sent:      true,
+		Received:  true,
+		System:    true,
+		User:      userID,
+		Chat:      chat.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if !reply.From.IsZero() {
+		message.System = false
+		message.Bot = true
+		message.User = reply.From
+	}
+	// poll made by command is the caller's own message
+	if reply.Poll != nil {
+		message.System = false
+		message.Poll = reply.Poll
+	}
+
+	return service.saveMessage(ctx, chat, message)
+}
+
+func (service *MessageService) saveMessage(ctx context.Context, chat *model.Chat, newMessage *model.Message) (primitive.ObjectID, error) {
+	if chat.RetentionSeconds > 0 {
+		expiresAt := newMessage.CreatedAt.Add(time.Duration(chat.RetentionSeconds) * time.Second)
+		if newMessage.ExpiresAt == nil || expiresAt.Before(*newMessage.ExpiresAt) {
+			newMessage.ExpiresAt = &expiresAt
+		}
+	}
+	mentioned, err := service.resolveMentions(ctx, chat, newMessage)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
 
 	newMessageId, err := service.messageRepo.SaveMessage(ctx, newMessage)
 	if err != nil || newMessageId == primitive.NilObjectID {
 		return primitive.NilObjectID, err
 	}
 
-	err = service.chatService.SetLastMessage(ctx, chat.ID, newMessageId)
+	newMessage.ID = newMessageId
+
+	if err = service.chatService.SetLastMessage(ctx, chat.ID, newMessageId, newMessage.ExpiresAt); err != nil {
+		return newMessageId, err
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventMessageCreated,
+		Payload:    newMessage,
+		Recipients: chat.Users,
+		Chat:       chat.ID,
+		ExpiresAt:  newMessage.ExpiresAt,
+	})
 
-	return newMessageId, err
+	if err = service.mentionCounterRepo.IncUnreadMentions(ctx, chat.ID, mentioned); err != nil {
+		return newMessageId, err
+	}
+	return newMessageId, service.notify(ctx, chat, newMessage, mentioned)
 }
 
-func (service *MessageService) GetAllMessages(ctx context.Context, chatID string) ([]model.MessagePopulated, error) {
-	return service.messageRepo.GetMessagesByChatID(ctx, chatID, types.PaginationParams{})
+// EphemeralMessage is shown only to one user and isn't stored
+type EphemeralMessage struct {
+	Text      string              `json:"text"`
+	Chat      primitive.ObjectID  `json:"chat"`
+	Bot       *primitive.ObjectID `json:"bot,omitempty"`
+	CreatedAt time.Time           `json:"createdAt"`
 }
 
-func (service *MessageService) GetMessagesPaginated(ctx context.Context, chatID string, pgParams types.PaginationParams) ([]model.MessagePopulated, error) {
-	return service.messageRepo.GetMessagesByChatID(ctx, chatID, pgParams)
+func (service *MessageService) GetAllMessages(ctx context.Context, chatID string, userID string) ([]model.MessagePopulated, error) {
+	return service.GetMessagesPaginated(ctx, chatID, userID, types.PaginationParams{})
 }
 
-func (service *MessageService) ClearChatMessages(ctx context.Context, chatID string) error {
-	return service.messageRepo.RemoveAllMessagesByChatID(ctx, chatID)
+// GetMessagesPaginated lists messages of the chat the user is member of
+func (service *MessageService) GetMessagesPaginated(ctx context.Context, chatID string, userID string, pgParams types.PaginationParams) ([]model.MessagePopulated, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	if _, err := service.chatService.GetUserChat(ctx, _chatID, _userID); err != nil {
+		return nil, err
+	}
+
+	messages, err := service.messageRepo.GetMessagesByChatID(ctx, chatID, pgParams)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		userservice.HideFromBlocked(_userID, messages[i].User)
+	}
+	if err := service.pollService.AttachResults(ctx, messages, _userID); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// populateMessages attaches authors to messages as the viewer sees them
+func (service *MessageService) populateMessages(ctx context.Context, viewerID primitive.ObjectID, messages []model.Message) ([]model.MessagePopulated, error) {
+	userIDs := make([]primitive.ObjectID, 0, len(messages))
+	for i := range messages {
+		if !slices.Contains(userIDs, messages[i].User) {
+			userIDs = append(userIDs, messages[i].User)
+		}
+	}
+	users, err := service.userService.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	populated := make([]model.MessagePopulated, 0, len(messages))
+	for i := range messages {
+		message := model.MessagePopulated{Message: &messages[i]}
+		if j := slices.IndexFunc(users, func(user model.User) bool { return user.ID == messages[i].User }); j >= 0 {
+			message.User = &users[j]
+		}
+		userservice.HideFromBlocked(viewerID, message.User)
+		populated = append(populated, message)
+	}
+
+	if err := service.pollService.AttachResults(ctx, populated, viewerID); err != nil {
+		return nil, err
+	}
+	return populated, nil
+}
+
+// ClearChatMessages deletes all messages of the chat the user is member of
+func (service *MessageService) ClearChatMessages(ctx context.Context, chatID string, userID string) error {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	chat, err := service.chatService.GetUserChat(ctx, _chatID, _userID)
+	if err != nil {
+		return err
+	}
+
+	if err := service.messageRepo.RemoveAllMessagesByChatID(ctx, chatID); err != nil {
+		return err
+	}
+	if err := service.pollService.ClearChatVotes(ctx, chat.ID); err != nil {
+		return err
+	}
+	if err := service.chatService.ClearPinnedMessages(ctx, chat.ID); err != nil {
+		return err
+	}
+	if err := service.mentionCounterRepo.ResetChatUnreadMentions(ctx, chat.ID); err != nil {
+		return err
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventMessageDeleted,
+		Payload:    MessagesDeletedPayload{Chat: chat.ID, All: true, By: _userID},
+		Recipients: chat.Users,
+		Chat:       chat.ID,
+	})
+
+	return nil
 }
+
+// MessagesDeletedPayload describes which messages of the chat are gone
+type MessagesDeletedPayload struct {
+	Chat     primitive.ObjectID   `json:"chat"`
+	Messages []primitive.ObjectID `json:"messages,omitempty"`
+	All      bool                 `json:"all,omitempty"`
+	// By is nil ID for messages which have expired
+	By primitive.ObjectID `json:"by"`
+}
+
+// SearchMessages does full-text search over chats of the user (or within one chat of his)
+func (service *MessageService) SearchMessages(ctx context.Context, userID string, chatID string, query string, pgParams types.PaginationParams) ([]model.MessageSearchHit, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	var chatIDs []primitive.ObjectID
+	if chatID != "" {
+		_chatID, err := primitive.ObjectIDFromHex(chatID)
+		if err != nil {
+			return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+		}
+		chat, err := service.chatService.GetUserChat(ctx, _chatID, _userID)
+		if err != nil {
+			return nil, err
+		}
+		chatIDs = []primitive.ObjectID{chat.ID}
+	} else {
+		var err error
+		if chatIDs, err = service.chatService.GetUserChatIDs(ctx, _userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(chatIDs) == 0 {
+		return make([]model.MessageSearchHit, 0), nil
+	}
+
+	hits, err := service.messageRepo.SearchMessages(ctx, query, chatIDs, pgParams)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(query)
+	for i := range hits {
+		hits[i].Highlights = highlight(hits[i].Text, terms)
+		userservice.HideFromBlocked(_userID, hits[i].User)
+	}
+
+	return hits, nil
+}
+
+// searchTerms extracts lowercased positive terms out of $text search query
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "-") {
+			continue
+		}
+		term = strings.ToLower(strings.Trim(term, `"`))
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// highlight marks words starting with any of terms (text index is stemmed, so prefix is good enough)
+func highlight(text string, terms []string) []model.TextRange {
+	ranges := []model.TextRange{}
+	runes := []rune(text)
+
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		word := strings.ToLower(string(runes[start:end]))
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) || strings.HasPrefix(term, word) && len(word) >= minStemLength {
+				ranges = append(ranges, model.TextRange{Start: start, End: end})
+				break
+			}
+		}
+		start = end
+	}
+
+	return ranges
+}
+
+const minStemLength int = 3
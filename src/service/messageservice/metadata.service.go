@@ -0,0 +1,84 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// UpdateChatMetadata changes name, description and icon of the chat, members are notified by system message.
+// Only chat creator may do it, or either user of direct chat unless there is a block between them
+func (service *MessageService) UpdateChatMetadata(ctx context.Context, chatID string, userID string, data *dto.UpdateChatInputDto) (*model.Chat, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	chat, err := service.chatService.GetAdminChat(ctx, _chatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]any{}
+	notes := []string{}
+	if data.Name != nil && *data.Name != chat.Name {
+		changes["name"] = *data.Name
+		chat.Name = *data.Name
+		if chat.Name == "" {
+			notes = append(notes, "removed the chat name")
+		} else {
+			notes = append(notes, fmt.Sprintf("renamed the chat to %q", chat.Name))
+		}
+	}
+	if data.Description != nil && *data.Description != chat.Description {
+		changes["description"] = *data.Description
+		chat.Description = *data.Description
+		if chat.Description == "" {
+			notes = append(notes, "removed the chat description")
+		} else {
+			notes = append(notes, "changed the chat description")
+		}
+	}
+	if data.IconUri != nil && *data.IconUri != chat.IconUri {
+		changes["iconUri"] = *data.IconUri
+		chat.IconUri = *data.IconUri
+		if chat.IconUri == "" {
+			notes = append(notes, "removed the chat icon")
+		} else {
+			notes = append(notes, "changed the chat icon")
+		}
+	}
+	if len(changes) == 0 {
+		return chat, nil
+	}
+
+	user, err := service.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.chatService.UpdateChat(ctx, chat.ID, changes); err != nil {
+		return nil, err
+	}
+	chat.UpdatedAt = time.Now()
+
+	_, err = service.saveMessage(ctx, chat, &model.Message{
+		Text:      fmt.Sprintf("@%s %s", user.Handle, strings.Join(notes, ", ")),
+		Sent:      true,
+		Received:  true,
+		System:    true,
+		User:      _userID,
+		Chat:      chat.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	return chat, err
+}
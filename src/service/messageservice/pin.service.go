@@ -0,0 +1,171 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// PinnedPayload is the pinned list of the chat after it has changed
+type PinnedPayload struct {
+	Chat           primitive.ObjectID   `json:"chat"`
+	PinnedMessages []primitive.ObjectID `json:"pinnedMessages"`
+	By             primitive.ObjectID   `json:"by"`
+}
+
+// PinMessage puts message of the chat on top of pinned ones and posts system notice about it. Only chat admins may do it
+func (service *MessageService) PinMessage(ctx context.Context, chatID string, userID string, messageID string) error {
+	_chatID, _userID, _messageID, err := parseChatItemIDs(chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+	chat, err := service.chatService.GetAdminChat(ctx, _chatID, _userID)
+	if err != nil {
+		return err
+	}
+	message, err := service.messageRepo.GetChatMessage(ctx, chat.ID, _messageID)
+	if err != nil {
+		return err
+	}
+	user, err := service.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := service.chatService.PinMessage(ctx, chat.ID, message.ID, MaxPinnedMessages)
+	if err != nil {
+		return err
+	}
+	if !pinned {
+		if slices.Contains(chat.PinnedMessages, message.ID) {
+			return ErrAlreadyPinned
+		}
+		return ErrTooManyPinned
+	}
+	if err := service.publishPinned(ctx, chat.ID, _userID); err != nil {
+		return err
+	}
+
+	_, err = service.saveMessage(ctx, chat, &model.Message{
+		Text:      fmt.Sprintf("@%s pinned a message: %q", user.Handle, snippet(message.Text)),
+		Sent:      true,
+		Received:  true,
+		System:    true,
+		User:      _userID,
+		Chat:      chat.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	return err
+}
+
+// UnpinMessage drops message from pinned ones of the chat. Only chat admins may do it
+func (service *MessageService) UnpinMessage(ctx context.Context, chatID string, userID string, messageID string) error {
+	_chatID, _userID, _messageID, err := parseChatItemIDs(chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+	chat, err := service.chatService.GetAdminChat(ctx, _chatID, _userID)
+	if err != nil {
+		return err
+	}
+
+	unpinned, err := service.chatService.UnpinMessages(ctx, chat.ID, []primitive.ObjectID{_messageID})
+	if err != nil {
+		return err
+	}
+	if !unpinned {
+		return errors.Join(cmnerr.ErrNotFoundEntity, ErrNotPinned)
+	}
+	return service.publishPinned(ctx, chat.ID, _userID)
+}
+
+// GetPinnedMessages returns pinned messages of the chat, the latest pin first. They don't depend
+// on history pages, so the pinned banner can be shown without loading them
+func (service *MessageService) GetPinnedMessages(ctx context.Context, chatID string, userID string) ([]model.MessagePopulated, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	chat, err := service.chatService.GetUserChat(ctx, _chatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chat.PinnedMessages) == 0 {
+		return make([]model.MessagePopulated, 0), nil
+	}
+
+	messages, err := service.messageRepo.GetChatMessagesByIDs(ctx, chat.ID, chat.PinnedMessages)
+	if err != nil {
+		return nil, err
+	}
+	// in order of pins
+	sort.SliceStable(messages, func(i, j int) bool {
+		return slices.Index(chat.PinnedMessages, messages[i].ID) < slices.Index(chat.PinnedMessages, messages[j].ID)
+	})
+
+	return service.populateMessages(ctx, _userID, messages)
+}
+
+// unpinGone drops deleted messages from pinned ones of the chat
+func (service *MessageService) unpinGone(ctx context.Context, chat *model.Chat, messageIDs []primitive.ObjectID) error {
+	pinned := slices.ContainsFunc(messageIDs, func(id primitive.ObjectID) bool {
+		return slices.Contains(chat.PinnedMessages, id)
+	})
+	if !pinned {
+		return nil
+	}
+	if _, err := service.chatService.UnpinMessages(ctx, chat.ID, messageIDs); err != nil {
+		return err
+	}
+	return service.publishPinned(ctx, chat.ID, primitive.NilObjectID)
+}
+
+func (service *MessageService) publishPinned(ctx context.Context, chatID primitive.ObjectID, by primitive.ObjectID) error {
+	chat, err := service.chatService.GetChatByID(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	pinned := chat.PinnedMessages
+	if pinned == nil {
+		pinned = []primitive.ObjectID{}
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventPinsUpdated,
+		Payload:    PinnedPayload{Chat: chat.ID, PinnedMessages: pinned, By: by},
+		Recipients: chat.Users,
+		Chat:       chat.ID,
+	})
+	return nil
+}
+
+// snippet shortens text for notices
+func snippet(text string) string {
+	runes := []rune(text)
+	if len(runes) <= SnippetLength {
+		return text
+	}
+	return string(runes[:SnippetLength]) + "…"
+}
+
+const (
+	MaxPinnedMessages = 10
+	SnippetLength     = 50
+)
+
+var (
+	ErrAlreadyPinned = errors.New("message is pinned already")
+	ErrTooManyPinned = errors.New("pinned messages limit of the chat is reached")
+	ErrNotPinned     = errors.New("message isn't pinned")
+)
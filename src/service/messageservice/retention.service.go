@@ -0,0 +1,156 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/timehelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// SetChatRetention sets timer after which new messages of the chat disappear, 0 turns it off.
+// Only chat admins can set it. Members learn about the change from system message
+func (service *MessageService) SetChatRetention(ctx context.Context, chatID string, userID string, seconds int64) error {
+	if seconds != 0 && (seconds < MinRetentionSeconds || seconds > MaxRetentionSeconds) {
+		return ErrInvalidRetention
+	}
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	chat, err := service.chatService.GetAdminChat(ctx, _chatID, _userID)
+	if err != nil {
+		return err
+	}
+	if chat.RetentionSeconds == seconds {
+		return nil
+	}
+	user, err := service.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := service.chatService.SetRetention(ctx, chat.ID, seconds); err != nil {
+		return err
+	}
+	chat.RetentionSeconds = seconds
+
+	text := fmt.Sprintf("@%s turned off disappearing messages", user.Handle)
+	if seconds > 0 {
+		text = fmt.Sprintf("@%s set messages to disappear after %s", user.Handle, timehelper.FormatDuration(time.Duration(seconds)*time.Second))
+	}
+	_, err = service.saveMessage(ctx, chat, &model.Message{
+		Text:      text,
+		Sent:      true,
+		Received:  true,
+		System:    true,
+		User:      _userID,
+		Chat:      chat.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	return err
+}
+
+// SweepExpiredMessages deletes expired messages ahead of TTL monitor, so members learn which ones are gone,
+// and moves last message of affected chats (also ones TTL monitor has got to first) to the newest one left.
+// Webhook deliveries carrying expired messages are deleted as well
+func (service *MessageService) SweepExpiredMessages(ctx context.Context) (bool, error) {
+	now := time.Now()
+	expired, err := service.messageRepo.GetExpiredMessages(ctx, now, SweepBatchSize)
+	if err != nil {
+		return false, err
+	}
+
+	byChat := make(map[primitive.ObjectID][]primitive.ObjectID)
+	ids := make([]primitive.ObjectID, 0, len(expired))
+	for i := range expired {
+		byChat[expired[i].Chat] = append(byChat[expired[i].Chat], expired[i].ID)
+		ids = append(ids, expired[i].ID)
+	}
+	if len(ids) > 0 {
+		if err := service.messageRepo.RemoveMessagesByIDs(ctx, ids); err != nil {
+			return false, err
+		}
+		if err := service.pollService.ClearMessagesVotes(ctx, ids); err != nil {
+			return false, err
+		}
+	}
+
+	if err := service.deliveryRepo.DeleteExpiredPayloads(ctx, now); err != nil {
+		return false, err
+	}
+
+	stale, err := service.chatService.GetChatsWithExpiredLastMessage(ctx, now, SweepBatchSize)
+	if err != nil {
+		return false, err
+	}
+	for _, chatID := range stale {
+		if _, found := byChat[chatID]; !found {
+			byChat[chatID] = nil
+		}
+	}
+
+	for chatID, messageIDs := range byChat {
+		chat, err := service.chatService.GetChatByID(ctx, chatID)
+		if err != nil {
+			if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+				continue
+			}
+			return false, err
+		}
+		if err := service.refreshLastMessage(ctx, chat, now); err != nil {
+			return false, err
+		}
+		if err := service.unpinGone(ctx, chat, messageIDs); err != nil {
+			return false, err
+		}
+		if len(messageIDs) > 0 {
+			eventhelper.Publish(eventhelper.Event{
+				Type:       eventhelper.EventMessageDeleted,
+				Payload:    MessagesDeletedPayload{Chat: chat.ID, Messages: messageIDs},
+				Recipients: chat.Users,
+				Chat:       chat.ID,
+			})
+		}
+	}
+
+	return len(expired) == SweepBatchSize, nil
+}
+
+// refreshLastMessage points the chat to its newest message left, nil ID if there's none
+func (service *MessageService) refreshLastMessage(ctx context.Context, chat *model.Chat, now time.Time) error {
+	latest, err := service.messageRepo.GetLatestChatMessage(ctx, chat.ID, now)
+	if err != nil {
+		return err
+	}
+	lastMessageID := primitive.NilObjectID
+	var expiresAt *time.Time
+	if latest != nil {
+		lastMessageID = latest.ID
+		expiresAt = latest.ExpiresAt
+	}
+	if lastMessageID == chat.LastMessage {
+		return nil
+	}
+	return service.chatService.SetLastMessage(ctx, chat.ID, lastMessageID, expiresAt)
+}
+
+const (
+	MinRetentionSeconds = 5
+	MaxRetentionSeconds = 365 * 24 * 60 * 60
+
+	SweepBatchSize = 100
+)
+
+var (
+	ErrInvalidRetention = errors.New("retention must be 0 (off) or from 5 seconds to 365 days")
+)
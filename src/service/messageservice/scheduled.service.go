@@ -0,0 +1,162 @@
+package messageservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// AsScheduled posts scheduled message under its own ID, so it cannot be posted twice.
+// It's posted as it was typed, slash commands aren't run
+func AsScheduled(id primitive.ObjectID) NewMessageOption {
+	return func(message *model.Message) {
+		message.ID = id
+	}
+}
+
+// ScheduleMessage keeps message to be posted into the chat at data.SendAt
+func (service *MessageService) ScheduleMessage(ctx context.Context, chatID string, userID string, data *dto.NewMessageInputDto, isBot bool) (*model.ScheduledMessage, error) {
+	if err := checkSendAt(data.SendAt); err != nil {
+		return nil, err
+	}
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	chat, err := service.chatService.GetWritableChat(ctx, _chatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := service.scheduledRepo.CountUserPending(ctx, _userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxScheduledPerUser {
+		return nil, ErrTooManyScheduled
+	}
+
+	now := time.Now()
+	scheduled := &model.ScheduledMessage{
+		Chat:      chat.ID,
+		User:      _userID,
+		Text:      data.Text,
+		Image:     data.Image,
+		Bot:       isBot,
+		SendAt:    data.SendAt.UTC(),
+		Status:    model.ScheduledStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if scheduled.ID, err = service.scheduledRepo.SaveScheduled(ctx, scheduled); err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+// ListScheduled returns messages the user has scheduled into the chat and which aren't posted yet
+func (service *MessageService) ListScheduled(ctx context.Context, chatID string, userID string) ([]model.ScheduledMessage, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return service.scheduledRepo.GetUserScheduled(ctx, _chatID, _userID)
+}
+
+// EditScheduled changes text, image or time of message which is still pending
+func (service *MessageService) EditScheduled(ctx context.Context, chatID string, userID string, scheduledID string, data *dto.EditScheduledMessageInputDto) (*model.ScheduledMessage, error) {
+	if data.SendAt != nil {
+		if err := checkSendAt(data.SendAt); err != nil {
+			return nil, err
+		}
+		sendAt := data.SendAt.UTC()
+		data.SendAt = &sendAt
+	}
+	_chatID, _userID, _scheduledID, err := parseChatItemIDs(chatID, userID, scheduledID)
+	if err != nil {
+		return nil, err
+	}
+	return service.scheduledRepo.UpdatePending(ctx, _chatID, _userID, _scheduledID, data.Text, data.Image, data.SendAt)
+}
+
+// CancelScheduled drops pending (or failed) message, one being posted right now cannot be cancelled
+func (service *MessageService) CancelScheduled(ctx context.Context, chatID string, userID string, scheduledID string) error {
+	_chatID, _userID, _scheduledID, err := parseChatItemIDs(chatID, userID, scheduledID)
+	if err != nil {
+		return err
+	}
+	return service.scheduledRepo.DeleteUnsent(ctx, _chatID, _userID, _scheduledID)
+}
+
+// SendNextScheduled posts the most overdue scheduled message, reports false if nothing is due.
+// Messages are claimed atomically, so replicas don't race, and posted under the scheduled ID,
+// so the one retried after crash of a replica cannot appear twice
+func (service *MessageService) SendNextScheduled(ctx context.Context) (bool, error) {
+	scheduled, err := service.scheduledRepo.ClaimDue(ctx, ScheduledLockTime)
+	if err != nil || scheduled == nil {
+		return false, err
+	}
+
+	_, err = service.NewMessage(ctx, scheduled.Chat.Hex(), scheduled.User.Hex(),
+		&dto.NewMessageInputDto{Text: scheduled.Text, Image: scheduled.Image},
+		AsBot(scheduled.Bot), AsScheduled(scheduled.ID))
+	switch {
+	case err == nil, mongo.IsDuplicateKeyError(err):
+		return true, service.scheduledRepo.DeleteSent(ctx, scheduled.ID)
+	case errors.Is(err, cmnerr.ErrNotFoundEntity), errors.Is(err, cmnerr.ErrForbidden):
+		// the author isn't allowed to write there anymore
+		return true, service.scheduledRepo.MarkFailed(ctx, scheduled.ID, "cannot write into the chat")
+	case scheduled.Attempts >= MaxScheduledAttempts:
+		slog.Info("scheduled message has failed", slog.String("scheduledId", scheduled.ID.Hex()), slog.String("error", err.Error()))
+		return true, service.scheduledRepo.MarkFailed(ctx, scheduled.ID, err.Error())
+	default:
+		// stays claimed, so it's retried once the lock expires
+		return true, errors.Join(err, service.scheduledRepo.Release(ctx, scheduled.ID, err.Error()))
+	}
+}
+
+func checkSendAt(sendAt *time.Time) error {
+	now := time.Now()
+	if sendAt == nil || !sendAt.After(now) || sendAt.Sub(now) > MaxScheduleAhead {
+		return ErrInvalidSendAt
+	}
+	return nil
+}
+
+// parseChatItemIDs parses IDs of the chat, the user and an item of the chat (e.g. message)
+func parseChatItemIDs(chatID string, userID string, itemID string) (primitive.ObjectID, primitive.ObjectID, primitive.ObjectID, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, primitive.NilObjectID, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_itemID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, primitive.NilObjectID, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return _chatID, _userID, _itemID, nil
+}
+
+const (
+	MaxScheduledPerUser  = 100
+	MaxScheduledAttempts = 5
+	MaxScheduleAhead     = 365 * 24 * time.Hour
+	// ScheduledLockTime is how long claimed message is hidden from other replicas
+	ScheduledLockTime = time.Minute
+)
+
+var (
+	ErrInvalidSendAt    = errors.New("sendAt must be in the future, within a year")
+	ErrTooManyScheduled = errors.New("scheduled messages limit is reached")
+)
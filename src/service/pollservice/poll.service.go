@@ -0,0 +1,262 @@
+package pollservice
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/messagerepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/pollvoterepo"
+	"github.com/MykolaSainiuk/schatgo/src/service/chatservice"
+)
+
+type PollService struct {
+	messageRepo  *messagerepo.MessageRepo
+	pollVoteRepo *pollvoterepo.PollVoteRepo
+
+	chatService *chatservice.ChatService
+}
+
+func NewPollService(srv types.IServer) *PollService {
+	return &PollService{
+		messageRepo:  messagerepo.NewMessageRepo(srv.GetDB()),
+		pollVoteRepo: pollvoterepo.NewPollVoteRepo(srv.GetDB()),
+
+		chatService: chatservice.NewChatService(srv),
+	}
+}
+
+// BuildPoll validates poll options, the question is the text of the message carrying the poll
+func BuildPoll(options []string, multipleChoice bool, anonymous bool, closesAt *time.Time) (*model.Poll, error) {
+	if len(options) < MinPollOptions || len(options) > MaxPollOptions {
+		return nil, ErrInvalidPollOptions
+	}
+
+	poll := &model.Poll{
+		Options:        make([]model.PollOption, 0, len(options)),
+		MultipleChoice: multipleChoice,
+		Anonymous:      anonymous,
+	}
+	seen := make(map[string]struct{}, len(options))
+	for i, text := range options {
+		text = strings.TrimSpace(text)
+		if text == "" || utf8.RuneCountInString(text) > MaxPollOptionLength {
+			return nil, ErrInvalidPollOptions
+		}
+		key := strings.ToLower(text)
+		if _, duplicate := seen[key]; duplicate {
+			return nil, ErrInvalidPollOptions
+		}
+		seen[key] = struct{}{}
+		poll.Options = append(poll.Options, model.PollOption{ID: i, Text: text})
+	}
+
+	if closesAt != nil {
+		now := time.Now()
+		if !closesAt.After(now) || closesAt.Sub(now) > MaxPollDuration {
+			return nil, ErrInvalidPollClose
+		}
+		closes := closesAt.UTC()
+		poll.ClosesAt = &closes
+	}
+
+	return poll, nil
+}
+
+// Vote sets options the user chooses in the poll, empty options retract the vote.
+// Only users who may post into the chat can vote. Fresh tallies are broadcast to the chat
+func (service *PollService) Vote(ctx context.Context, userID string, chatID string, messageID string, options []int) (*model.PollResults, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_messageID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	chat, err := service.chatService.GetWritableChat(ctx, _chatID, _userID)
+	if err != nil {
+		return nil, err
+	}
+	message, err := service.messageRepo.GetChatMessage(ctx, chat.ID, _messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Poll == nil {
+		return nil, ErrNotPoll
+	}
+	if message.Poll.IsClosed(time.Now()) {
+		return nil, ErrPollClosed
+	}
+
+	options, err = checkChoice(message.Poll, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options) == 0 {
+		err = service.pollVoteRepo.DeleteVote(ctx, message.ID, _userID)
+	} else {
+		err = service.pollVoteRepo.SaveVote(ctx, &model.PollVote{
+			Message: message.ID,
+			Chat:    chat.ID,
+			User:    _userID,
+			Options: options,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := service.GetResults(ctx, []*model.Message{message}, _userID)
+	if err != nil {
+		return nil, err
+	}
+	result := results[message.ID]
+
+	// own votes are personal, everyone else gets bare tallies
+	broadcast := *result
+	broadcast.MyVotes = nil
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventPollUpdated,
+		Payload:    &broadcast,
+		Recipients: chat.Users,
+		Chat:       chat.ID,
+	})
+
+	return result, nil
+}
+
+// GetResults aggregates votes of polls among given messages, others are skipped
+func (service *PollService) GetResults(ctx context.Context, messages []*model.Message, viewerID primitive.ObjectID) (map[primitive.ObjectID]*model.PollResults, error) {
+	results := make(map[primitive.ObjectID]*model.PollResults)
+	messageIDs := make([]primitive.ObjectID, 0)
+	now := time.Now()
+	for _, message := range messages {
+		if message.Poll == nil {
+			continue
+		}
+		result := &model.PollResults{
+			Message: message.ID,
+			Tallies: make([]model.PollTally, len(message.Poll.Options)),
+			Closed:  message.Poll.IsClosed(now),
+		}
+		for i, option := range message.Poll.Options {
+			result.Tallies[i].Option = option.ID
+		}
+		results[message.ID] = result
+		messageIDs = append(messageIDs, message.ID)
+	}
+	if len(messageIDs) == 0 {
+		return results, nil
+	}
+
+	tallies, voterCounts, err := service.pollVoteRepo.GetTallies(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	anonymous := make(map[primitive.ObjectID]bool, len(messages))
+	for _, message := range messages {
+		if message.Poll != nil {
+			anonymous[message.ID] = message.Poll.Anonymous
+		}
+	}
+	for _, tally := range tallies {
+		result := results[tally.Message]
+		for i := range result.Tallies {
+			if result.Tallies[i].Option != tally.Option {
+				continue
+			}
+			result.Tallies[i].Votes = tally.Votes
+			if !anonymous[tally.Message] {
+				result.Tallies[i].Voters = tally.Voters
+			}
+		}
+	}
+	for _, count := range voterCounts {
+		results[count.Message].Voters = count.Count
+	}
+
+	votes, err := service.pollVoteRepo.GetUserVotes(ctx, messageIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, vote := range votes {
+		results[vote.Message].MyVotes = vote.Options
+	}
+
+	return results, nil
+}
+
+// AttachResults fills poll results of listed messages as the viewer sees them
+func (service *PollService) AttachResults(ctx context.Context, messages []model.MessagePopulated, viewerID primitive.ObjectID) error {
+	plain := make([]*model.Message, 0, len(messages))
+	for i := range messages {
+		plain = append(plain, messages[i].Message)
+	}
+	results, err := service.GetResults(ctx, plain, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		messages[i].PollResults = results[messages[i].ID]
+	}
+	return nil
+}
+
+func (service *PollService) ClearChatVotes(ctx context.Context, chatID primitive.ObjectID) error {
+	return service.pollVoteRepo.DeleteChatVotes(ctx, chatID)
+}
+
+func (service *PollService) ClearMessagesVotes(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	return service.pollVoteRepo.DeleteMessagesVotes(ctx, messageIDs)
+}
+
+// checkChoice dedupes chosen options and makes sure the poll has them
+func checkChoice(poll *model.Poll, options []int) ([]int, error) {
+	chosen := make([]int, 0, len(options))
+	for _, option := range options {
+		known := slices.ContainsFunc(poll.Options, func(pollOption model.PollOption) bool {
+			return pollOption.ID == option
+		})
+		if !known {
+			return nil, ErrUnknownPollOption
+		}
+		if !slices.Contains(chosen, option) {
+			chosen = append(chosen, option)
+		}
+	}
+	if !poll.MultipleChoice && len(chosen) > 1 {
+		return nil, ErrSingleChoice
+	}
+	slices.Sort(chosen)
+	return chosen, nil
+}
+
+const (
+	MaxPollQuestionLength = 300
+	MinPollOptions        = 2
+	MaxPollOptions        = 10
+	MaxPollOptionLength   = 100
+	MaxPollDuration       = 90 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidPollOptions = errors.New("poll needs 2 to 10 distinct non-empty options up to 100 characters")
+	ErrInvalidPollClose   = errors.New("poll close time must be in the future, within 90 days")
+	ErrNotPoll            = errors.New("message is not a poll")
+	ErrPollClosed         = errors.New("poll is closed")
+	ErrUnknownPollOption  = errors.New("poll has no such option")
+	ErrSingleChoice       = errors.New("poll allows only one option")
+)
@@ -0,0 +1,172 @@
+package userservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/mailhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// SendEmailConfirmation mails one-time link proving the user owns his current email.
+// Nothing is mailed for email confirmed by someone else, but the caller isn't told so
+func (service *UserService) SendEmailConfirmation(ctx context.Context, user *model.User) error {
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.EmailVerified {
+		return ErrEmailVerified
+	}
+	if err := service.CheckEmailFree(ctx, user.Email, user.ID); err != nil {
+		if errors.Is(err, ErrEmailOccupied) {
+			slog.Info("email confirmation skipped for email confirmed by another user", slog.String("userId", user.ID.Hex()))
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	recent, err := service.emailConfirmationRepo.CountRecentConfirmations(ctx, user.ID, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent >= MaxConfirmationsPerHour {
+		return ErrTooManyConfirmations
+	}
+
+	token, err := pwdhelper.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	lifetime := getConfirmationTokenLifetime()
+	if err = service.emailConfirmationRepo.SaveEmailConfirmation(ctx, &model.EmailConfirmation{
+		User:      user.ID,
+		Email:     user.Email,
+		TokenHash: pwdhelper.HashToken(token),
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	return mailhelper.GetMailer().Send(ctx, mailhelper.Mail{
+		To:      user.Email,
+		Subject: "Email confirmation",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nplease confirm this is your email address.\n"+
+				"Use the link below within %d hours, it works only once:\n\n%s\n\n"+
+				"If it wasn't you just ignore this letter.\n",
+			user.Name, int(lifetime.Hours()), confirmationLink(token),
+		),
+	})
+}
+
+// ConfirmEmail marks email of the user verified by mailed token, unless he has changed it since
+// or someone else has confirmed it first. Unconfirmed claims of others on the email are dropped
+func (service *UserService) ConfirmEmail(ctx context.Context, token string) error {
+	confirmation, err := service.emailConfirmationRepo.UseToken(ctx, pwdhelper.HashToken(token))
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return ErrInvalidConfirmationToken
+		}
+		return err
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, confirmation.User.Hex())
+	if err != nil {
+		return err
+	}
+	if user.Email != confirmation.Email {
+		return ErrInvalidConfirmationToken
+	}
+	if err = service.CheckEmailFree(ctx, user.Email, user.ID); err != nil {
+		if errors.Is(err, ErrEmailOccupied) {
+			return ErrInvalidConfirmationToken
+		}
+		return err
+	}
+
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"emailVerified": true,
+		"updatedAt":     time.Now(),
+	}); err != nil {
+		return err
+	}
+	return service.userRepo.UnsetUnverifiedEmails(ctx, user.Email, user.ID)
+}
+
+// changeEmail sets new unverified email, proven by current password since it's the way to reset one.
+// Email confirmed by someone else is taken the same way, so the reply doesn't reveal who is registered
+func (service *UserService) changeEmail(ctx context.Context, userID primitive.ObjectID, email string, currentPassword *string, reauthToken string) (bool, error) {
+	user, err := service.userRepo.GetUserByID(ctx, userID.Hex())
+	if err != nil {
+		return false, err
+	}
+	rawPassword := ""
+	if currentPassword != nil {
+		rawPassword = *currentPassword
+	}
+	if err = CheckCurrentPassword(user, rawPassword, reauthToken); err != nil {
+		return false, err
+	}
+	if email == user.Email {
+		return false, nil
+	}
+
+	if email == "" {
+		return true, service.userRepo.UnsetUserFields(ctx, userID, "email", "emailVerified")
+	}
+	return true, service.userRepo.UpdateUser(ctx, userID, map[string]any{
+		"email":         email,
+		"emailVerified": false,
+	})
+}
+
+// sendEmailConfirmationQuietly doesn't fail the change of email, the user can request another letter
+func (service *UserService) sendEmailConfirmationQuietly(ctx context.Context, user *model.User) {
+	if err := service.SendEmailConfirmation(ctx, user); err != nil {
+		slog.Error("cannot send email confirmation", slog.String("userId", user.ID.Hex()), slog.Any("error", err))
+	}
+}
+
+func getConfirmationTokenLifetime() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EMAIL_CONFIRMATION_TOKEN_LIFETIME_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func confirmationLink(token string) string {
+	base := os.Getenv("EMAIL_CONFIRMATION_URL")
+	if base == "" {
+		return token
+	}
+	link, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+const MaxConfirmationsPerHour int64 = 5
+
+var (
+	ErrNoEmail                  = errors.New("user has no email")
+	ErrEmailVerified            = errors.New("email is already confirmed")
+	ErrTooManyConfirmations     = errors.New("too many confirmation letters requested, try later")
+	ErrInvalidConfirmationToken = errors.New("confirmation token is invalid, expired or already used")
+)
@@ -2,20 +2,37 @@ package userservice
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
 	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/handlehelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/jwthelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
 	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/emailconfirmationrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/handleredirectrepo"
 	"github.com/MykolaSainiuk/schatgo/src/repo/userrepo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserService struct {
-	userRepo *userrepo.UserRepo
+	userRepo              *userrepo.UserRepo
+	handleRedirectRepo    *handleredirectrepo.HandleRedirectRepo
+	emailConfirmationRepo *emailconfirmationrepo.EmailConfirmationRepo
 }
 
 func NewUserService(srv types.IServer) *UserService {
 	return &UserService{
-		userRepo: userrepo.NewUserRepo(srv.GetDB()),
+		userRepo:              userrepo.NewUserRepo(srv.GetDB()),
+		handleRedirectRepo:    handleredirectrepo.NewHandleRedirectRepo(srv.GetDB()),
+		emailConfirmationRepo: emailconfirmationrepo.NewEmailConfirmationRepo(srv.GetDB()),
 	}
 }
 
@@ -23,20 +40,112 @@ func (service *UserService) GetUserByID(ctx context.Context, userID string) (*mo
 	return service.userRepo.GetUserByID(ctx, userID)
 }
 
+func (service *UserService) GetUsersByIDs(ctx context.Context, userIDs []primitive.ObjectID) ([]model.User, error) {
+	return service.userRepo.GetUsersByIDs(ctx, userIDs)
+}
+
 func (service *UserService) GetUserInfo(ctx context.Context, userID string) (*model.UserPopulated, error) {
 	return service.userRepo.GetUserByIdPopulated(ctx, userID)
 }
 
-func (service *UserService) GetUserByName(ctx context.Context, name string) (*model.User, error) {
-	return service.userRepo.GetUserByName(ctx, name)
+// GetUserByHandle finds user by his current handle or any of former ones
+func (service *UserService) GetUserByHandle(ctx context.Context, handle string) (*model.User, error) {
+	handle = handlehelper.Normalize(handle)
+
+	user, err := service.userRepo.GetUserByHandle(ctx, handle)
+	if err == nil || !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return user, err
+	}
+
+	redirect, rErr := service.handleRedirectRepo.GetRedirect(ctx, handle)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return service.userRepo.GetUserByID(ctx, redirect.User.Hex())
+}
+
+// GenerateHandle picks free handle derived from the name
+func (service *UserService) GenerateHandle(ctx context.Context, name string) (string, error) {
+	base := handlehelper.FromName(name)
+	handle := base
+	for n := 1; n <= MaxHandleAttempts; n++ {
+		_, err := service.GetUserByHandle(ctx, handle)
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return handle, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		handle = handlehelper.WithSuffix(base, n)
+	}
+
+	return "", errors.Join(cmnerr.ErrUniqueViolation, ErrNoFreeHandle)
+}
+
+// ChangeHandle sets new handle keeping the former one as a redirect
+func (service *UserService) ChangeHandle(ctx context.Context, userID string, newHandle string) (*model.User, error) {
+	newHandle = handlehelper.Normalize(newHandle)
+	if !handlehelper.IsValid(newHandle) {
+		return nil, ErrInvalidHandle
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Handle == newHandle {
+		return user, nil
+	}
+	if !user.HandleChangedAt.IsZero() && time.Since(user.HandleChangedAt) < HandleChangeCooldown {
+		return nil, ErrHandleCooldown
+	}
+
+	// former handles of other users stay reserved for their redirects
+	redirect, err := service.handleRedirectRepo.GetRedirect(ctx, newHandle)
+	if err != nil && !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+		return nil, err
+	}
+	if redirect != nil && redirect.User != user.ID {
+		return nil, cmnerr.ErrUniqueViolation
+	}
+
+	now := time.Now()
+	if err = service.userRepo.UpdateUser(ctx, user.ID, map[string]any{
+		"handle":          newHandle,
+		"handleChangedAt": now,
+		"updatedAt":       now,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = service.handleRedirectRepo.SaveRedirect(ctx, user.Handle, user.ID); err != nil {
+		return nil, err
+	}
+	if redirect != nil {
+		if err = service.handleRedirectRepo.DeleteRedirect(ctx, newHandle, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return service.userRepo.GetUserByID(ctx, userID)
 }
 
-func (service *UserService) AddContact(ctx context.Context, userID string, contactName string) error {
-	return service.userRepo.AddContact(ctx, userID, contactName)
+func (service *UserService) SearchUsers(ctx context.Context, userID string, query string, limit int) ([]model.User, error) {
+	searcher, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.userRepo.SearchUsersByPrefix(ctx, searcher, strings.ToLower(query), handlehelper.Normalize(query), limit)
 }
 
-func (service *UserService) GetAllUsers(ctx context.Context, userID string) ([]model.User, error) {
-	return service.userRepo.GetAllUsers(ctx, userID)
+func (service *UserService) SetDiscoverable(ctx context.Context, userID string, discoverable bool) error {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return service.userRepo.UpdateUser(ctx, _userID, map[string]any{
+		"discoverable": discoverable,
+		"updatedAt":    time.Now(),
+	})
 }
 
 func (service *UserService) GetAllContacts(ctx context.Context, userID string) ([]model.User, error) {
@@ -50,3 +159,150 @@ func (service *UserService) GetContactsPaginated(ctx context.Context, userID str
 func (service *UserService) RegisterNewChat(ctx context.Context, chatID primitive.ObjectID, userID string, anotherUserID string) error {
 	return service.userRepo.AddChatIdToUsers(ctx, chatID, userID, anotherUserID)
 }
+
+func (service *UserService) RegisterChat(ctx context.Context, chatID primitive.ObjectID, userID primitive.ObjectID) error {
+	return service.userRepo.AddChatIdToUser(ctx, chatID, userID)
+}
+
+// UpdateProfile changes only provided fields and notifies contacts about it.
+// New email stays unverified until the user follows the link mailed to it
+func (service *UserService) UpdateProfile(ctx context.Context, userID string, data *dto.UpdateProfileInputDto) (*model.User, error) {
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+
+	emailChanged := false
+	if data.Email != nil {
+		var err error
+		if emailChanged, err = service.changeEmail(ctx, _userID, NormalizeEmail(*data.Email), data.CurrentPassword, data.ReauthToken); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := map[string]any{"updatedAt": time.Now()}
+	if data.Name != nil {
+		changes["name"] = *data.Name
+	}
+	if data.AvatarUri != nil {
+		changes["avatarUri"] = *data.AvatarUri
+	}
+	if data.Bio != nil {
+		changes["bio"] = *data.Bio
+	}
+	if data.StatusText != nil {
+		changes["statusText"] = *data.StatusText
+	}
+
+	if err := service.userRepo.UpdateUser(ctx, _userID, changes); err != nil {
+		return nil, err
+	}
+
+	user, err := service.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if emailChanged && user.Email != "" {
+		service.sendEmailConfirmationQuietly(ctx, user)
+	}
+
+	eventhelper.Publish(eventhelper.Event{
+		Type:       eventhelper.EventUserUpdated,
+		Payload:    ToProfileOutputDto(user),
+		Recipients: append([]primitive.ObjectID{user.ID}, user.Contacts...),
+	})
+
+	return user, nil
+}
+
+// CheckCurrentPassword proves the User is present by current password, or by reauth token of fresh OIDC login,
+// the only way for users signed up via OIDC who have no password
+func CheckCurrentPassword(user *model.User, rawPassword string, reauthToken string) error {
+	if reauthToken != "" {
+		payload, err := jwthelper.VerifyReauthToken(reauthToken)
+		if err != nil || payload.UserID != user.ID.Hex() {
+			slog.Info("bad reauth token")
+			return cmnerr.ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	if !pwdhelper.CheckPasswordHash(rawPassword, user.Hash) {
+		slog.Info("bad password")
+		return cmnerr.ErrPasswordMismatch
+	}
+	return nil
+}
+
+// CheckEmailFree fails if anyone but the owner has confirmed the email.
+// Unconfirmed claims don't count, they are dropped once the owner confirms it
+func (service *UserService) CheckEmailFree(ctx context.Context, email string, ownerID primitive.ObjectID) error {
+	user, err := service.userRepo.GetUserByVerifiedEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return nil
+		}
+		return err
+	}
+	if user.ID != ownerID {
+		return ErrEmailOccupied
+	}
+	return nil
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func ToProfileOutputDto(user *model.User) dto.ProfileOutputDto {
+	return dto.ProfileOutputDto{
+		ID:         user.ID.Hex(),
+		Handle:     user.Handle,
+		Name:       user.Name,
+		AvatarUri:  user.AvatarUri,
+		Bio:        user.Bio,
+		StatusText: user.StatusText,
+		UpdatedAt:  user.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func ToUserPublicOutputDto(user *model.User) dto.UserPublicOutputDto {
+	return dto.UserPublicOutputDto{
+		ID:        user.ID.Hex(),
+		Handle:    user.Handle,
+		Name:      user.Name,
+		AvatarUri: user.AvatarUri,
+	}
+}
+
+// IsBlocked reports whether any of two users has blocked another one
+func IsBlocked(user1 *model.User, user2 *model.User) bool {
+	return slices.Contains(user1.Blocked, user2.ID) || slices.Contains(user2.Blocked, user1.ID)
+}
+
+// HideFromBlocked leaves only identity of users who have blocked the viewer, everything else is cleared
+func HideFromBlocked(viewerID primitive.ObjectID, users ...*model.User) {
+	for _, user := range users {
+		if user != nil && slices.Contains(user.Blocked, viewerID) {
+			*user = model.User{
+				ID:     user.ID,
+				Handle: user.Handle,
+				Name:   user.Name,
+				IsBot:  user.IsBot,
+
+				Blocked:  make([]primitive.ObjectID, 0),
+				Contacts: make([]primitive.ObjectID, 0),
+				Chats:    make([]primitive.ObjectID, 0),
+			}
+		}
+	}
+}
+
+const (
+	HandleChangeCooldown     = 30 * 24 * time.Hour
+	MaxHandleAttempts    int = 100
+)
+
+var (
+	ErrInvalidHandle  = errors.New("handle must be 3-32 chars long and consist of latin letters, digits or underscores")
+	ErrHandleCooldown = errors.New("handle can be changed once per 30 days")
+	ErrNoFreeHandle   = errors.New("cannot find free handle")
+	ErrEmailOccupied  = errors.New("such email is already in use")
+)
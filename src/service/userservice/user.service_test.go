@@ -0,0 +1,53 @@
+package userservice
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+func TestIsBlocked(t *testing.T) {
+	user1, user2 := &model.User{ID: primitive.NewObjectID()}, &model.User{ID: primitive.NewObjectID()}
+
+	if IsBlocked(user1, user2) {
+		t.Error("expected no block between users with empty block lists")
+	}
+
+	user2.Blocked = []primitive.ObjectID{user1.ID}
+	if !IsBlocked(user1, user2) || !IsBlocked(user2, user1) {
+		t.Error("expected block to work both ways")
+	}
+}
+
+func TestHideFromBlocked(t *testing.T) {
+	viewerID := primitive.NewObjectID()
+	blocker := &model.User{
+		ID:           primitive.NewObjectID(),
+		Name:         "Blocker",
+		AvatarUri:    "https://example.com/avatar.png",
+		Discoverable: true,
+		Blocked:      []primitive.ObjectID{viewerID},
+		Contacts:     []primitive.ObjectID{primitive.NewObjectID()},
+		Chats:        []primitive.ObjectID{primitive.NewObjectID()},
+	}
+	other := &model.User{
+		ID:        primitive.NewObjectID(),
+		Name:      "Other",
+		AvatarUri: "https://example.com/other.png",
+	}
+	blockerID := blocker.ID
+
+	HideFromBlocked(viewerID, blocker, other, nil)
+
+	if blocker.ID != blockerID || blocker.Name != "Blocker" {
+		t.Errorf("expected blocker identity kept, got %+v", blocker)
+	}
+	if blocker.AvatarUri != "" || len(blocker.Blocked) != 0 || len(blocker.Contacts) != 0 || len(blocker.Chats) != 0 {
+		t.Errorf("expected blocker profile hidden, got %+v", blocker)
+	}
+	if other.AvatarUri == "" {
+		t.Error("expected profile of user who hasn't blocked viewer kept")
+	}
+}
@@ -0,0 +1,164 @@
+package webhookservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// deliveryPayload is the body receivers get
+type deliveryPayload struct {
+	ID        string             `json:"id"`
+	Event     string             `json:"event"`
+	Chat      primitive.ObjectID `json:"chat"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      any                `json:"data"`
+}
+
+// EnqueueEvent puts delivery of the chat event for every webhook subscribed to it into the queue
+func (service *WebhookService) EnqueueEvent(ctx context.Context, event eventhelper.Event) error {
+	if event.Chat.IsZero() {
+		return nil
+	}
+
+	webhooks, err := service.webhookRepo.GetChatWebhooks(ctx, event.Chat, event.Type)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]*model.WebhookDelivery, 0, len(webhooks))
+	for i := range webhooks {
+		deliveryID := primitive.NewObjectID()
+		payload, err := json.Marshal(deliveryPayload{
+			ID:        deliveryID.Hex(),
+			Event:     event.Type,
+			Chat:      event.Chat,
+			CreatedAt: event.CreatedAt,
+			Data:      event.Payload,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot marshal webhook payload: %w", err)
+		}
+
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			ID:               deliveryID,
+			Webhook:          webhooks[i].ID,
+			Chat:             event.Chat,
+			Event:            event.Type,
+			Payload:          string(payload),
+			Status:           model.DeliveryStatusPending,
+			NextAttemptAt:    now,
+			PayloadExpiresAt: event.ExpiresAt,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		})
+	}
+
+	return service.deliveryRepo.SaveDeliveries(ctx, deliveries)
+}
+
+// DeliverNext makes one attempt of the most overdue delivery, reports false if the queue has nothing due
+func (service *WebhookService) DeliverNext(ctx context.Context) (bool, error) {
+	delivery, err := service.deliveryRepo.ClaimDue(ctx, DeliveryLockTime)
+	if err != nil || delivery == nil {
+		return false, err
+	}
+
+	webhook, err := service.webhookRepo.GetWebhookByID(ctx, delivery.Webhook)
+	if err != nil {
+		if !errors.Is(err, cmnerr.ErrNotFoundEntity) {
+			return true, err
+		}
+		// webhook was deleted meanwhile
+		delivery.Status = model.DeliveryStatusDead
+		delivery.LastError = "webhook is deleted"
+		return true, service.deliveryRepo.FinishAttempt(ctx, delivery)
+	}
+
+	statusCode, err := service.send(ctx, webhook, delivery)
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status = model.DeliveryStatusSucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= MaxDeliveryAttempts:
+		delivery.Status = model.DeliveryStatusDead
+		delivery.LastError = err.Error()
+		slog.Info("webhook delivery is dead", slog.String("deliveryId", delivery.ID.Hex()), slog.String("error", err.Error()))
+	default:
+		delivery.Status = model.DeliveryStatusPending
+		delivery.NextAttemptAt = time.Now().Add(RetryDelay(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	return true, service.deliveryRepo.FinishAttempt(ctx, delivery)
+}
+
+// send posts signed payload, any non-2xx answer counts as failure
+func (service *WebhookService) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", DeliveryUserAgent)
+	req.Header.Set(webhookhelper.HeaderEvent, delivery.Event)
+	req.Header.Set(webhookhelper.HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(webhookhelper.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookhelper.HeaderSignature, webhookhelper.Sign(webhook.Secret, timestamp, body))
+
+	res, err := webhookhelper.GetClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// drain a bit to let connection be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, MaxDrainedResponseSize))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("%w: %d", webhookhelper.ErrUnexpectedStatus, res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// RetryDelay is exponential back-off after given number of failed attempts
+func RetryDelay(attempts int) time.Duration {
+	delay := FirstRetryDelay
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRetryDelay)
+}
+
+const (
+	MaxDeliveryAttempts = 8
+	FirstRetryDelay     = 10 * time.Second
+	MaxRetryDelay       = time.Hour
+	// DeliveryLockTime is how long claimed delivery is hidden from other workers
+	DeliveryLockTime = time.Minute
+
+	DeliveryUserAgent      = "schatgo-webhooks/1.0"
+	MaxDrainedResponseSize = 64 << 10
+)
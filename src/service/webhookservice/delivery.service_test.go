@@ -0,0 +1,197 @@
+package webhookservice
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/testhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+)
+
+// receivedDelivery is what the receiver got and whether the signature matched
+type receivedDelivery struct {
+	event    string
+	delivery string
+	body     string
+	signed   bool
+}
+
+func TestDeliverNext(t *testing.T) {
+	// receiver listens on loopback
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "true")
+	const secret = webhookhelper.SecretPrefix + "test"
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name           string
+		receiverStatus int
+		// attempts is number of attempts already failed
+		attempts       int
+		expectedStatus string
+		expectedDelay  time.Duration
+	}{
+		{name: "succeeds on 2xx", receiverStatus: http.StatusNoContent, expectedStatus: model.DeliveryStatusSucceeded},
+		{name: "retries after first failure", receiverStatus: http.StatusInternalServerError, expectedStatus: model.DeliveryStatusPending, expectedDelay: FirstRetryDelay},
+		{name: "backs off exponentially", receiverStatus: http.StatusBadGateway, attempts: 3, expectedStatus: model.DeliveryStatusPending, expectedDelay: 8 * FirstRetryDelay},
+		{name: "gives up after max attempts", receiverStatus: http.StatusNotFound, attempts: MaxDeliveryAttempts - 1, expectedStatus: model.DeliveryStatusDead},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			var (
+				mu       sync.Mutex
+				received []receivedDelivery
+			)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				timestamp, _ := strconv.ParseInt(r.Header.Get(webhookhelper.HeaderTimestamp), 10, 64)
+				mu.Lock()
+				received = append(received, receivedDelivery{
+					event:    r.Header.Get(webhookhelper.HeaderEvent),
+					delivery: r.Header.Get(webhookhelper.HeaderDelivery),
+					body:     string(body),
+					signed:   webhookhelper.Verify(secret, timestamp, body, r.Header.Get(webhookhelper.HeaderSignature)),
+				})
+				mu.Unlock()
+				w.WriteHeader(tt.receiverStatus)
+			}))
+			defer receiver.Close()
+
+			deliveryID, webhookID, chatID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+			payload := `{"id":"` + deliveryID.Hex() + `","event":"message.created"}`
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+					{Key: "_id", Value: deliveryID},
+					{Key: "webhook", Value: webhookID},
+					{Key: "chat", Value: chatID},
+					{Key: "event", Value: "message.created"},
+					{Key: "payload", Value: payload},
+					{Key: "status", Value: model.DeliveryStatusDelivering},
+					{Key: "attempts", Value: tt.attempts},
+				}}),
+				mtest.CreateCursorResponse(0, "schat.webhooks", mtest.FirstBatch, bson.D{
+					{Key: "_id", Value: webhookID},
+					{Key: "chat", Value: chatID},
+					{Key: "url", Value: receiver.URL + "/hook"},
+					{Key: "secret", Value: secret},
+					{Key: "events", Value: bson.A{"message.created"}},
+				}),
+				mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			)
+
+			service := NewWebhookService(testhelper.NewServer(mt))
+			before := time.Now()
+			more, err := service.DeliverNext(context.Background())
+			if err != nil || !more {
+				mt.Fatalf("expected delivery attempt, got more %v, error %v", more, err)
+			}
+
+			if len(received) != 1 {
+				mt.Fatalf("expected receiver called once, got %d", len(received))
+			}
+			got := received[0]
+			if !got.signed {
+				mt.Error("expected valid signature")
+			}
+			if got.event != "message.created" || got.delivery != deliveryID.Hex() || got.body != payload {
+				mt.Errorf("unexpected delivery %+v", got)
+			}
+
+			update := startedCommand(mt, "update").Lookup("updates").Array().Index(0).Value().Document().
+				Lookup("u", "$set").Document()
+			if status := update.Lookup("status").StringValue(); status != tt.expectedStatus {
+				mt.Errorf("expected status %s, got %s", tt.expectedStatus, status)
+			}
+			if attempts := update.Lookup("attempts").AsInt64(); attempts != int64(tt.attempts+1) {
+				mt.Errorf("expected %d attempts, got %d", tt.attempts+1, attempts)
+			}
+			if code := update.Lookup("lastStatusCode").AsInt64(); code != int64(tt.receiverStatus) {
+				mt.Errorf("expected last status code %d, got %d", tt.receiverStatus, code)
+			}
+			// only finished deliveries expire
+			_, expires := update.Lookup("expiresAt").TimeOK()
+			if finished := tt.expectedStatus != model.DeliveryStatusPending; expires != finished {
+				mt.Errorf("expected delivery to expire %v, got %v", finished, expires)
+			}
+			if tt.expectedDelay > 0 {
+				// stored time is truncated to milliseconds
+				nextAttemptAt := update.Lookup("nextAttemptAt").Time()
+				if delay := nextAttemptAt.Sub(before.Truncate(time.Millisecond)); delay < tt.expectedDelay || delay > tt.expectedDelay+time.Minute {
+					mt.Errorf("expected next attempt in %s, got %s", tt.expectedDelay, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueueEventExpiresWithMessage(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("delivery of disappearing message expires with it", func(mt *mtest.T) {
+		chatID := primitive.NewObjectID()
+		expiresAt := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "schat.webhooks", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "chat", Value: chatID},
+				{Key: "events", Value: bson.A{eventhelper.EventMessageCreated}},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewWebhookService(testhelper.NewServer(mt))
+		err := service.EnqueueEvent(context.Background(), eventhelper.Event{
+			Type:      eventhelper.EventMessageCreated,
+			Payload:   map[string]string{"text": "soon gone"},
+			Chat:      chatID,
+			ExpiresAt: &expiresAt,
+		})
+		if err != nil {
+			mt.Fatalf("expected delivery enqueued, got error %v", err)
+		}
+
+		delivery := startedCommand(mt, "insert").Lookup("documents").Array().Index(0).Value().Document()
+		if got, ok := delivery.Lookup("payloadExpiresAt").TimeOK(); !ok || !got.Equal(expiresAt) {
+			mt.Errorf("expected payload to expire at %s, got %s", expiresAt, got)
+		}
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 10 * time.Second},
+		{attempts: 2, expected: 20 * time.Second},
+		{attempts: 5, expected: 160 * time.Second},
+		{attempts: 20, expected: MaxRetryDelay},
+	}
+	for _, tt := range tests {
+		if delay := RetryDelay(tt.attempts); delay != tt.expected {
+			t.Errorf("expected delay %s after %d attempts, got %s", tt.expected, tt.attempts, delay)
+		}
+	}
+}
+
+func startedCommand(mt *mtest.T, name string) bson.Raw {
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("no %s command was sent", name)
+	return nil
+}
@@ -0,0 +1,123 @@
+package webhookservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+// CreateIncomingHook makes secret URL posting into the chat on behalf of the user, the token is returned only here.
+// Only chat admins may do it
+func (service *WebhookService) CreateIncomingHook(ctx context.Context, userID string, chatID string, data *dto.NewIncomingHookInputDto) (*dto.NewIncomingHookOutputDto, error) {
+	chat, err := service.getAdminChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := service.incomingHookRepo.CountActiveChatHooks(ctx, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxIncomingHooksPerChat {
+		return nil, ErrTooManyIncomingHooks
+	}
+
+	secret, err := pwdhelper.GenerateRandomString(IncomingHookTokenLength)
+	if err != nil {
+		return nil, err
+	}
+	token := model.IncomingHookTokenPrefix + secret
+
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	hook := &model.IncomingHook{
+		Chat:      chat.ID,
+		Creator:   _userID,
+		Name:      data.Name,
+		AvatarUri: data.AvatarUri,
+		Prefix:    token[:IncomingHookShownPrefixLength],
+		TokenHash: pwdhelper.HashToken(token),
+		CreatedAt: time.Now(),
+	}
+	hookID, err := service.incomingHookRepo.SaveHook(ctx, hook)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("incoming hook created", slog.String("chatId", chat.ID.Hex()), slog.String("hookId", hookID.Hex()))
+
+	return &dto.NewIncomingHookOutputDto{
+		ID:     hookID.Hex(),
+		Name:   hook.Name,
+		Path:   IncomingHookPath + token,
+		Token:  token,
+		Prefix: hook.Prefix,
+	}, nil
+}
+
+func (service *WebhookService) ListIncomingHooks(ctx context.Context, userID string, chatID string) ([]model.IncomingHook, error) {
+	chat, err := service.getUserChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return service.incomingHookRepo.GetChatHooks(ctx, chat.ID)
+}
+
+// RevokeIncomingHook can be done by chat admins, the URL stops working immediately
+func (service *WebhookService) RevokeIncomingHook(ctx context.Context, userID string, chatID string, hookID string) error {
+	chat, err := service.getAdminChat(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	_hookID, err := primitive.ObjectIDFromHex(hookID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	return service.incomingHookRepo.RevokeHook(ctx, chat.ID, _hookID)
+}
+
+// PostViaIncomingHook posts message as the hook creator, so it's possible only while the creator may write into the chat
+func (service *WebhookService) PostViaIncomingHook(ctx context.Context, token string, data *dto.IncomingHookMessageInputDto) (primitive.ObjectID, error) {
+	if !strings.HasPrefix(token, model.IncomingHookTokenPrefix) {
+		return primitive.NilObjectID, cmnerr.ErrNotFoundEntity
+	}
+	hook, err := service.incomingHookRepo.GetActiveHook(ctx, pwdhelper.HashToken(token))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	messageID, err := service.messageService.NewMessage(ctx, hook.Chat.Hex(), hook.Creator.Hex(),
+		&dto.NewMessageInputDto{Text: data.Text},
+		messageservice.ViaHook(&model.MessageHook{ID: hook.ID, Name: hook.Name, AvatarUri: hook.AvatarUri}),
+	)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if err := service.incomingHookRepo.TouchHook(ctx, hook.ID, IncomingHookTouchInterval); err != nil {
+		slog.Error("cannot record incoming hook usage", slog.Any("error", err))
+	}
+
+	return messageID, nil
+}
+
+const (
+	MaxIncomingHooksPerChat = 10
+
+	IncomingHookPath              = "/api/hook/"
+	IncomingHookTokenLength       = 40
+	IncomingHookShownPrefixLength = 10
+	IncomingHookTouchInterval     = time.Minute
+)
+
+var (
+	ErrTooManyIncomingHooks = errors.New("incoming hooks limit per chat is reached")
+)
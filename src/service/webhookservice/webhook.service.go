@@ -0,0 +1,181 @@
+package webhookservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/MykolaSainiuk/schatgo/src/api/dto"
+	"github.com/MykolaSainiuk/schatgo/src/common/cmnerr"
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/pwdhelper"
+	"github.com/MykolaSainiuk/schatgo/src/helper/webhookhelper"
+	"github.com/MykolaSainiuk/schatgo/src/model"
+	"github.com/MykolaSainiuk/schatgo/src/repo/incominghookrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/webhookdeliveryrepo"
+	"github.com/MykolaSainiuk/schatgo/src/repo/webhookrepo"
+	"github.com/MykolaSainiuk/schatgo/src/service/chatservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+)
+
+type WebhookService struct {
+	webhookRepo      *webhookrepo.WebhookRepo
+	deliveryRepo     *webhookdeliveryrepo.WebhookDeliveryRepo
+	incomingHookRepo *incominghookrepo.IncomingHookRepo
+
+	chatService    *chatservice.ChatService
+	messageService *messageservice.MessageService
+}
+
+func NewWebhookService(srv types.IServer) *WebhookService {
+	return &WebhookService{
+		webhookRepo:      webhookrepo.NewWebhookRepo(srv.GetDB()),
+		deliveryRepo:     webhookdeliveryrepo.NewWebhookDeliveryRepo(srv.GetDB()),
+		incomingHookRepo: incominghookrepo.NewIncomingHookRepo(srv.GetDB()),
+
+		chatService:    chatservice.NewChatService(srv),
+		messageService: messageservice.NewMessageService(srv),
+	}
+}
+
+// CreateWebhook registers webhook for the chat administered by the user, the secret is returned only here
+func (service *WebhookService) CreateWebhook(ctx context.Context, userID string, chatID string, data *dto.NewWebhookInputDto) (*dto.NewWebhookOutputDto, error) {
+	chat, err := service.getAdminChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhookhelper.ValidateUrl(data.Url); err != nil {
+		return nil, err
+	}
+
+	count, err := service.webhookRepo.CountChatWebhooks(ctx, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxWebhooksPerChat {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := pwdhelper.GenerateRandomString(WebhookSecretLength)
+	if err != nil {
+		return nil, err
+	}
+	secret = webhookhelper.SecretPrefix + secret
+
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	now := time.Now()
+	webhook := &model.Webhook{
+		Chat:      chat.ID,
+		Creator:   _userID,
+		Url:       data.Url,
+		Secret:    secret,
+		Events:    data.Events,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	webhookID, err := service.webhookRepo.SaveWebhook(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.NewWebhookOutputDto{
+		ID:     webhookID.Hex(),
+		Url:    webhook.Url,
+		Events: webhook.Events,
+		Secret: secret,
+	}, nil
+}
+
+func (service *WebhookService) ListWebhooks(ctx context.Context, userID string, chatID string) ([]model.Webhook, error) {
+	chat, err := service.getUserChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return service.webhookRepo.GetChatWebhooks(ctx, chat.ID, "")
+}
+
+// DeleteWebhook removes webhook together with its delivery log & pending deliveries, only chat admins may do it
+func (service *WebhookService) DeleteWebhook(ctx context.Context, userID string, chatID string, webhookID string) error {
+	chat, err := service.getAdminChat(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	webhook, err := service.getChatWebhook(ctx, chat, webhookID)
+	if err != nil {
+		return err
+	}
+	if err := service.webhookRepo.DeleteWebhook(ctx, webhook.Chat, webhook.ID); err != nil {
+		return err
+	}
+	return service.deliveryRepo.DeleteWebhookDeliveries(ctx, webhook.ID)
+}
+
+// ListDeliveries is delivery log of the webhook, newest first
+func (service *WebhookService) ListDeliveries(ctx context.Context, userID string, chatID string, webhookID string, pgParams types.PaginationParams) ([]model.WebhookDelivery, error) {
+	chat, err := service.getUserChat(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := service.getChatWebhook(ctx, chat, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return service.deliveryRepo.GetDeliveries(ctx, webhook.ID, pgParams)
+}
+
+// Redeliver requeues finished (dead or succeeded) delivery with fresh attempts, only chat admins may do it
+func (service *WebhookService) Redeliver(ctx context.Context, userID string, chatID string, webhookID string, deliveryID string) error {
+	chat, err := service.getAdminChat(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	webhook, err := service.getChatWebhook(ctx, chat, webhookID)
+	if err != nil {
+		return err
+	}
+	_deliveryID, err := primitive.ObjectIDFromHex(deliveryID)
+	if err != nil {
+		return errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	return service.deliveryRepo.Redeliver(ctx, webhook.ID, _deliveryID)
+}
+
+// getUserChat lets only chat members see its webhooks
+func (service *WebhookService) getUserChat(ctx context.Context, userID string, chatID string) (*model.Chat, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return service.chatService.GetUserChat(ctx, _chatID, _userID)
+}
+
+// getAdminChat lets only chat admins manage its webhooks
+func (service *WebhookService) getAdminChat(ctx context.Context, userID string, chatID string) (*model.Chat, error) {
+	_chatID, err := primitive.ObjectIDFromHex(chatID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	_userID, _ := primitive.ObjectIDFromHex(userID)
+	return service.chatService.GetAdminChat(ctx, _chatID, _userID)
+}
+
+func (service *WebhookService) getChatWebhook(ctx context.Context, chat *model.Chat, webhookID string) (*model.Webhook, error) {
+	_webhookID, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return nil, errors.Join(cmnerr.ErrNotFoundEntity, err)
+	}
+	return service.webhookRepo.GetChatWebhook(ctx, chat.ID, _webhookID)
+}
+
+const (
+	MaxWebhooksPerChat = 10
+
+	WebhookSecretLength = 32
+)
+
+var (
+	ErrTooManyWebhooks = errors.New("webhooks limit per chat is reached")
+)
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/MykolaSainiuk/schatgo/src/common/types"
+	"github.com/MykolaSainiuk/schatgo/src/helper/eventhelper"
+	"github.com/MykolaSainiuk/schatgo/src/service/messageservice"
+	"github.com/MykolaSainiuk/schatgo/src/service/webhookservice"
+)
+
+// Job is background work polling persistent queue. Run does one unit of work
+// and reports whether there may be more, otherwise the worker sleeps for Idle.
+type Job struct {
+	Name        string
+	Run         func(ctx context.Context) (bool, error)
+	Idle        time.Duration
+	Concurrency int
+}
+
+// StartAll runs background jobs of the app; call returned func to stop them gracefully
+func StartAll(srv types.IServer) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	webhookService := webhookservice.NewWebhookService(srv)
+	listenChatEvents(webhookService)
+
+	start(ctx, wg, Job{
+		Name:        "webhook deliveries",
+		Run:         webhookService.DeliverNext,
+		Idle:        2 * time.Second,
+		Concurrency: 4,
+	})
+
+	messageService := messageservice.NewMessageService(srv)
+	start(ctx, wg, Job{
+		Name:        "scheduled messages",
+		Run:         messageService.SendNextScheduled,
+		Idle:        time.Second,
+		Concurrency: 2,
+	})
+
+	start(ctx, wg, Job{
+		Name:        "expired messages",
+		Run:         messageService.SweepExpiredMessages,
+		Idle:        5 * time.Second,
+		Concurrency: 1,
+	})
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func start(ctx context.Context, wg *sync.WaitGroup, job Job) {
+	for i := 0; i < max(job.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				more, err := job.Run(ctx)
+				if err != nil && ctx.Err() == nil {
+					slog.Error("background job has failed", slog.String("job", job.Name), slog.Any("error", err.Error()))
+				}
+				if more && err == nil {
+					if ctx.Err() != nil {
+						return
+					}
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(job.Idle):
+				}
+			}
+		}()
+	}
+}
+
+// listenChatEvents puts webhook deliveries of chat events into the queue right when they're published,
+// so they survive crash of the process once the change which caused them is done
+func listenChatEvents(webhookService *webhookservice.WebhookService) {
+	eventhelper.Listen(func(event eventhelper.Event) {
+		if event.Chat.IsZero() {
+			return
+		}
+		// publisher doesn't pass its context, the enqueue must not outlive it much anyway
+		ctx, cancel := context.WithTimeout(context.Background(), EnqueueTimeout)
+		defer cancel()
+		if err := webhookService.EnqueueEvent(ctx, event); err != nil {
+			slog.Error("cannot enqueue webhook deliveries", slog.String("type", event.Type),
+				slog.String("chatId", event.Chat.Hex()), slog.Any("error", err.Error()))
+		}
+	})
+}
+
+const EnqueueTimeout = 5 * time.Second